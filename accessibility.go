@@ -0,0 +1,150 @@
+package svgg
+
+import (
+	"strings"
+)
+
+// AccessibleNode is a node of the accessibility tree of a document, for
+// apps that show rendered SVGs to surface what a screen reader would say
+// about them.
+type AccessibleNode struct {
+	Element *Element
+
+	// Role is the element's role attribute, or else its implicit role in
+	// the SVG Accessibility API Mappings, such as "graphics-document" for
+	// the root, "group", "graphics-symbol", "img", "text" or "link".
+	Role string
+
+	// Name is the accessible name, from aria-label, aria-labelledby, a
+	// <title> child or, for text and links, the text inside.
+	Name string
+
+	// Description is from aria-describedby or a <desc> child.
+	Description string
+
+	Children []*AccessibleNode
+}
+
+// implicitRoles are the roles of elements without a role attribute.
+var implicitRoles = map[string]string{
+	"svg":      "graphics-document",
+	"g":        "group",
+	"use":      "graphics-object",
+	"path":     "graphics-symbol",
+	"rect":     "graphics-symbol",
+	"circle":   "graphics-symbol",
+	"ellipse":  "graphics-symbol",
+	"line":     "graphics-symbol",
+	"polyline": "graphics-symbol",
+	"polygon":  "graphics-symbol",
+	"image":    "img",
+	"text":     "text",
+	"a":        "link",
+}
+
+// AccessibilityTree returns the accessibility tree of the document, rooted
+// at its <svg> element. Elements with aria-hidden="true", and those that
+// are not drawn, are left out with their contents. Groups and shapes are
+// only included if they have a name, a description or an explicit role;
+// the accessible elements inside those left out, and inside elements with
+// the role none or presentation, are given to their parent.
+func (doc *Document) AccessibilityTree() *AccessibleNode {
+	if doc.Root == nil {
+		return nil
+	}
+	root := doc.accessibleNode(doc.Root)
+	root.Children = doc.accessibleChildren(doc.Root)
+	return root
+}
+
+// accessibleChildren returns the accessible nodes inside e.
+func (doc *Document) accessibleChildren(e *Element) []*AccessibleNode {
+	var nodes []*AccessibleNode
+	for _, c := range e.Children {
+		if skippedElements[c.Name] || animationElements[c.Name] || c.Name == "defs" || c.Name == "symbol" {
+			continue
+		}
+		if strings.TrimSpace(c.Attr("aria-hidden")) == "true" || strings.TrimSpace(c.Style("display")) == "none" {
+			continue
+		}
+		n := doc.accessibleNode(c)
+		role := strings.TrimSpace(c.Attr("role"))
+		included := role != "none" && role != "presentation" && implicitRoles[c.Name] != "" &&
+			(role != "" || n.Name != "" || n.Description != "" || c.Name == "text" || c.Name == "a")
+		if !included {
+			nodes = append(nodes, doc.accessibleChildren(c)...)
+			continue
+		}
+		if c.Name != "text" {
+			// the text inside text elements is their name
+			n.Children = doc.accessibleChildren(c)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// accessibleNode returns the node of e without its children.
+func (doc *Document) accessibleNode(e *Element) *AccessibleNode {
+	n := &AccessibleNode{Element: e, Role: strings.TrimSpace(e.Attr("role"))}
+	if n.Role == "" {
+		n.Role = implicitRoles[e.Name]
+	}
+	n.Name = strings.TrimSpace(e.Attr("aria-label"))
+	if n.Name == "" {
+		n.Name = doc.textOfIDs(e.Attr("aria-labelledby"))
+	}
+	if n.Name == "" {
+		n.Name = childText(e, "title")
+	}
+	if n.Name == "" && (e.Name == "text" || e.Name == "a") {
+		n.Name = textContent(e)
+	}
+	n.Description = doc.textOfIDs(e.Attr("aria-describedby"))
+	if n.Description == "" {
+		n.Description = childText(e, "desc")
+	}
+	return n
+}
+
+// textOfIDs returns the text inside the elements with the space-separated
+// ids, joined by spaces.
+func (doc *Document) textOfIDs(ids string) string {
+	var texts []string
+	for _, id := range strings.Fields(ids) {
+		if e := doc.ElementByID(id); e != nil {
+			if t := textContent(e); t != "" {
+				texts = append(texts, t)
+			}
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// childText returns the text of the first child of e named name.
+func childText(e *Element, name string) string {
+	for _, c := range e.Children {
+		if c.Name == name {
+			return textContent(c)
+		}
+	}
+	return ""
+}
+
+// textContent returns the character data inside e, with runs of white
+// space collapsed to single spaces. <title> and <desc> are left out.
+func textContent(e *Element) string {
+	var b strings.Builder
+	var walk func(e *Element)
+	walk = func(e *Element) {
+		b.WriteString(e.Text)
+		for _, c := range e.Children {
+			if c.Name != "title" && c.Name != "desc" {
+				walk(c)
+			}
+			b.WriteString(c.Tail)
+		}
+	}
+	walk(e)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,766 @@
+package svgg
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// ErrInvalidAnimation is returned for SMIL animation elements whose timing
+// or values cannot be used. The animation is left out, as the spec asks.
+var ErrInvalidAnimation = errors.New("invalid animation")
+
+// animationElements are the SMIL animation elements evaluated by DrawAt.
+// They are never rendered themselves.
+var animationElements = map[string]bool{
+	"animate":          true,
+	"animateColor":     true,
+	"animateTransform": true,
+	"set":              true,
+}
+
+// colorProperties are the properties whose values are interpolated as
+// colors.
+var colorProperties = map[string]bool{
+	"fill":   true,
+	"stroke": true,
+	"color":  true,
+}
+
+// DrawAt draws the document as it stands t into its animations. The SMIL
+// <animate>, <animateColor>, <set> and <animateTransform> elements are
+// supported, with offset begin and end times; animations that begin on
+// events or other animations never start. CSS animations declared in
+// <style> elements or style attributes are applied too, for @keyframes
+// that animate transform, opacity or other presentation properties. Draw
+// ignores animations and draws the document as authored.
+func (doc *Document) DrawAt(dc *gg.Context, t time.Duration) error {
+	return doc.DrawWithOptions(dc, &RenderOptions{Animate: true, Time: t})
+}
+
+// animation is a parsed SMIL animation element.
+type animation struct {
+	e        *Element
+	attr     string // the animated attribute or property
+	property bool   // whether attr is a presentation property
+	typ      string // transform function of an animateTransform
+
+	begins, ends []float64 // offsets in seconds, sorted
+	dur          float64   // simple duration, +Inf if indefinite
+	repeatCount  float64   // NaN if unset, +Inf if indefinite
+	repeatDur    float64   // NaN if unset, +Inf if indefinite
+	freeze       bool
+
+	calcMode   string
+	values     []string // nil for a to-animation, which starts from the base value
+	to         string
+	keyTimes   []float64
+	keySplines [][4]float64
+	additive   bool
+	accumulate bool
+}
+
+// animations holds the animations of a document by target element: SMIL
+// animations in document order, and CSS animations.
+type animations struct {
+	smil    map[*Element][]*animation
+	css     map[*Element]*cssAnimations
+	viewBox ViewBox
+}
+
+// animations parses the animation elements and CSS animations of the
+// document. Invalid animation elements are passed to fail and left out; a
+// non-nil error from fail stops the parse.
+func (doc *Document) animations(fail func(e *Element, err error) error) (*animations, error) {
+	anims := &animations{
+		smil:    make(map[*Element][]*animation),
+		css:     doc.parseStylesheets().animations(doc),
+		viewBox: doc.ViewBox,
+	}
+	var walk func(parent, e *Element) error
+	walk = func(parent, e *Element) error {
+		if animationElements[e.Name] {
+			target := parent
+			if ref, ok := href(e); ok {
+				t, err := doc.lookupRef(ref)
+				if err != nil {
+					return fail(e, err)
+				}
+				target = t
+			}
+			a, err := parseAnimation(e)
+			if err != nil {
+				return fail(e, err)
+			}
+			anims.smil[target] = append(anims.smil[target], a)
+			return nil
+		}
+		for _, c := range e.Children {
+			if err := walk(e, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(nil, doc.Root)
+	return anims, err
+}
+
+// AnimationDuration returns how long the SMIL and CSS animations of the
+// document take to play through once: until the last of them ends, or for
+// animations that repeat forever, until they complete their first repeat,
+// or first pair of repeats if they alternate. It is zero for a document
+// without timed animations.
+func (doc *Document) AnimationDuration() time.Duration {
+	anims, _ := doc.animations(func(*Element, error) error { return nil })
+	end := 0.0
+	for _, ca := range anims.css {
+		for _, a := range ca.list {
+			active := a.dur * a.count
+			if math.IsInf(a.count, 1) {
+				active = a.dur
+				if strings.HasPrefix(a.direction, "alternate") {
+					active *= 2
+				}
+			}
+			end = math.Max(end, a.delay+active)
+		}
+	}
+	for _, list := range anims.smil {
+		for _, a := range list {
+			if len(a.begins) == 0 {
+				continue
+			}
+			begin := a.begins[0]
+			active := a.active(begin)
+			if math.IsInf(active, 1) {
+				active = a.dur
+			}
+			if !math.IsInf(active, 1) {
+				end = math.Max(end, begin+active)
+			}
+		}
+	}
+	return time.Duration(end * float64(time.Second))
+}
+
+// href returns the href or xlink:href attribute of e.
+func href(e *Element) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Name.Local == "href" {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// parseAnimation parses the timing and values of the animation element e.
+func parseAnimation(e *Element) (*animation, error) {
+	a := &animation{e: e, repeatCount: math.NaN(), repeatDur: math.NaN(), calcMode: "linear"}
+	a.attr = e.Attr("attributeName")
+	if i := strings.IndexByte(a.attr, ':'); i >= 0 {
+		a.attr = a.attr[i+1:]
+	}
+	if e.Name == "animateTransform" {
+		a.attr = "transform"
+		a.typ = e.Attr("type")
+		if a.typ == "" {
+			a.typ = "translate"
+		}
+		if transformArgs[a.typ] == 0 {
+			return nil, fmt.Errorf("%w: type %q", ErrInvalidAnimation, a.typ)
+		}
+	}
+	if a.attr == "" {
+		return nil, fmt.Errorf("%w: no attributeName", ErrInvalidAnimation)
+	}
+	a.property = styleProperties[a.attr]
+
+	var err error
+	if a.begins, err = parseTimes(e.Attr("begin"), "0"); err != nil {
+		return nil, err
+	}
+	if a.ends, err = parseTimes(e.Attr("end"), ""); err != nil {
+		return nil, err
+	}
+	a.dur = math.Inf(1)
+	if v := strings.TrimSpace(e.Attr("dur")); v != "" && v != "indefinite" && v != "media" {
+		if a.dur, err = parseClock(v); err != nil || a.dur <= 0 {
+			return nil, fmt.Errorf("%w: dur %q", ErrInvalidAnimation, v)
+		}
+	}
+	if v := strings.TrimSpace(e.Attr("repeatCount")); v == "indefinite" {
+		a.repeatCount = math.Inf(1)
+	} else if v != "" {
+		if a.repeatCount, err = strconv.ParseFloat(v, 64); err != nil || a.repeatCount <= 0 {
+			return nil, fmt.Errorf("%w: repeatCount %q", ErrInvalidAnimation, v)
+		}
+	}
+	if v := strings.TrimSpace(e.Attr("repeatDur")); v == "indefinite" {
+		a.repeatDur = math.Inf(1)
+	} else if v != "" {
+		if a.repeatDur, err = parseClock(v); err != nil {
+			return nil, fmt.Errorf("%w: repeatDur %q", ErrInvalidAnimation, v)
+		}
+	}
+	a.freeze = e.Attr("fill") == "freeze"
+	a.additive = e.Attr("additive") == "sum"
+	a.accumulate = e.Attr("accumulate") == "sum"
+	if v := e.Attr("calcMode"); v != "" {
+		a.calcMode = v
+	}
+
+	from, hasFrom := e.LookupAttr("from")
+	to, hasTo := e.LookupAttr("to")
+	by, hasBy := e.LookupAttr("by")
+	switch {
+	case e.Name == "set":
+		a.values = []string{to}
+		a.calcMode = "discrete"
+		a.additive, a.accumulate = false, false
+	case strings.TrimSpace(e.Attr("values")) != "":
+		for _, v := range strings.Split(strings.TrimSpace(e.Attr("values")), ";") {
+			if v = strings.TrimSpace(v); v != "" {
+				a.values = append(a.values, v)
+			}
+		}
+	case hasFrom && hasTo:
+		a.values = []string{from, to}
+	case hasFrom && hasBy:
+		sum, ok := a.parseValue(from).add(a.parseValue(by), 1)
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot add by=%q to from=%q", ErrInvalidAnimation, by, from)
+		}
+		a.values = []string{from, sum.String()}
+	case hasBy:
+		zero := a.parseValue(by)
+		for i := range zero.nums {
+			zero.nums[i] = 0
+		}
+		a.values = []string{zero.String(), by}
+		a.additive = true
+	case hasTo:
+		a.to = to
+		a.additive, a.accumulate = false, false
+	default:
+		return nil, fmt.Errorf("%w: no values", ErrInvalidAnimation)
+	}
+	n := len(a.values)
+	if n == 0 {
+		n = 2
+	}
+
+	if v := strings.TrimSpace(e.Attr("keyTimes")); v != "" && a.calcMode != "paced" {
+		for _, s := range strings.Split(v, ";") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: keyTimes %q", ErrInvalidAnimation, v)
+			}
+			a.keyTimes = append(a.keyTimes, f)
+		}
+		if len(a.keyTimes) != n || a.keyTimes[0] != 0 || (a.calcMode != "discrete" && a.keyTimes[n-1] != 1) || !sort.Float64sAreSorted(a.keyTimes) {
+			return nil, fmt.Errorf("%w: keyTimes %q", ErrInvalidAnimation, v)
+		}
+	}
+	if a.calcMode == "spline" {
+		v := strings.TrimSpace(e.Attr("keySplines"))
+		for _, s := range strings.Split(strings.TrimSuffix(v, ";"), ";") {
+			f, err := parseFloats(s)
+			if err != nil || len(f) != 4 {
+				return nil, fmt.Errorf("%w: keySplines %q", ErrInvalidAnimation, v)
+			}
+			a.keySplines = append(a.keySplines, [4]float64{f[0], f[1], f[2], f[3]})
+		}
+		if len(a.keySplines) != n-1 {
+			return nil, fmt.Errorf("%w: keySplines %q", ErrInvalidAnimation, v)
+		}
+	}
+	return a, nil
+}
+
+// parseTimes parses a begin or end list, keeping the offset values. Event,
+// syncbase and indefinite values are never resolved, so they are dropped.
+// An empty list is parsed as def.
+func parseTimes(s, def string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		s = def
+	}
+	var times []float64
+	for _, v := range strings.Split(s, ";") {
+		v = strings.TrimSpace(v)
+		if v == "" || v == "indefinite" {
+			continue
+		}
+		sign := 1.0
+		if v[0] == '+' || v[0] == '-' {
+			if v[0] == '-' {
+				sign = -1
+			}
+			v = strings.TrimSpace(v[1:])
+		}
+		t, err := parseClock(v)
+		if err != nil {
+			if v != "" && (isDigit(v[0]) || v[0] == '.') {
+				return nil, fmt.Errorf("%w: time %q", ErrInvalidAnimation, v)
+			}
+			// an event, syncbase or wallclock value
+			continue
+		}
+		times = append(times, sign*t)
+	}
+	sort.Float64s(times)
+	return times, nil
+}
+
+// parseClock parses a SMIL clock value, such as "2s", "150ms", "1.5min",
+// "0:01:30" or "01:30", into seconds.
+func parseClock(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+		}
+		t := 0.0
+		for _, p := range parts {
+			f, err := strconv.ParseFloat(p, 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+			}
+			t = t*60 + f
+		}
+		return t, nil
+	}
+	unit := 1.0
+	for _, u := range []struct {
+		suffix string
+		scale  float64
+	}{{"ms", 0.001}, {"min", 60}, {"h", 3600}, {"s", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSuffix(s, u.suffix), u.scale
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+	}
+	return f * unit, nil
+}
+
+// begin returns the start of the latest interval of the animation to have
+// begun by time t.
+func (a *animation) begin(t float64) (float64, bool) {
+	i := sort.SearchFloat64s(a.begins, t)
+	if i < len(a.begins) && a.begins[i] == t {
+		i++
+	}
+	if i == 0 {
+		return 0, false
+	}
+	return a.begins[i-1], true
+}
+
+// active returns the active duration of the interval that began at begin,
+// which is the simple duration repeated and cut short by the end times.
+func (a *animation) active(begin float64) float64 {
+	active := a.dur
+	if !math.IsNaN(a.repeatCount) || !math.IsNaN(a.repeatDur) {
+		active = math.Inf(1)
+		if !math.IsNaN(a.repeatCount) {
+			active = a.dur * a.repeatCount
+		}
+		if !math.IsNaN(a.repeatDur) {
+			active = math.Min(active, a.repeatDur)
+		}
+	}
+	if i := sort.SearchFloat64s(a.ends, begin); i < len(a.ends) {
+		active = math.Min(active, a.ends[i]-begin)
+	}
+	return active
+}
+
+// state returns the point in its simple duration, from 0 to 1, that the
+// animation has reached at time t into the interval that began at begin,
+// and the number of repeats it has made. ok is false if the animation has
+// no effect at t.
+func (a *animation) state(begin, t float64) (frac float64, repeat int, ok bool) {
+	active := a.active(begin)
+	elapsed := t - begin
+	if elapsed >= active {
+		if !a.freeze {
+			return 0, 0, false
+		}
+		elapsed = active
+	}
+	if math.IsInf(a.dur, 1) {
+		return 0, 0, true
+	}
+	n := math.Floor(elapsed / a.dur)
+	frac = elapsed/a.dur - n
+	if elapsed == active && frac == 0 && n > 0 {
+		// frozen at the end of a repeat
+		n, frac = n-1, 1
+	}
+	return frac, int(n), true
+}
+
+// value returns the value of the animation at the point frac of its simple
+// duration, given the base value it animates from.
+func (a *animation) value(base animValue, frac float64) animValue {
+	var values []animValue
+	if a.values == nil {
+		values = []animValue{base, a.parseValue(a.to)}
+	} else {
+		for _, v := range a.values {
+			values = append(values, a.parseValue(v))
+		}
+	}
+	n := len(values)
+	if n == 1 {
+		return values[0]
+	}
+	if a.calcMode == "discrete" {
+		i := int(frac * float64(n))
+		if a.keyTimes != nil {
+			i = sort.SearchFloat64s(a.keyTimes, frac)
+			if i == n || a.keyTimes[i] != frac {
+				i--
+			}
+		}
+		if i >= n {
+			i = n - 1
+		}
+		return values[i]
+	}
+
+	keyTimes := a.keyTimes
+	if a.calcMode == "paced" {
+		keyTimes = pacedTimes(values)
+	}
+	if keyTimes == nil {
+		keyTimes = make([]float64, n)
+		for i := range keyTimes {
+			keyTimes[i] = float64(i) / float64(n-1)
+		}
+	}
+	i := sort.SearchFloat64s(keyTimes, frac) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i > n-2 {
+		i = n - 2
+	}
+	span := keyTimes[i+1] - keyTimes[i]
+	if span <= 0 {
+		return values[i+1]
+	}
+	local := (frac - keyTimes[i]) / span
+	if a.keySplines != nil {
+		local = spline(a.keySplines[i], local)
+	}
+	return values[i].interpolate(values[i+1], local)
+}
+
+// pacedTimes returns key times that move through values at an even speed,
+// or nil if the values cannot be measured.
+func pacedTimes(values []animValue) []float64 {
+	times := make([]float64, len(values))
+	for i := 1; i < len(values); i++ {
+		d, ok := values[i-1].distance(values[i])
+		if !ok {
+			return nil
+		}
+		times[i] = times[i-1] + d
+	}
+	total := times[len(times)-1]
+	if total == 0 {
+		return nil
+	}
+	for i := range times {
+		times[i] /= total
+	}
+	return times
+}
+
+// spline eases x through the cubic Bézier from (0, 0) to (1, 1) with
+// control points k[0], k[1] and k[2], k[3], as keySplines do.
+func spline(k [4]float64, x float64) float64 {
+	bezier := func(p1, p2, s float64) float64 {
+		return 3*(1-s)*(1-s)*s*p1 + 3*(1-s)*s*s*p2 + s*s*s
+	}
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 50; i++ {
+		mid := (lo + hi) / 2
+		if bezier(k[0], k[2], mid) < x {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return bezier(k[1], k[3], (lo+hi)/2)
+}
+
+// apply returns e as it stands at time t under its animations, given the
+// style of its parent. CSS animations override SMIL ones. e itself is
+// returned if it is not animated.
+func (anims *animations) apply(e *Element, parent style, t float64) *Element {
+	list, css := anims.smil[e], anims.css[e]
+	if len(list) == 0 && css == nil {
+		return e
+	}
+	type active struct {
+		a      *animation
+		begin  float64
+		frac   float64
+		repeat int
+	}
+	var on []active
+	for _, a := range list {
+		begin, ok := a.begin(t)
+		if !ok {
+			continue
+		}
+		if frac, repeat, ok := a.state(begin, t); ok {
+			on = append(on, active{a, begin, frac, repeat})
+		}
+	}
+	// animations that began later take priority, then those later in the
+	// document
+	sort.SliceStable(on, func(i, j int) bool { return on[i].begin < on[j].begin })
+
+	c := *e
+	c.Attrs = append([]xml.Attr(nil), e.Attrs...)
+	for _, o := range on {
+		a := o.a
+		base := a.baseValue(&c, parent)
+		v := a.value(a.parseValue(base), o.frac)
+		if a.accumulate && o.repeat > 0 && a.values != nil {
+			last := a.parseValue(a.values[len(a.values)-1])
+			if sum, ok := v.add(last, float64(o.repeat)); ok {
+				v = sum
+			}
+		}
+		s := v.String()
+		if a.typ != "" {
+			s = a.typ + "(" + s + ")"
+			if a.additive && base != "" {
+				s = base + " " + s
+			}
+		} else if a.additive {
+			if sum, ok := a.parseValue(base).add(v, 1); ok {
+				s = sum.String()
+			}
+		}
+		if a.property {
+			c.SetStyle(a.attr, s)
+		} else {
+			c.SetAttr(a.attr, s)
+		}
+	}
+	if css != nil {
+		css.apply(&c, parent, t, anims.viewBox)
+	}
+	return &c
+}
+
+// baseValue returns the value of the animated attribute of e before the
+// animation applies, falling back to the inherited or initial value.
+func (a *animation) baseValue(e *Element, parent style) string {
+	if a.typ != "" {
+		return e.Attr("transform")
+	}
+	if !a.property {
+		if v, ok := e.LookupAttr(a.attr); ok {
+			return v
+		}
+		return "0"
+	}
+	if v, ok := properties(e)[a.attr]; ok && v != "inherit" {
+		return v
+	}
+	switch a.attr {
+	case "fill":
+		return paintString(parent.fillServer, parent.fill)
+	case "stroke":
+		return paintString(parent.strokeServer, parent.stroke)
+	case "fill-opacity":
+		return formatFloat(parent.fillOpacity)
+	case "stroke-opacity":
+		return formatFloat(parent.strokeOpacity)
+	case "stroke-width":
+		return formatFloat(parent.strokeWidth)
+	case "opacity":
+		return "1"
+	}
+	return ""
+}
+
+// paintString formats a paint server reference with its fallback color c,
+// or c alone if there is no server.
+func paintString(server string, c color.Color) string {
+	if server == "" {
+		return colorString(c)
+	}
+	if c == nil {
+		return "url(" + server + ")"
+	}
+	return "url(" + server + ") " + colorString(c)
+}
+
+// colorString formats c as an rgb() color, or "none" if it is nil.
+func colorString(c color.Color) string {
+	if c == nil {
+		return "none"
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("rgb(%d,%d,%d)", n.R, n.G, n.B)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// transformArgs is the number of arguments of each transform function that
+// animateTransform interpolates.
+var transformArgs = map[string]int{
+	"translate": 2,
+	"scale":     2,
+	"rotate":    3,
+	"skewX":     1,
+	"skewY":     1,
+}
+
+// animValue is an animation value split into its numbers and the text
+// around them, so that values of the same shape, such as lengths, lists
+// or path data with the same commands, can be interpolated number by
+// number. text has one more element than nums.
+type animValue struct {
+	nums []float64
+	text []string
+}
+
+// parseValue splits the animation value s. Colors are converted to rgb()
+// form, and the arguments of an animateTransform are filled out to their
+// full length.
+func (a *animation) parseValue(s string) animValue {
+	s = strings.TrimSpace(s)
+	if a.typ != "" {
+		f, err := parseFloats(s)
+		if err != nil {
+			return animValue{text: []string{s}}
+		}
+		n := transformArgs[a.typ]
+		if a.typ == "scale" && len(f) == 1 {
+			f = append(f, f[0])
+		}
+		for len(f) < n {
+			f = append(f, 0)
+		}
+		v := animValue{nums: f[:n], text: []string{""}}
+		for i := 0; i < n; i++ {
+			v.text = append(v.text, " ")
+		}
+		v.text[n] = ""
+		return v
+	}
+	if colorProperties[a.attr] {
+		if c, err := parseColor(s); err == nil && c != nil {
+			n := color.NRGBAModel.Convert(c).(color.NRGBA)
+			return animValue{nums: []float64{float64(n.R), float64(n.G), float64(n.B)}, text: []string{"rgb(", ",", ",", ")"}}
+		}
+		return animValue{text: []string{s}}
+	}
+	v := animValue{text: []string{""}}
+	for i := 0; i < len(s); {
+		if isNumberStart(s[i]) {
+			j := scanNumber(s, i)
+			if f, err := strconv.ParseFloat(s[i:j], 64); err == nil {
+				v.nums = append(v.nums, f)
+				v.text = append(v.text, "")
+				i = j
+				continue
+			}
+		}
+		v.text[len(v.text)-1] += s[i : i+1]
+		i++
+	}
+	return v
+}
+
+// String reassembles the value.
+func (v animValue) String() string {
+	var b strings.Builder
+	for i, t := range v.text {
+		if i > 0 {
+			if i > 1 && v.text[i-1] == "" {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatFloat(v.nums[i-1]))
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+// compatible reports whether v and w have the same shape.
+func (v animValue) compatible(w animValue) bool {
+	if len(v.nums) != len(w.nums) {
+		return false
+	}
+	for i := range v.text {
+		if strings.Trim(v.text[i], " ,\t\r\n") != strings.Trim(w.text[i], " ,\t\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// interpolate returns the value at t between v and w. Values of different
+// shapes switch from one to the other half way.
+func (v animValue) interpolate(w animValue, t float64) animValue {
+	if !v.compatible(w) {
+		if t < 0.5 {
+			return v
+		}
+		return w
+	}
+	r := animValue{nums: make([]float64, len(v.nums)), text: v.text}
+	for i := range v.nums {
+		r.nums[i] = v.nums[i] + (w.nums[i]-v.nums[i])*t
+	}
+	return r
+}
+
+// add returns v plus k times w, if they have the same shape.
+func (v animValue) add(w animValue, k float64) (animValue, bool) {
+	if !v.compatible(w) {
+		return v, false
+	}
+	r := animValue{nums: make([]float64, len(v.nums)), text: v.text}
+	for i := range v.nums {
+		r.nums[i] = v.nums[i] + w.nums[i]*k
+	}
+	return r, true
+}
+
+// distance returns the distance between the numbers of v and w, if they
+// have the same shape.
+func (v animValue) distance(w animValue) (float64, bool) {
+	if !v.compatible(w) || len(v.nums) == 0 {
+		return 0, false
+	}
+	d := 0.0
+	for i := range v.nums {
+		d += (w.nums[i] - v.nums[i]) * (w.nums[i] - v.nums[i])
+	}
+	return math.Sqrt(d), true
+}
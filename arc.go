@@ -0,0 +1,179 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// addArc draws the elliptical arc described by the seven absolute arc
+// command parameters in points, from the current point, as a sequence of
+// cubic Béziers. The conversion from endpoint to center parameterization
+// and the correction of out-of-range radii follow the implementation notes
+// of the SVG spec: a zero radius gives a straight line, negative radii are
+// made positive, and radii too small to reach the end point are scaled up
+// uniformly until they just do.
+func (p *Parser) addArc(points []float64) {
+	rx, ry, rot := math.Abs(points[0]), math.Abs(points[1]), points[2]
+	large, sweep := points[3] != 0, points[4] != 0
+	x1, y1 := p.placeX, p.placeY
+	x2, y2 := points[5], points[6]
+	p.placeX, p.placeY = x2, y2
+	if x1 == x2 && y1 == y2 {
+		// the arc is omitted entirely
+		return
+	}
+	if rx == 0 || ry == 0 {
+		p.drawLine(x2, y2)
+		return
+	}
+
+	phi := rot * math.Pi / 180
+	sinPhi, cosPhi := math.Sincos(phi)
+	dx2, dy2 := (x1-x2)/2, (y1-y2)/2
+	x1p := cosPhi*dx2 + sinPhi*dy2
+	y1p := -sinPhi*dx2 + cosPhi*dy2
+
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		rx *= math.Sqrt(lambda)
+		ry *= math.Sqrt(lambda)
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cxp := coef * rx * y1p / ry
+	cyp := coef * -ry * x1p / rx
+	cx := cosPhi*cxp - sinPhi*cyp + (x1+x2)/2
+	cy := sinPhi*cxp + cosPhi*cyp + (y1+y2)/2
+
+	theta1 := vectorAngle(1, 0, (x1p-cxp)/rx, (y1p-cyp)/ry)
+	dtheta := vectorAngle((x1p-cxp)/rx, (y1p-cyp)/ry, (-x1p-cxp)/rx, (-y1p-cyp)/ry)
+	if !sweep && dtheta > 0 {
+		dtheta -= 2 * math.Pi
+	} else if sweep && dtheta < 0 {
+		dtheta += 2 * math.Pi
+	}
+
+	arc := Arc{Cx: cx, Cy: cy, Rx: rx, Ry: ry, Phi: phi, Theta1: theta1, DTheta: dtheta}
+	if p.tol > 0 {
+		p.lastX, p.lastY = x1, y1
+		p.flatten(Segment{Op: ArcOp, P: [3]gg.Point{{X: x2, Y: y2}}, Arc: arc})
+		return
+	}
+	if as, ok := p.sink.(ArcSink); ok {
+		as.ArcTo(arc, x2, y2)
+		p.chunk(x2, y2)
+		return
+	}
+	arc.cubics(x2, y2, 0, p.cubicTo)
+}
+
+// Arc is an elliptical arc in center parameterization: the part of the
+// ellipse centered at Cx, Cy with radii Rx and Ry, rotated by Phi radians,
+// that runs from angle Theta1 through DTheta.
+type Arc struct {
+	Cx, Cy, Rx, Ry float64
+	Phi            float64
+	Theta1, DTheta float64
+}
+
+// Point returns the point of the ellipse at angle theta.
+func (a Arc) Point(theta float64) (x, y float64) {
+	sinPhi, cosPhi := math.Sincos(a.Phi)
+	s, c := math.Sincos(theta)
+	return a.Cx + a.Rx*cosPhi*c - a.Ry*sinPhi*s, a.Cy + a.Rx*sinPhi*c + a.Ry*cosPhi*s
+}
+
+// Transform returns the arc mapped by the affine transform m, which is
+// again an elliptical arc. ok is false if m is singular and flattens the
+// arc onto a line.
+func (a Arc) Transform(m gg.Matrix) (t Arc, ok bool) {
+	// the linear part of m times the rotation and scaling of the ellipse
+	sinPhi, cosPhi := math.Sincos(a.Phi)
+	xx, xy := (m.XX*cosPhi+m.XY*sinPhi)*a.Rx, (m.XY*cosPhi-m.XX*sinPhi)*a.Ry
+	yx, yy := (m.YX*cosPhi+m.YY*sinPhi)*a.Rx, (m.YY*cosPhi-m.YX*sinPhi)*a.Ry
+	det := xx*yy - xy*yx
+	if det == 0 {
+		return Arc{}, false
+	}
+	t.Theta1, t.DTheta = a.Theta1, a.DTheta
+	if det < 0 {
+		// a mirror image: run the other way around a flipped ellipse
+		xy, yy = -xy, -yy
+		t.Theta1, t.DTheta = -t.Theta1, -t.DTheta
+	}
+	// split the matrix into a rotation, a scaling and a rotation
+	e, f := (xx+yy)/2, (xx-yy)/2
+	g, h := (yx+xy)/2, (yx-xy)/2
+	q, r := math.Hypot(e, h), math.Hypot(f, g)
+	a1, a2 := math.Atan2(g, f), math.Atan2(h, e)
+	t.Cx, t.Cy = m.TransformPoint(a.Cx, a.Cy)
+	t.Rx, t.Ry = q+r, q-r
+	t.Phi = (a2 + a1) / 2
+	t.Theta1 += (a2 - a1) / 2
+	return t, true
+}
+
+// endpointParams returns the radii, x-axis rotation in degrees and flags
+// of the arc command that draws a.
+func (a Arc) endpointParams() (rx, ry, rot, large, sweep float64) {
+	if math.Abs(a.DTheta) > math.Pi {
+		large = 1
+	}
+	if a.DTheta > 0 {
+		sweep = 1
+	}
+	return a.Rx, a.Ry, a.Phi * 180 / math.Pi, large, sweep
+}
+
+// cubics approximates the arc by cubic Béziers of at most 90 degrees each,
+// passing them to cubicTo. If tol is positive, the arc is split further
+// until the curves stay within tol of it. The last one ends exactly at x, y.
+func (a Arc) cubics(x, y, tol float64, cubicTo func(x1, y1, x2, y2, x, y float64)) {
+	n := int(math.Ceil(math.Abs(a.DTheta) / (math.Pi / 2)))
+	if n == 0 {
+		return
+	}
+	if r := math.Max(a.Rx, a.Ry); tol > 0 && tol < r {
+		// the error of a cubic spanning angle d of a unit circle is at most
+		// 2 sin⁶(d/4) / (27 cos²(d/4)); an ellipse is a scaled circle
+		const maxSteps = 1 << 16
+		for ; n < maxSteps; n++ {
+			s, c := math.Sincos(math.Abs(a.DTheta) / float64(n) / 4)
+			if r*2*math.Pow(s, 6)/(27*c*c) <= tol {
+				break
+			}
+		}
+	}
+	delta := a.DTheta / float64(n)
+	t := 4.0 / 3.0 * math.Tan(delta/4)
+	sinPhi, cosPhi := math.Sincos(a.Phi)
+	// point maps a point on the unit circle onto the ellipse
+	point := func(ux, uy float64) (float64, float64) {
+		return a.Cx + a.Rx*cosPhi*ux - a.Ry*sinPhi*uy, a.Cy + a.Rx*sinPhi*ux + a.Ry*cosPhi*uy
+	}
+	a1 := a.Theta1
+	for i := 0; i < n; i++ {
+		a2 := a1 + delta
+		s1, c1 := math.Sincos(a1)
+		s2, c2 := math.Sincos(a2)
+		c1x, c1y := point(c1-t*s1, s1+t*c1)
+		c2x, c2y := point(c2+t*s2, s2-t*c2)
+		ex, ey := point(c2, s2)
+		if i == n-1 {
+			// land exactly on the requested end point
+			ex, ey = x, y
+		}
+		cubicTo(c1x, c1y, c2x, c2y, ex, ey)
+		a1 = a2
+	}
+}
+
+// vectorAngle returns the signed angle from vector (ux, uy) to (vx, vy).
+func vectorAngle(ux, uy, vx, vy float64) float64 {
+	return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+}
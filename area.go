@@ -0,0 +1,141 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Subpaths returns the subpaths of the path, each as a path of its own
+// starting with a move. Subpaths that are only a move are left out.
+func (c *CompiledPath) Subpaths() []*CompiledPath {
+	var subs []*CompiledPath
+	var cur *CompiledPath
+	end := func() {
+		if cur != nil && len(cur.Segments) > 1 {
+			subs = append(subs, cur)
+		}
+		cur = nil
+	}
+	c.forEach(func(from gg.Point, s Segment) {
+		if s.Op == MoveOp {
+			end()
+			cur = &CompiledPath{}
+			cur.MoveTo(s.P[0].X, s.P[0].Y)
+			return
+		}
+		if cur == nil {
+			cur = &CompiledPath{}
+			cur.MoveTo(from.X, from.Y)
+		}
+		cur.Segments = append(cur.Segments, s)
+		if s.Op == CloseOp {
+			end()
+		}
+	})
+	end()
+	return subs
+}
+
+// WindingNumber returns the number of times the path winds around the
+// point x, y, counting clockwise turns as drawn on screen, where y points
+// down, as positive. Open subpaths are closed, as they are when filling.
+func (c *CompiledPath) WindingNumber(x, y float64) int {
+	w := 0
+	for _, sub := range c.subpaths(c.hitTolerance()) {
+		w += polygonWinding(sub.pts, gg.Point{X: x, Y: y})
+	}
+	return w
+}
+
+// polygonWinding returns the winding number of the closed polygon pts
+// around p, counting clockwise turns as drawn on screen as positive.
+func polygonWinding(pts []gg.Point, p gg.Point) int {
+	w := 0
+	for i, a := range pts {
+		b := pts[(i+1)%len(pts)]
+		cross := (b.X-a.X)*(p.Y-a.Y) - (p.X-a.X)*(b.Y-a.Y)
+		switch {
+		case a.Y <= p.Y && b.Y > p.Y && cross > 0:
+			w++
+		case a.Y > p.Y && b.Y <= p.Y && cross < 0:
+			w--
+		}
+	}
+	return w
+}
+
+// Area returns the signed area enclosed by the path, with open subpaths
+// closed. Subpaths winding clockwise as drawn on screen, where y points
+// down, count as positive and those winding the other way as negative, so
+// a hole drawn against the direction of its outline is subtracted. Curves
+// and arcs are measured exactly rather than flattened.
+func (c *CompiledPath) Area() float64 {
+	a, _, _ := c.moments()
+	return a
+}
+
+// Clockwise reports whether the path winds clockwise as drawn on screen,
+// where y points down, that is whether its Area is positive.
+func (c *CompiledPath) Clockwise() bool {
+	return c.Area() > 0
+}
+
+// Centroid returns the centre of mass of the area enclosed by the path,
+// weighting subpaths by their signed Area. For a path enclosing no area it
+// returns the centre of the bounding box.
+func (c *CompiledPath) Centroid() gg.Point {
+	a, mx, my := c.moments()
+	if a == 0 {
+		b := &boundsSink{}
+		c.Draw(b)
+		return gg.Point{X: (b.x0 + b.x1) / 2, Y: (b.y0 + b.y1) / 2}
+	}
+	return gg.Point{X: mx / (2 * a), Y: -my / (2 * a)}
+}
+
+// moments returns the signed area of the path and the integrals of x²dy
+// and y²dx around it, from which Green's theorem gives its centroid.
+func (c *CompiledPath) moments() (a, mx, my float64) {
+	add := func(from gg.Point, s Segment) {
+		a += lineIntegral(from, s, func(p, d gg.Point) float64 { return (p.X*d.Y - p.Y*d.X) / 2 })
+		mx += lineIntegral(from, s, func(p, d gg.Point) float64 { return p.X * p.X * d.Y })
+		my += lineIntegral(from, s, func(p, d gg.Point) float64 { return p.Y * p.Y * d.X })
+	}
+	var start, cur gg.Point
+	closeSubpath := func() {
+		if cur != start {
+			add(cur, Segment{Op: LineOp, P: [3]gg.Point{start}})
+		}
+	}
+	for _, s := range c.Segments {
+		if s.Op == MoveOp {
+			closeSubpath()
+			start, cur = s.P[0], s.P[0]
+			continue
+		}
+		add(cur, s)
+		cur = s.End()
+	}
+	closeSubpath()
+	return a, mx, my
+}
+
+// lineIntegral integrates f, given the point and derivative of segment s
+// starting at from, over the segment. Gauss-Legendre quadrature is exact
+// for the polynomials lines and Béziers give here; arcs are split into
+// eighths of a turn, on which it is accurate to rounding error.
+func lineIntegral(from gg.Point, s Segment, f func(p, d gg.Point) float64) float64 {
+	g := func(t float64) float64 {
+		return f(pointOn(from, s, t), derivative(from, s, t))
+	}
+	if s.Op != ArcOp {
+		return gaussLegendre(g, 0, 1)
+	}
+	n := math.Ceil(math.Abs(s.Arc.DTheta) / (math.Pi / 4))
+	sum := 0.0
+	for i := 0.0; i < n; i++ {
+		sum += gaussLegendre(g, i/n, (i+1)/n)
+	}
+	return sum
+}
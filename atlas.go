@@ -0,0 +1,157 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// DefaultAtlasWidth is the width an atlas is packed into when
+// AtlasOptions.MaxWidth is zero.
+const DefaultAtlasWidth = 2048
+
+// Sprite is a document to be packed into an atlas.
+type Sprite struct {
+	Name string
+	Doc  *Document
+
+	// Width and Height are the size of the sprite in pixels. If both are
+	// zero, the document's size is used; if one is, it follows the
+	// document's aspect ratio.
+	Width, Height int
+}
+
+// AtlasOptions configures PackSprites. The zero value packs sprites
+// without padding into an atlas DefaultAtlasWidth pixels wide.
+type AtlasOptions struct {
+	// MaxWidth is the width sprites are packed into. The atlas is wider
+	// only if a sprite is.
+	MaxWidth int
+
+	// Padding is the number of transparent pixels kept between sprites,
+	// so that filtering a sprite does not bleed in its neighbors.
+	Padding int
+
+	// PowerOfTwo rounds the atlas's width and height up to powers of two,
+	// as some GPUs require of textures.
+	PowerOfTwo bool
+
+	// Options configures how each document is drawn. It may be nil.
+	Options *RenderOptions
+}
+
+// Atlas is a set of sprites drawn into a single image.
+type Atlas struct {
+	Image *image.RGBA
+
+	// Rects holds the rectangle of each sprite in Image, in the order the
+	// sprites were given.
+	Rects []image.Rectangle
+
+	// Names maps the names of the sprites to their index in Rects.
+	Names map[string]int
+}
+
+// PackSprites draws the sprites into a single atlas image, for texture
+// atlases and CSS sprite sheets built from vector sources. Sprites are
+// packed in rows, tallest first. Each is drawn on its own, so content
+// overflowing a document's bounds does not spill into its neighbors.
+func PackSprites(sprites []Sprite, opts *AtlasOptions) (*Atlas, error) {
+	var o AtlasOptions
+	if opts != nil {
+		o = *opts
+	}
+	maxWidth := o.MaxWidth
+	if maxWidth <= 0 {
+		maxWidth = DefaultAtlasWidth
+	}
+	sizes := make([]image.Point, len(sprites))
+	for i, s := range sprites {
+		w, h, err := spriteSize(s)
+		if err != nil {
+			return nil, err
+		}
+		sizes[i] = image.Pt(w, h)
+		if w > maxWidth {
+			maxWidth = w
+		}
+	}
+
+	// shelf packing: sprites are placed left to right in rows as tall as
+	// their first, tallest sprite
+	order := make([]int, len(sprites))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return sizes[order[a]].Y > sizes[order[b]].Y
+	})
+	rects := make([]image.Rectangle, len(sprites))
+	var x, y, rowHeight, width int
+	for _, i := range order {
+		sz := sizes[i]
+		if x > 0 && x+sz.X > maxWidth {
+			x, y, rowHeight = 0, y+rowHeight+o.Padding, 0
+		}
+		rects[i] = image.Rect(x, y, x+sz.X, y+sz.Y)
+		x += sz.X + o.Padding
+		if sz.Y > rowHeight {
+			rowHeight = sz.Y
+		}
+		if rects[i].Max.X > width {
+			width = rects[i].Max.X
+		}
+	}
+	height := y + rowHeight
+	if o.PowerOfTwo {
+		width, height = nextPowerOfTwo(width), nextPowerOfTwo(height)
+	}
+
+	a := &Atlas{
+		Image: image.NewRGBA(image.Rect(0, 0, width, height)),
+		Rects: rects,
+		Names: make(map[string]int, len(sprites)),
+	}
+	for i, s := range sprites {
+		if s.Name != "" {
+			a.Names[s.Name] = i
+		}
+		r := rects[i]
+		im := NewImage(s.Doc, r.Dx(), r.Dy(), o.Options)
+		if err := im.Err(); err != nil {
+			return nil, fmt.Errorf("svgg: sprite %q: %w", s.Name, err)
+		}
+		draw.Draw(a.Image, r, im.Image(), image.Point{}, draw.Src)
+	}
+	return a, nil
+}
+
+// spriteSize returns the size in pixels of the sprite s.
+func spriteSize(s Sprite) (int, int, error) {
+	dw, dh := s.Doc.Width, s.Doc.Height
+	w, h := s.Width, s.Height
+	switch {
+	case w > 0 && h > 0:
+	case w > 0 && dw > 0:
+		h = int(math.Round(float64(w) * dh / dw))
+	case h > 0 && dh > 0:
+		w = int(math.Round(float64(h) * dw / dh))
+	default:
+		w, h = int(math.Ceil(dw-1e-9)), int(math.Ceil(dh-1e-9))
+	}
+	if w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("svgg: sprite %q has an empty size", s.Name)
+	}
+	return w, h, nil
+}
+
+// nextPowerOfTwo returns the least power of two that is at least n.
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,112 @@
+package svgg
+
+import "sort"
+
+// Fidelity describes how the renderer treats an audited feature.
+type Fidelity uint8
+
+const (
+	// Ignored features are skipped entirely when drawing.
+	Ignored Fidelity = iota
+	// Approximated features are drawn, but not exactly as the spec requires.
+	Approximated
+)
+
+func (f Fidelity) String() string {
+	if f == Approximated {
+		return "approximated"
+	}
+	return "ignored"
+}
+
+// AuditItem is a single feature found by Audit.
+type AuditItem struct {
+	Kind     string // "element" or "attribute"
+	Name     string // element or attribute name
+	Element  string // element the attribute was found on
+	Fidelity Fidelity
+	Count    int // number of occurrences in the document
+}
+
+// AuditReport lists the features of a document that the renderer would
+// ignore or approximate.
+type AuditReport struct {
+	Items []AuditItem
+}
+
+// Empty reports whether the document is expected to render faithfully.
+func (r *AuditReport) Empty() bool {
+	return len(r.Items) == 0
+}
+
+// Audit walks the document without drawing it and reports the elements
+// and attributes that Draw would ignore or approximate. Every path command
+// is drawn, so path data is not audited.
+func (doc *Document) Audit() *AuditReport {
+	counts := make(map[AuditItem]int)
+	var walk func(e *Element)
+	walk = func(e *Element) {
+		if skippedElements[e.Name] || animationElements[e.Name] {
+			switch e.Name {
+			case "pattern", "clipPath", "mask", "marker":
+				// their content is drawn where they are referenced
+				for _, c := range e.Children {
+					walk(c)
+				}
+			}
+			return
+		}
+		if _, ok := elementAttrs[e.Name]; !ok && e.Name != "defs" {
+			counts[AuditItem{Kind: "element", Name: e.Name, Fidelity: Ignored}]++
+			return
+		}
+		for _, a := range e.Attrs {
+			if !attrSupported(e.Name, a.Name.Space, a.Name.Local) {
+				counts[AuditItem{Kind: "attribute", Name: a.Name.Local, Element: e.Name, Fidelity: Ignored}]++
+			}
+		}
+		props := properties(e)
+		if _, ok := props["opacity"]; ok && len(e.Children) > 0 {
+			counts[AuditItem{Kind: "attribute", Name: "opacity", Element: e.Name, Fidelity: Approximated}]++
+		}
+		if _, ok := props["stroke-miterlimit"]; ok {
+			// gg has no mitered joins, so the limit has no effect
+			counts[AuditItem{Kind: "attribute", Name: "stroke-miterlimit", Element: e.Name, Fidelity: Approximated}]++
+		}
+		for _, c := range e.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+
+	report := &AuditReport{}
+	for item, n := range counts {
+		item.Count = n
+		report.Items = append(report.Items, item)
+	}
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Element < b.Element
+	})
+	return report
+}
+
+// attrSupported reports whether the attribute is read when drawing the
+// element name.
+func attrSupported(name, space, local string) bool {
+	if space == "xmlns" || local == "xmlns" || styleProperties[local] || coreAttrs[local] {
+		return true
+	}
+	for _, a := range elementAttrs[name] {
+		if a == local {
+			return true
+		}
+	}
+	return false
+}
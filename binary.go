@@ -0,0 +1,425 @@
+package svgg
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// ErrInvalidBinary is returned when binary data given to UnmarshalBinary
+// is corrupt, truncated or of an unknown version.
+var ErrInvalidBinary = errors.New("invalid binary data")
+
+// The binary forms start with a magic string and a version, which changes
+// whenever the layout does.
+const (
+	binaryDocumentMagic = "svgg"
+	binaryPathMagic     = "svgp"
+	binaryVersion       = 4
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. It writes the element
+// tree of the document with its size, viewBox, ErrorMode and Limits, and
+// the compiled path data of its <path> elements, in a compact form that
+// UnmarshalBinary reads back much faster than the SVG can be parsed, so
+// asset pipelines can decode SVG files at build time and skip both the
+// XML and the path data at runtime. Path data with errors is left to be
+// parsed when drawn. The Resolver, Fonts, ErrorHandler, Warnings and
+// class styles are not written.
+func (doc *Document) MarshalBinary() ([]byte, error) {
+	if doc.Root == nil {
+		return nil, ErrNoSVGElement
+	}
+	w := &binaryWriter{strings: make(map[string]int)}
+	w.collect(doc.Root)
+	w.b = append(w.b, binaryDocumentMagic...)
+	w.b = append(w.b, binaryVersion)
+	for _, f := range []float64{doc.Width, doc.Height, doc.ViewBox.X, doc.ViewBox.Y, doc.ViewBox.W, doc.ViewBox.H} {
+		w.float(f)
+	}
+	w.uint(uint64(doc.ErrorMode))
+	l := doc.Limits
+	for _, n := range []int{l.MaxElements, l.MaxDepth, l.MaxPathPoints, l.MaxUseDepth} {
+		w.uint(uint64(n))
+	}
+	w.float(l.MaxCanvas)
+	w.uint(uint64(l.MaxUseInstances))
+	w.uint(uint64(l.MaxImagePixels))
+	w.uint(uint64(len(w.table)))
+	for _, s := range w.table {
+		w.uint(uint64(len(s)))
+		w.b = append(w.b, s...)
+	}
+	w.element(doc.Root)
+	return w.b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the
+// document with one written by MarshalBinary, whose <path> elements have
+// their Compiled paths set. The Resolver, Fonts and ErrorHandler of doc
+// are kept. The element tree is bounded by the Limits of doc if any are
+// set, as they are for untrusted data, and by those written otherwise.
+func (doc *Document) UnmarshalBinary(data []byte) error {
+	r := &binaryReader{b: data}
+	if err := r.magic(binaryDocumentMagic); err != nil {
+		return fmt.Errorf("svgg: %w: %v", ErrInvalidBinary, err)
+	}
+	var f [6]float64
+	for i := range f {
+		f[i] = r.float()
+	}
+	mode := ErrorMode(r.uint())
+	var l Limits
+	l.MaxElements = r.int()
+	l.MaxDepth = r.int()
+	l.MaxPathPoints = r.int()
+	l.MaxUseDepth = r.int()
+	l.MaxCanvas = r.float()
+	l.MaxUseInstances = r.int()
+	l.MaxImagePixels = r.int()
+	r.table = make([]string, r.count(1))
+	for i := range r.table {
+		r.table[i] = r.bytes(r.count(1))
+	}
+	if doc.Limits != (Limits{}) {
+		l = doc.Limits
+	}
+	root := r.tree(&limitCounter{Limits: l})
+	if r.err == nil && len(r.b) > 0 {
+		r.err = errors.New("trailing data")
+	}
+	if errors.Is(r.err, ErrLimitExceeded) {
+		return r.err
+	}
+	if r.err != nil {
+		return fmt.Errorf("svgg: %w: %v", ErrInvalidBinary, r.err)
+	}
+	*doc = Document{
+		Root:         root,
+		Width:        f[0],
+		Height:       f[1],
+		ViewBox:      ViewBox{f[2], f[3], f[4], f[5]},
+		ErrorMode:    mode,
+		ErrorHandler: doc.ErrorHandler,
+		Resolver:     doc.Resolver,
+		Fonts:        doc.Fonts,
+		Limits:       l,
+	}
+	doc.indexIDs()
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, writing the segments
+// of the path exactly in a compact form.
+func (c *CompiledPath) MarshalBinary() ([]byte, error) {
+	w := &binaryWriter{}
+	w.b = append(w.b, binaryPathMagic...)
+	w.b = append(w.b, binaryVersion)
+	w.path(c)
+	return w.b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, replacing the
+// path with one written by MarshalBinary.
+func (c *CompiledPath) UnmarshalBinary(data []byte) error {
+	r := &binaryReader{b: data}
+	if err := r.magic(binaryPathMagic); err != nil {
+		return fmt.Errorf("svgg: %w: %v", ErrInvalidBinary, err)
+	}
+	out := r.path()
+	if r.err == nil && len(r.b) > 0 {
+		r.err = errors.New("trailing data")
+	}
+	if r.err != nil {
+		return fmt.Errorf("svgg: %w: %v", ErrInvalidBinary, r.err)
+	}
+	*c = *out
+	return nil
+}
+
+// segmentPoints returns the number of points of a segment with op that
+// are meaningful.
+func segmentPoints(op SegmentOp) int {
+	switch op {
+	case QuadOp:
+		return 2
+	case CubicOp:
+		return 3
+	}
+	return 1
+}
+
+// binaryWriter appends the binary forms to b. Strings are written once to
+// a table and referred to by their index.
+type binaryWriter struct {
+	b       []byte
+	table   []string
+	strings map[string]int
+}
+
+func (w *binaryWriter) uint(n uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.b = append(w.b, buf[:binary.PutUvarint(buf[:], n)]...)
+}
+
+func (w *binaryWriter) float(f float64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+	w.b = append(w.b, buf[:]...)
+}
+
+// collect adds the strings of the tree under e to the table.
+func (w *binaryWriter) collect(e *Element) {
+	add := func(s string) {
+		if _, ok := w.strings[s]; !ok {
+			w.strings[s] = len(w.table)
+			w.table = append(w.table, s)
+		}
+	}
+	add(e.Name)
+	add(e.Space)
+	for _, a := range e.Attrs {
+		add(a.Name.Space)
+		add(a.Name.Local)
+		add(a.Value)
+	}
+	add(e.Text)
+	add(e.Tail)
+	for _, c := range e.Children {
+		w.collect(c)
+	}
+}
+
+// element writes e and its children, by the indices of their strings,
+// with the compiled path of a <path>.
+func (w *binaryWriter) element(e *Element) {
+	str := func(s string) { w.uint(uint64(w.strings[s])) }
+	str(e.Name)
+	str(e.Space)
+	w.uint(uint64(len(e.Attrs)))
+	for _, a := range e.Attrs {
+		str(a.Name.Space)
+		str(a.Name.Local)
+		str(a.Value)
+	}
+	str(e.Text)
+	str(e.Tail)
+	c := e.Compiled
+	if c == nil && e.Name == "path" {
+		c = compileClean(e.Attr("d"))
+	}
+	if c == nil {
+		w.b = append(w.b, 0)
+	} else {
+		w.b = append(w.b, 1)
+		w.path(c)
+	}
+	w.uint(uint64(len(e.Children)))
+	for _, c := range e.Children {
+		w.element(c)
+	}
+}
+
+// path writes the segments of c.
+func (w *binaryWriter) path(c *CompiledPath) {
+	w.uint(uint64(len(c.Segments)))
+	for _, s := range c.Segments {
+		w.b = append(w.b, byte(s.Op))
+		for _, p := range s.P[:segmentPoints(s.Op)] {
+			w.float(p.X)
+			w.float(p.Y)
+		}
+		if s.Op == ArcOp {
+			a := s.Arc
+			for _, f := range []float64{a.Cx, a.Cy, a.Rx, a.Ry, a.Phi, a.Theta1, a.DTheta} {
+				w.float(f)
+			}
+		}
+	}
+}
+
+// binaryReader reads the binary forms from b. The first error is kept in
+// err, after which every read returns zero.
+type binaryReader struct {
+	b     []byte
+	table []string
+	err   error
+}
+
+func (r *binaryReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+	r.b = nil
+}
+
+// magic reads the magic string and version of a binary form, failing if
+// they are not magic and the current version.
+func (r *binaryReader) magic(magic string) error {
+	if len(r.b) < len(magic)+1 || string(r.b[:len(magic)]) != magic {
+		return errors.New("missing magic " + magic)
+	}
+	if v := r.b[len(magic)]; v != binaryVersion {
+		return fmt.Errorf("version %d, not %d", v, binaryVersion)
+	}
+	r.b = r.b[len(magic)+1:]
+	return nil
+}
+
+func (r *binaryReader) byte() byte {
+	if len(r.b) < 1 {
+		r.fail("unexpected end of data")
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *binaryReader) uint() uint64 {
+	n, k := binary.Uvarint(r.b)
+	if k <= 0 {
+		r.fail("bad varint")
+		return 0
+	}
+	r.b = r.b[k:]
+	return n
+}
+
+func (r *binaryReader) int() int {
+	n := r.uint()
+	if n > math.MaxInt32 {
+		r.fail("value %d out of range", n)
+		return 0
+	}
+	return int(n)
+}
+
+// count reads the length of a list whose items take at least size bytes
+// each, failing if the rest of the data cannot hold them, so that corrupt
+// data cannot make the reader allocate too much.
+func (r *binaryReader) count(size int) int {
+	n := r.uint()
+	if n > uint64(len(r.b)/size) {
+		r.fail("count %d exceeds the data", n)
+		return 0
+	}
+	return int(n)
+}
+
+func (r *binaryReader) float() float64 {
+	if len(r.b) < 8 {
+		r.fail("unexpected end of data")
+		return 0
+	}
+	f := math.Float64frombits(binary.LittleEndian.Uint64(r.b))
+	r.b = r.b[8:]
+	return f
+}
+
+func (r *binaryReader) bytes(n int) string {
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+// str reads the index of a string in the table.
+func (r *binaryReader) str() string {
+	i := r.uint()
+	if i >= uint64(len(r.table)) {
+		r.fail("string index %d out of range", i)
+		return ""
+	}
+	return r.table[i]
+}
+
+// tree reads an element tree, counting its elements against the limits
+// of lc. It keeps its own stack rather than recursing, so that deeply
+// nested data cannot exhaust the goroutine's.
+func (r *binaryReader) tree(lc *limitCounter) *Element {
+	type open struct {
+		e    *Element
+		left int // children still to read
+	}
+	root, n := r.element()
+	if err := lc.addElement(root, 1); err != nil {
+		r.fail("%w", err)
+		return nil
+	}
+	stack := []open{{root, n}}
+	for len(stack) > 0 && r.err == nil {
+		top := &stack[len(stack)-1]
+		if top.left == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		top.left--
+		e, n := r.element()
+		if err := lc.addElement(e, len(stack)+1); err != nil {
+			r.fail("%w", err)
+			break
+		}
+		top.e.Children = append(top.e.Children, e)
+		if n > 0 {
+			stack = append(stack, open{e, n})
+		}
+	}
+	return root
+}
+
+// element reads an element without its children, returning it with the
+// number of children that follow.
+func (r *binaryReader) element() (*Element, int) {
+	e := &Element{Name: r.str(), Space: r.str()}
+	// every attribute takes at least 3 bytes, and every child 7
+	if n := r.count(3); n > 0 {
+		e.Attrs = make([]xml.Attr, n)
+		for i := range e.Attrs {
+			e.Attrs[i].Name.Space = r.str()
+			e.Attrs[i].Name.Local = r.str()
+			e.Attrs[i].Value = r.str()
+		}
+	}
+	e.Text = r.str()
+	e.Tail = r.str()
+	switch r.byte() {
+	case 0:
+	case 1:
+		e.Compiled = r.path()
+	default:
+		r.fail("bad compiled path flag")
+	}
+	n := r.count(7)
+	if n > 0 {
+		e.Children = make([]*Element, 0, n)
+	}
+	return e, n
+}
+
+// path reads the segments of a compiled path.
+func (r *binaryReader) path() *CompiledPath {
+	// every segment takes at least 17 bytes
+	c := &CompiledPath{Segments: make([]Segment, 0, r.count(17))}
+	for i := cap(c.Segments); i > 0 && r.err == nil; i-- {
+		s := Segment{Op: SegmentOp(r.byte())}
+		if s.Op > CloseOp {
+			r.fail("unknown segment op %d", s.Op)
+			break
+		}
+		for j := 0; j < segmentPoints(s.Op); j++ {
+			s.P[j] = gg.Point{X: r.float(), Y: r.float()}
+		}
+		if s.Op == ArcOp {
+			s.Arc = Arc{r.float(), r.float(), r.float(), r.float(), r.float(), r.float(), r.float()}
+		}
+		if s.Op == MoveOp {
+			c.start = s.P[0]
+		}
+		c.Segments = append(c.Segments, s)
+	}
+	return c
+}
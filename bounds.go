@@ -0,0 +1,337 @@
+package svgg
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// PathBounds returns the tight bounding box of the path data d, without
+// drawing it. Curves and arcs are bounded by their extrema rather than by
+// their control points. An empty path has an all-zero box.
+func PathBounds(d string) (x0, y0, x1, y1 float64, err error) {
+	b := &boundsSink{}
+	p := NewSinkParser(b)
+	if err := p.CompilePath(d); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	return b.x0, b.y0, b.x1, b.y1, nil
+}
+
+// ViewBounds returns the union of the bounding boxes of all the elements
+// Draw would render, after their transforms, in the user space of the root
+// element. Strokes are not included. Setting the ViewBox to these bounds
+// crops a document whose width, height and viewBox attributes are missing
+// or meaningless.
+//
+// Elements whose geometry cannot be read are left out, unless the
+// document's ErrorMode is StrictErrorMode.
+func (doc *Document) ViewBounds() (x0, y0, x1, y1 float64, err error) {
+	b := &boundsSink{}
+	t := &transformSink{sink: b}
+	p := NewSinkParser(t)
+	err = doc.walkGeometry(doc.ErrorMode == StrictErrorMode, func(e *Element, m gg.Matrix, s style, use *Element) error {
+		t.m = m
+		if e.Name == "image" {
+			// the intrinsic size of the image is not known without
+			// loading it, so only images with both dimensions count
+			_, hasW := e.LookupAttr("width")
+			_, hasH := e.LookupAttr("height")
+			f, err := floatAttrs(e, "x", "y", "width", "height")
+			if err != nil {
+				return err
+			}
+			if hasW && hasH {
+				x, y, w, h := f[0], f[1], f[2], f[3]
+				t.MoveTo(x, y)
+				t.LineTo(x+w, y)
+				t.LineTo(x+w, y+h)
+				t.LineTo(x, y+h)
+			}
+			return nil
+		}
+		return buildShape(p, e)
+	})
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	return b.x0, b.y0, b.x1, b.y1, nil
+}
+
+// walkGeometry calls fn, in drawing order, for every shape and image Draw
+// would render, with the matrix from its user space to that of the root
+// element and its resolved style. use is the outermost <use> element the
+// element is drawn through, if any. Elements that fail, or for which fn
+// fails, are skipped unless strict is set, in which case the walk stops
+// with the error.
+func (doc *Document) walkGeometry(strict bool, fn func(e *Element, m gg.Matrix, s style, use *Element) error) error {
+	skip := func(e *Element, err error) error {
+		if strict {
+			return fmt.Errorf("svgg: <%s>: %w", e.Name, err)
+		}
+		return nil
+	}
+	var uses []*Element
+	var inst int        // elements walked through <use>
+	var target *Element // the element referenced by the innermost <use>
+	var walk func(e *Element, m gg.Matrix, parent style) error
+	walkChildren := func(e *Element, m gg.Matrix, s style) error {
+		for _, c := range e.Children {
+			if err := walk(c, m, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	walk = func(e *Element, m gg.Matrix, parent style) error {
+		if _, ok := elementAttrs[e.Name]; !ok {
+			return nil
+		}
+		if len(uses) > 0 {
+			inst++
+			if max := doc.Limits.MaxUseInstances; max > 0 && inst > max {
+				return skip(e, fmt.Errorf("%w: more than %d elements drawn through <use>", ErrLimitExceeded, max))
+			}
+		}
+		var instance *Element
+		if e == target {
+			instance = uses[len(uses)-1]
+		}
+		target = nil
+		if e.Name == "symbol" && instance == nil {
+			return nil
+		}
+		s, err := parent.resolve(e)
+		if err != nil {
+			return skip(e, err)
+		}
+		if !s.display {
+			return nil
+		}
+		if tr := e.Attr("transform"); tr != "" {
+			local, err := parseTransform(tr)
+			if err != nil {
+				return skip(e, err)
+			}
+			m = local.Multiply(m)
+		}
+		switch e.Name {
+		case "svg", "symbol":
+			rect, vm, err := doc.viewport(e, instance)
+			if err != nil {
+				return skip(e, err)
+			}
+			if rect[2] <= 0 || rect[3] <= 0 {
+				return nil
+			}
+			return walkChildren(e, vm.Multiply(m), s)
+		case "g", "a":
+			return walkChildren(e, m, s)
+		case "use":
+			ref, err := doc.lookupRef(e.Attr("href"))
+			if err != nil {
+				return skip(e, err)
+			}
+			for _, u := range uses {
+				if u == e {
+					return skip(e, fmt.Errorf("circular reference to %q", e.Attr("href")))
+				}
+			}
+			if max := doc.Limits.MaxUseDepth; max > 0 && len(uses) >= max {
+				return skip(e, fmt.Errorf("%w: <use> nested more than %d deep", ErrLimitExceeded, max))
+			}
+			f, err := floatAttrs(e, "x", "y")
+			if err != nil {
+				return skip(e, err)
+			}
+			uses = append(uses, e)
+			defer func() { uses = uses[:len(uses)-1] }()
+			target = ref
+			return walk(ref, gg.Translate(f[0], f[1]).Multiply(m), s)
+		}
+		var use *Element
+		if len(uses) > 0 {
+			use = uses[0]
+		}
+		if err := fn(e, m, s, use); err != nil {
+			return skip(e, err)
+		}
+		return nil
+	}
+	return walkChildren(doc.Root, gg.Identity(), doc.rootStyle())
+}
+
+// transformSink applies an affine transform to the points of the segments
+// it passes on.
+type transformSink struct {
+	sink PathSink
+	m    gg.Matrix
+}
+
+func (t *transformSink) MoveTo(x, y float64) {
+	t.sink.MoveTo(t.m.TransformPoint(x, y))
+}
+
+func (t *transformSink) LineTo(x, y float64) {
+	t.sink.LineTo(t.m.TransformPoint(x, y))
+}
+
+func (t *transformSink) QuadraticTo(x1, y1, x, y float64) {
+	x1, y1 = t.m.TransformPoint(x1, y1)
+	x, y = t.m.TransformPoint(x, y)
+	t.sink.QuadraticTo(x1, y1, x, y)
+}
+
+func (t *transformSink) CubicTo(x1, y1, x2, y2, x, y float64) {
+	x1, y1 = t.m.TransformPoint(x1, y1)
+	x2, y2 = t.m.TransformPoint(x2, y2)
+	x, y = t.m.TransformPoint(x, y)
+	t.sink.CubicTo(x1, y1, x2, y2, x, y)
+}
+
+func (t *transformSink) ClosePath() {
+	t.sink.ClosePath()
+}
+
+func (t *transformSink) ArcTo(a Arc, x, y float64) {
+	if as, ok := t.sink.(ArcSink); ok {
+		if ta, ok := a.Transform(t.m); ok {
+			x, y = t.m.TransformPoint(x, y)
+			as.ArcTo(ta, x, y)
+			return
+		}
+	}
+	// Béziers transform by their control points
+	a.cubics(x, y, 0, t.CubicTo)
+}
+
+// boundsSink accumulates the bounding box of the segments sent to it.
+type boundsSink struct {
+	x0, y0, x1, y1 float64
+	curX, curY     float64
+	startX, startY float64
+	started        bool // the box holds at least one point
+}
+
+func (b *boundsSink) add(x, y float64) {
+	if !b.started {
+		b.x0, b.y0, b.x1, b.y1 = x, y, x, y
+		b.started = true
+		return
+	}
+	b.x0, b.y0 = math.Min(b.x0, x), math.Min(b.y0, y)
+	b.x1, b.y1 = math.Max(b.x1, x), math.Max(b.y1, y)
+}
+
+// segment adds the start point of a segment, then moves the current point
+// to its end point, which the caller must add.
+func (b *boundsSink) segment(x, y float64) {
+	b.add(b.curX, b.curY)
+	b.curX, b.curY = x, y
+}
+
+func (b *boundsSink) MoveTo(x, y float64) {
+	b.curX, b.curY = x, y
+	b.startX, b.startY = x, y
+}
+
+func (b *boundsSink) LineTo(x, y float64) {
+	b.segment(x, y)
+	b.add(x, y)
+}
+
+func (b *boundsSink) QuadraticTo(x1, y1, x, y float64) {
+	x0, y0 := b.curX, b.curY
+	b.segment(x, y)
+	b.add(x, y)
+	for _, t := range [2]float64{quadExtremum(x0, x1, x), quadExtremum(y0, y1, y)} {
+		if t > 0 && t < 1 {
+			mt := 1 - t
+			b.add(mt*mt*x0+2*mt*t*x1+t*t*x, mt*mt*y0+2*mt*t*y1+t*t*y)
+		}
+	}
+}
+
+func (b *boundsSink) CubicTo(x1, y1, x2, y2, x, y float64) {
+	x0, y0 := b.curX, b.curY
+	b.segment(x, y)
+	b.add(x, y)
+	var buf [4]float64
+	ts := cubicExtrema(buf[:0], x0, x1, x2, x)
+	ts = cubicExtrema(ts, y0, y1, y2, y)
+	for _, t := range ts {
+		mt := 1 - t
+		a, c1, c2, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+		b.add(a*x0+c1*x1+c2*x2+d*x, a*y0+c1*y1+c2*y2+d*y)
+	}
+}
+
+func (b *boundsSink) ClosePath() {
+	b.curX, b.curY = b.startX, b.startY
+}
+
+func (b *boundsSink) ArcTo(a Arc, x, y float64) {
+	b.segment(x, y)
+	b.add(x, y)
+	sinPhi, cosPhi := math.Sincos(a.Phi)
+	// angles at which x and y reach their extremes on the full ellipse
+	tx := math.Atan2(-a.Ry*sinPhi, a.Rx*cosPhi)
+	ty := math.Atan2(a.Ry*cosPhi, a.Rx*sinPhi)
+	for _, theta := range [4]float64{tx, tx + math.Pi, ty, ty + math.Pi} {
+		if angleInSweep(theta, a.Theta1, a.DTheta) {
+			b.add(a.Point(theta))
+		}
+	}
+}
+
+// angleInSweep reports whether theta lies on the arc running from theta1
+// through dtheta.
+func angleInSweep(theta, theta1, dtheta float64) bool {
+	d := theta - theta1
+	if dtheta < 0 {
+		d, dtheta = -d, -dtheta
+	}
+	d = math.Mod(d, 2*math.Pi)
+	if d < 0 {
+		d += 2 * math.Pi
+	}
+	return d <= dtheta
+}
+
+// quadExtremum returns the parameter at which the quadratic Bézier with
+// coordinates p0, p1, p2 has zero derivative, or -1 if there is none.
+func quadExtremum(p0, p1, p2 float64) float64 {
+	den := p0 - 2*p1 + p2
+	if den == 0 {
+		return -1
+	}
+	return (p0 - p1) / den
+}
+
+// cubicExtrema appends to ts the parameters in (0, 1) at which the cubic
+// Bézier with coordinates p0 to p3 has zero derivative.
+func cubicExtrema(ts []float64, p0, p1, p2, p3 float64) []float64 {
+	a := -p0 + 3*p1 - 3*p2 + p3
+	b := 2 * (p0 - 2*p1 + p2)
+	c := p1 - p0
+	add := func(t float64) {
+		if t > 0 && t < 1 {
+			ts = append(ts, t)
+		}
+	}
+	if math.Abs(a) < 1e-12 {
+		if b != 0 {
+			add(-c / b)
+		}
+		return ts
+	}
+	disc := b*b - 4*a*c
+	if disc < 0 {
+		return ts
+	}
+	sq := math.Sqrt(disc)
+	add((-b + sq) / (2 * a))
+	add((-b - sq) / (2 * a))
+	return ts
+}
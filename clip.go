@@ -0,0 +1,276 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// clipAndMask restricts drawing e, in its current user space, to the clip
+// path and mask it references. Like group opacity, a mask on a group is
+// approximated by applying it to every shape in the group rather than to
+// the group as a whole. A reference that cannot be used is reported and
+// ignored.
+func (r *renderer) clipAndMask(e *Element) error {
+	if r.opts.Wireframe {
+		return nil
+	}
+	props := properties(e)
+	var box *boundsSink
+	for _, name := range []string{"clip-path", "mask"} {
+		v := props[name]
+		if v == "" || v == "none" {
+			continue
+		}
+		if box == nil {
+			box = &boundsSink{}
+			elementBounds(e, &transformSink{sink: box, m: gg.Identity()})
+		}
+		ref, err := parseURL(v)
+		var mask *image.Alpha
+		if err == nil {
+			if name == "clip-path" {
+				mask, err = r.clipPath(ref, box)
+			} else {
+				mask, err = r.mask(ref, box)
+			}
+		}
+		if err != nil {
+			if err := r.fail(e, fmt.Errorf("%s=%q: %w", name, v, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		if r.clip != nil {
+			multiplyMask(mask, r.clip)
+		}
+		r.clip = mask
+		setClip(r.dc, mask)
+	}
+	return nil
+}
+
+// setClip makes clip, which may be nil for none, the mask of dc.
+func setClip(dc *gg.Context, clip *image.Alpha) {
+	if clip == nil {
+		dc.ResetClip()
+	} else {
+		dc.SetMask(clip)
+	}
+}
+
+// parseURL parses a functional IRI such as "url(#clip)".
+func parseURL(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "url(") || !strings.HasSuffix(s, ")") {
+		return "", fmt.Errorf("%w: invalid reference %q", ErrSyntax, s)
+	}
+	return strings.Trim(strings.TrimSpace(s[4:len(s)-1]), `'"`), nil
+}
+
+// unitsMatrix returns the matrix from the coordinates given in units to
+// user space: for "objectBoundingBox", the bounding box b has sides of 1,
+// and any other units are user space already.
+func unitsMatrix(units string, b *boundsSink) gg.Matrix {
+	if strings.TrimSpace(units) != "objectBoundingBox" {
+		return gg.Identity()
+	}
+	return gg.Scale(b.x1-b.x0, b.y1-b.y0).Multiply(gg.Translate(b.x0, b.y0))
+}
+
+// clipPath returns the coverage of the clipPath element referenced by ref
+// on the device pixels of the context, for an element with the bounding
+// box b. The children of the clip path are united, each filled with its
+// clip-rule rather than its fill-rule.
+func (r *renderer) clipPath(ref string, b *boundsSink) (*image.Alpha, error) {
+	c, err := r.doc.lookupRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if c.Name != "clipPath" {
+		return nil, fmt.Errorf("%w: <%s> used as a clip path", ErrNotImplemented, c.Name)
+	}
+	m := unitsMatrix(c.Attr("clipPathUnits"), b)
+	if t := c.Attr("transform"); t != "" {
+		ct, err := parseTransform(t)
+		if err != nil {
+			return nil, err
+		}
+		m = m.Multiply(ct)
+	}
+	m = m.Multiply(currentMatrix(r.dc))
+	s, err := r.doc.rootStyle().resolve(c)
+	if err != nil {
+		return nil, err
+	}
+	dc := gg.NewContext(r.dc.Width(), r.dc.Height())
+	dc.SetRGB(1, 1, 1)
+	for _, child := range c.Children {
+		if err := clipShape(r.doc, dc, child, m, s); err != nil {
+			return nil, fmt.Errorf("<%s>: %w", child.Name, err)
+		}
+	}
+	return dc.AsMask(), nil
+}
+
+// clipShape fills the outline of e, a child of a clip path drawn through
+// the matrix m to the device pixels of dc. A <use> child may reference a
+// shape; other elements are not part of the clip.
+func clipShape(doc *Document, dc *gg.Context, e *Element, m gg.Matrix, parent style) error {
+	s, err := parent.resolve(e)
+	if err != nil || !s.display {
+		return err
+	}
+	if t := e.Attr("transform"); t != "" {
+		local, err := parseTransform(t)
+		if err != nil {
+			return err
+		}
+		m = local.Multiply(m)
+	}
+	if e.Name == "use" {
+		ref, err := doc.lookupRef(e.Attr("href"))
+		if err != nil {
+			return err
+		}
+		f, err := floatAttrs(e, "x", "y")
+		if err != nil {
+			return err
+		}
+		if ref.Name == "use" {
+			return fmt.Errorf("%w: <use> of a <use> in a clip path", ErrNotImplemented)
+		}
+		return clipShape(doc, dc, ref, gg.Translate(f[0], f[1]).Multiply(m), s)
+	}
+	switch e.Name {
+	case "svg", "g", "image":
+		return nil
+	}
+	if _, ok := elementAttrs[e.Name]; !ok {
+		return nil
+	}
+	if err := buildShape(NewSinkParser(&transformSink{sink: dc, m: m}), e); err != nil {
+		dc.ClearPath()
+		return err
+	}
+	dc.SetFillRule(s.clipRule)
+	dc.Fill()
+	return nil
+}
+
+// mask returns the coverage of the mask element referenced by ref on the
+// device pixels of the context, for an element with the bounding box b.
+// The coverage is the luminance of the mask's contents, limited to the
+// mask's x, y, width and height.
+func (r *renderer) mask(ref string, b *boundsSink) (*image.Alpha, error) {
+	e, err := r.doc.lookupRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if e.Name != "mask" {
+		return nil, fmt.Errorf("%w: <%s> used as a mask", ErrNotImplemented, e.Name)
+	}
+	units := e.Attr("maskUnits")
+	if units == "" {
+		units = "objectBoundingBox"
+	}
+	w, h := 1.0, 1.0
+	if strings.TrimSpace(units) != "objectBoundingBox" {
+		w, h = r.doc.ViewBox.W, r.doc.ViewBox.H
+	}
+	var f [4]float64
+	for i, a := range [4]struct {
+		name, def string
+		size      float64
+	}{{"x", "-10%", w}, {"y", "-10%", h}, {"width", "120%", w}, {"height", "120%", h}} {
+		v := e.Attr(a.name)
+		if v == "" {
+			v = a.def
+		}
+		if f[i], err = parseLength(v, a.size); err != nil {
+			return nil, fmt.Errorf("%s: %w", a.name, err)
+		}
+	}
+	ctm := currentMatrix(r.dc)
+	region := gg.NewContext(r.dc.Width(), r.dc.Height())
+	applyMatrix(region, unitsMatrix(units, b).Multiply(ctm))
+	region.DrawRectangle(f[0], f[1], f[2], f[3])
+	region.Fill()
+
+	s, err := r.doc.rootStyle().resolve(e)
+	if err != nil {
+		return nil, err
+	}
+	dc := gg.NewContext(r.dc.Width(), r.dc.Height())
+	applyMatrix(dc, unitsMatrix(e.Attr("maskContentUnits"), b).Multiply(ctm))
+	if err := r.drawDef(e, dc, func() error { return r.drawChildren(e, s) }); err != nil {
+		return nil, err
+	}
+	im := dc.Image().(*image.RGBA)
+	mask := region.AsMask()
+	for i := range mask.Pix {
+		p := im.Pix[4*i : 4*i+3]
+		// the colors are premultiplied, so this is luminance times alpha
+		l := 0.2125*float64(p[0]) + 0.7154*float64(p[1]) + 0.0721*float64(p[2])
+		mask.Pix[i] = uint8(l*float64(mask.Pix[i])/255 + 0.5)
+	}
+	return mask, nil
+}
+
+// drawDef calls draw with dc in place of the renderer's context, to draw
+// the contents of the mask or pattern e, which are not clipped by the
+// element using them.
+func (r *renderer) drawDef(e *Element, dc *gg.Context, draw func() error) error {
+	for _, d := range r.defs {
+		if d == e {
+			return fmt.Errorf("circular reference to <%s id=%q>", e.Name, e.Attr("id"))
+		}
+	}
+	saved, clip, layer := r.dc, r.clip, r.layer
+	r.dc, r.clip, r.layer = dc, nil, nil
+	r.defs = append(r.defs, e)
+	r.parser.setContext(dc)
+	err := draw()
+	r.dc, r.clip, r.layer = saved, clip, layer
+	r.defs = r.defs[:len(r.defs)-1]
+	r.parser.setContext(saved)
+	return err
+}
+
+// multiplyMask multiplies the coverage of dst by that of src, which has
+// the same bounds.
+func multiplyMask(dst, src *image.Alpha) {
+	for i, a := range src.Pix {
+		dst.Pix[i] = uint8((int(dst.Pix[i])*int(a) + 127) / 255)
+	}
+}
+
+// clipRect intersects the clip region of the renderer with the rectangle
+// x, y, w, h in the current user space.
+func (r *renderer) clipRect(x, y, w, h float64) {
+	if r.opts.Wireframe {
+		return
+	}
+	dc := gg.NewContext(r.dc.Width(), r.dc.Height())
+	applyMatrix(dc, currentMatrix(r.dc))
+	dc.DrawRectangle(x, y, w, h)
+	dc.Fill()
+	mask := dc.AsMask()
+	if r.clip != nil {
+		multiplyMask(mask, r.clip)
+	}
+	r.clip = mask
+	setClip(r.dc, mask)
+}
+
+// clipsOverflow reports whether the viewport established by e hides the
+// content that overflows it, which is the default.
+func clipsOverflow(e *Element) bool {
+	switch properties(e)["overflow"] {
+	case "visible", "auto":
+		return false
+	}
+	return true
+}
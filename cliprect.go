@@ -0,0 +1,407 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// rectClipper is the PathSink through which a Parser with a ClipRect
+// draws. It clips in the coordinates of the rectangle and sends what is
+// left on to sink in the Parser's user space.
+type rectClipper struct {
+	sink     PathSink
+	m, inv   gg.Matrix // user space to the rectangle's coordinates and back
+	min, max gg.Point  // corners of the rectangle
+	tol      float64   // flattening tolerance in the rectangle's coordinates
+	lines    bool      // clip as lines rather than polygons
+
+	start, cur gg.Point // start of the subpath and last point received
+	open       bool     // a subpath has been started and not finished
+	drawn      bool     // the output subpath has been started
+
+	// in polygon mode, the subpath passes through a stage per edge
+	stages [4]clipStage
+
+	// in line mode, cut records that part of the subpath was clipped
+	cut bool
+}
+
+// clipStage is the state of the subpath clipped against one edge of the
+// rectangle.
+type clipStage struct {
+	first, prev gg.Point
+	n           int  // points received
+	started     bool // the first point was passed on
+}
+
+// reset prepares c to clip to the rectangle min, max for a Parser drawing
+// to sink through m.
+func (c *rectClipper) reset(sink PathSink, m gg.Matrix, min, max gg.Point, tol float64, lines bool) {
+	*c = rectClipper{sink: sink, m: m, inv: invertMatrix(m), min: min, max: max, tol: tol, lines: lines}
+}
+
+func (c *rectClipper) MoveTo(x, y float64) {
+	c.finish()
+	x, y = c.m.TransformPoint(x, y)
+	c.start = gg.Point{X: x, Y: y}
+	c.cur = c.start
+	c.open = true
+	c.drawn = false
+	c.cut = false
+	if !c.lines {
+		c.polyStart(0, c.start)
+	}
+}
+
+func (c *rectClipper) LineTo(x, y float64) {
+	c.segment(Segment{Op: LineOp, P: [3]gg.Point{{X: x, Y: y}}})
+}
+
+func (c *rectClipper) QuadraticTo(x1, y1, x, y float64) {
+	c.segment(Segment{Op: QuadOp, P: [3]gg.Point{{X: x1, Y: y1}, {X: x, Y: y}}})
+}
+
+func (c *rectClipper) CubicTo(x1, y1, x2, y2, x, y float64) {
+	c.segment(Segment{Op: CubicOp, P: [3]gg.Point{{X: x1, Y: y1}, {X: x2, Y: y2}, {X: x, Y: y}}})
+}
+
+func (c *rectClipper) ClosePath() {
+	if !c.open {
+		return
+	}
+	if c.lines {
+		if !c.cut && c.drawn {
+			c.sink.ClosePath()
+		} else {
+			c.lineSeg(Segment{Op: LineOp, P: [3]gg.Point{c.start}})
+		}
+		c.open = false
+		return
+	}
+	c.finish()
+}
+
+// segment clips the segment s, given in user space, from the last point.
+func (c *rectClipper) segment(s Segment) {
+	if !c.open {
+		// like a context, start a subpath at a point drawn to without one
+		e := s.End()
+		c.MoveTo(e.X, e.Y)
+		return
+	}
+	n := 1
+	switch s.Op {
+	case QuadOp:
+		n = 2
+	case CubicOp:
+		n = 3
+	}
+	for i := 0; i < n; i++ {
+		s.P[i].X, s.P[i].Y = c.m.TransformPoint(s.P[i].X, s.P[i].Y)
+	}
+	if c.lines {
+		c.lineSeg(s)
+	} else {
+		c.polySeg(0, s)
+	}
+	c.cur = s.End()
+}
+
+// finish closes the subpath being clipped as a polygon, sending the edges
+// it gained along the rectangle. Line mode has nothing left to send.
+func (c *rectClipper) finish() {
+	if c.open && !c.lines {
+		c.polyClose(0)
+	}
+	c.open = false
+}
+
+// inside reports whether p lies on the inner side of edge k of the
+// rectangle: its left, right, top or bottom.
+func (c *rectClipper) inside(k int, p gg.Point) bool {
+	switch k {
+	case 0:
+		return p.X >= c.min.X
+	case 1:
+		return p.X <= c.max.X
+	case 2:
+		return p.Y >= c.min.Y
+	}
+	return p.Y <= c.max.Y
+}
+
+// intersect returns the point at which the line from a to b crosses edge
+// k, which it must cross.
+func (c *rectClipper) intersect(k int, a, b gg.Point) gg.Point {
+	switch k {
+	case 0, 1:
+		x := c.min.X
+		if k == 1 {
+			x = c.max.X
+		}
+		return gg.Point{X: x, Y: a.Y + (x-a.X)/(b.X-a.X)*(b.Y-a.Y)}
+	}
+	y := c.min.Y
+	if k == 3 {
+		y = c.max.Y
+	}
+	return gg.Point{X: a.X + (y-a.Y)/(b.Y-a.Y)*(b.X-a.X), Y: y}
+}
+
+// hull returns the start point of s, from a, and its other points.
+func hull(a gg.Point, s Segment) []gg.Point {
+	switch s.Op {
+	case QuadOp:
+		return []gg.Point{a, s.P[0], s.P[1]}
+	case CubicOp:
+		return []gg.Point{a, s.P[0], s.P[1], s.P[2]}
+	}
+	return []gg.Point{a, s.P[0]}
+}
+
+// splits reports whether the curve s, from a, is long enough to halve
+// rather than flatten where it crosses an edge.
+func (c *rectClipper) splits(a gg.Point, s Segment) bool {
+	l := controlLength(a, s)
+	return l > 16*c.tol && !math.IsInf(l, 0)
+}
+
+// halve splits the quadratic or cubic Bézier s, from a, at its middle.
+func halve(a gg.Point, s Segment) (Segment, Segment) {
+	if s.Op == QuadOp {
+		p01 := a.Interpolate(s.P[0], 0.5)
+		p12 := s.P[0].Interpolate(s.P[1], 0.5)
+		m := p01.Interpolate(p12, 0.5)
+		return Segment{Op: QuadOp, P: [3]gg.Point{p01, m}},
+			Segment{Op: QuadOp, P: [3]gg.Point{p12, s.P[1]}}
+	}
+	p01 := a.Interpolate(s.P[0], 0.5)
+	p12 := s.P[0].Interpolate(s.P[1], 0.5)
+	p23 := s.P[1].Interpolate(s.P[2], 0.5)
+	p012 := p01.Interpolate(p12, 0.5)
+	p123 := p12.Interpolate(p23, 0.5)
+	m := p012.Interpolate(p123, 0.5)
+	return Segment{Op: CubicOp, P: [3]gg.Point{p01, p012, m}},
+		Segment{Op: CubicOp, P: [3]gg.Point{p123, p23, s.P[2]}}
+}
+
+// sides reports whether all the points lie inside edge k, and whether all
+// lie outside it.
+func (c *rectClipper) sides(k int, pts []gg.Point) (in, out bool) {
+	in, out = true, true
+	for _, p := range pts {
+		if c.inside(k, p) {
+			out = false
+		} else {
+			in = false
+		}
+	}
+	return in, out
+}
+
+// polyStart starts the subpath at p in stage k of the polygon clipper,
+// whose stage 4 is the output.
+func (c *rectClipper) polyStart(k int, p gg.Point) {
+	if k == len(c.stages) {
+		c.emit(Segment{Op: MoveOp, P: [3]gg.Point{p}})
+		return
+	}
+	c.stages[k] = clipStage{first: p, prev: p, n: 1}
+	if c.inside(k, p) {
+		c.polyEmit(k, Segment{Op: LineOp, P: [3]gg.Point{p}})
+	}
+}
+
+// polySeg clips the segment s, from the last point of stage k, against
+// edge k. A curve crossing the edge is halved until the parts crossing it
+// are small enough to flatten, so the rest stays curved.
+func (c *rectClipper) polySeg(k int, s Segment) {
+	if k == len(c.stages) {
+		c.emit(s)
+		return
+	}
+	st := &c.stages[k]
+	if st.n == 0 {
+		return
+	}
+	if s.Op == LineOp {
+		c.polyLine(k, s.P[0])
+		return
+	}
+	switch in, out := c.sides(k, hull(st.prev, s)); {
+	case in:
+		c.polyEmit(k, s)
+		st.prev = s.End()
+	case out:
+		st.prev = s.End()
+	case c.splits(st.prev, s):
+		a, b := halve(st.prev, s)
+		c.polySeg(k, a)
+		c.polySeg(k, b)
+	default:
+		flattenSegment(st.prev, s, c.tol, func(p gg.Point) {
+			c.polyLine(k, p)
+		})
+	}
+}
+
+// polyLine clips the line from the last point of stage k to p against
+// edge k.
+func (c *rectClipper) polyLine(k int, p gg.Point) {
+	st := &c.stages[k]
+	a := st.prev
+	st.prev = p
+	switch ain, pin := c.inside(k, a), c.inside(k, p); {
+	case ain && pin:
+		c.polyEmit(k, Segment{Op: LineOp, P: [3]gg.Point{p}})
+	case pin:
+		c.polyEmit(k, Segment{Op: LineOp, P: [3]gg.Point{c.intersect(k, a, p)}})
+		c.polyEmit(k, Segment{Op: LineOp, P: [3]gg.Point{p}})
+	case ain:
+		c.polyEmit(k, Segment{Op: LineOp, P: [3]gg.Point{c.intersect(k, a, p)}})
+	}
+}
+
+// polyEmit passes s on from stage k to the next stage, starting the
+// subpath there with its end point if it is the first.
+func (c *rectClipper) polyEmit(k int, s Segment) {
+	st := &c.stages[k]
+	if !st.started {
+		st.started = true
+		c.polyStart(k+1, s.End())
+		return
+	}
+	c.polySeg(k+1, s)
+}
+
+// polyClose closes the subpath in stage k and the stages after it.
+func (c *rectClipper) polyClose(k int) {
+	if k == len(c.stages) {
+		if c.drawn {
+			c.sink.ClosePath()
+		}
+		return
+	}
+	st := &c.stages[k]
+	if st.n > 0 {
+		c.polyLine(k, st.first)
+	}
+	*st = clipStage{}
+	c.polyClose(k + 1)
+}
+
+// lineSeg clips the segment s from the last point as a line, splitting the
+// subpath where it leaves the rectangle. Curves crossing an edge are
+// halved and flattened as by polySeg.
+func (c *rectClipper) lineSeg(s Segment) {
+	a := c.cur
+	if s.Op != LineOp {
+		pts := hull(a, s)
+		in := true
+		for k := range c.stages {
+			kin, kout := c.sides(k, pts)
+			if kout {
+				c.drawn, c.cut = false, true
+				return
+			}
+			in = in && kin
+		}
+		if in {
+			if !c.drawn {
+				c.emit(Segment{Op: MoveOp, P: [3]gg.Point{a}})
+			}
+			c.emit(s)
+			return
+		}
+		if c.splits(a, s) {
+			h0, h1 := halve(a, s)
+			c.lineSeg(h0)
+			c.cur = h0.End()
+			c.lineSeg(h1)
+			return
+		}
+		flattenSegment(a, s, c.tol, func(p gg.Point) {
+			c.lineSeg(Segment{Op: LineOp, P: [3]gg.Point{p}})
+			c.cur = p
+		})
+		return
+	}
+	b := s.P[0]
+	t0, t1, ok := c.clipLine(a, b)
+	if !ok {
+		c.drawn, c.cut = false, true
+		return
+	}
+	at := func(t float64) gg.Point {
+		return gg.Point{X: a.X + t*(b.X-a.X), Y: a.Y + t*(b.Y-a.Y)}
+	}
+	if t0 > 0 {
+		c.drawn, c.cut = false, true
+	}
+	if !c.drawn {
+		c.emit(Segment{Op: MoveOp, P: [3]gg.Point{at(t0)}})
+	}
+	if t1 < 1 {
+		c.emit(Segment{Op: LineOp, P: [3]gg.Point{at(t1)}})
+		c.drawn, c.cut = false, true
+		return
+	}
+	c.emit(s)
+}
+
+// clipLine returns the parameters t0 <= t1 between which the line from a
+// to b lies inside the rectangle, or ok false if no part of it does.
+func (c *rectClipper) clipLine(a, b gg.Point) (t0, t1 float64, ok bool) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t0, t1 = 0, 1
+	for _, e := range [4][2]float64{
+		{-dx, a.X - c.min.X},
+		{dx, c.max.X - a.X},
+		{-dy, a.Y - c.min.Y},
+		{dy, c.max.Y - a.Y},
+	} {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, false
+			}
+			continue
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return 0, 0, false
+			}
+			if r > t0 {
+				t0 = r
+			}
+		} else {
+			if r < t0 {
+				return 0, 0, false
+			}
+			if r < t1 {
+				t1 = r
+			}
+		}
+	}
+	return t0, t1, true
+}
+
+// emit sends a segment of the clipped path on to the sink in user space.
+func (c *rectClipper) emit(s Segment) {
+	for i := range s.P {
+		s.P[i].X, s.P[i].Y = c.inv.TransformPoint(s.P[i].X, s.P[i].Y)
+	}
+	switch s.Op {
+	case MoveOp:
+		c.sink.MoveTo(s.P[0].X, s.P[0].Y)
+		c.drawn = true
+	case LineOp:
+		c.sink.LineTo(s.P[0].X, s.P[0].Y)
+	case QuadOp:
+		c.sink.QuadraticTo(s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y)
+	case CubicOp:
+		c.sink.CubicTo(s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y, s.P[2].X, s.P[2].Y)
+	}
+}
@@ -0,0 +1,398 @@
+// Command svgg rasterizes SVG files to PNG or JPEG images.
+//
+// Usage:
+//
+//	svgg [flags] input.svg
+//	svgg [flags] [-sizes 16,32,64] [-d dir] inputs...
+//
+// The image is drawn at the document's own size unless -w or -h is given,
+// scaled by -scale and by -dpi relative to the 96 dpi of CSS pixels. If
+// only one of -w and -h is given, the other follows the document's aspect
+// ratio. The output format is taken from the extension of -o, which
+// defaults to the input path with a .png extension.
+//
+// Inputs may also be directories, whose .svg and .svgz files are converted,
+// or glob patterns such as "icons/*.svg". Each input is written to the
+// directory given by -d, or next to it, with a .png or .jpg extension. With
+// -sizes, an image is drawn for each size, fitting the document into a
+// square of that many pixels, and named with the size, as in icon-32.png.
+// The ico format instead writes all the sizes, by default 16, 32 and 48,
+// to a single favicon file per input. With -worldfile, a world file such as
+// map.pgw is written next to each image, georeferencing it in the
+// document's user units for GIS software.
+// Files are converted by -j parallel workers; a failure is reported and
+// the other files are still converted.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/engelsjk/svgg"
+	"github.com/fogleman/gg"
+)
+
+// options are the settings of a conversion.
+type options struct {
+	width, height int
+	box           int   // fits the longer side in box pixels, if positive
+	icons         []int // sizes of the images of an ico file
+	scale, dpi    float64
+	background    color.Color
+	format        string
+	quality       int
+	worldFile     bool // write a world file next to the image
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("svgg: ")
+	var o options
+	var out, dir, bg, sizes string
+	var workers int
+	flag.StringVar(&out, "o", "", "output `path` for a single input, or - for standard output (default input with a .png extension)")
+	flag.StringVar(&dir, "d", "", "output `directory` (default next to each input)")
+	flag.IntVar(&o.width, "w", 0, "output width in pixels, overriding -scale and -dpi")
+	flag.IntVar(&o.height, "h", 0, "output height in pixels, overriding -scale and -dpi")
+	flag.StringVar(&sizes, "sizes", "", "comma-separated `list` of square sizes in pixels to draw each input at, such as 16,32,64")
+	flag.Float64Var(&o.scale, "scale", 1, "scale `factor` applied to the document size")
+	flag.Float64Var(&o.dpi, "dpi", 96, "output resolution; 96 draws a CSS pixel as one pixel")
+	flag.StringVar(&bg, "bg", "", "background `color`, such as white or #336699 (default transparent, or white for JPEG)")
+	flag.StringVar(&o.format, "format", "", "output format, png, jpeg or ico (default from the output extension, or png)")
+	flag.IntVar(&o.quality, "quality", jpeg.DefaultQuality, "JPEG quality from 1 to 100")
+	flag.BoolVar(&o.worldFile, "worldfile", false, "also write a world file georeferencing each image in the document's user units")
+	flag.IntVar(&workers, "j", runtime.NumCPU(), "number of files converted in parallel")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: svgg [flags] inputs...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if bg != "" {
+		c, err := parseColor(bg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		o.background = c
+	}
+	boxes, err := parseSizes(sizes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	inputs, err := expandInputs(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if out != "" && o.format == "" {
+		o.format = formatOf(out)
+	}
+	switch o.format = strings.ToLower(o.format); o.format {
+	case "jpg":
+		o.format = "jpeg"
+	case "", "png", "jpeg", "ico":
+	default:
+		log.Fatalf("unknown -format %q, want png, jpeg or ico", o.format)
+	}
+	if o.format == "ico" {
+		o.icons = boxes
+		if len(boxes) == 0 {
+			o.icons = []int{16, 32, 48}
+		}
+		boxes = nil
+	}
+	if o.worldFile && (o.format == "ico" || out == "-") {
+		log.Fatal("-worldfile needs png or jpeg output to a file")
+	}
+	if out != "" {
+		if len(inputs) != 1 || len(boxes) > 1 {
+			log.Fatal("-o needs a single input and size")
+		}
+		if len(boxes) == 1 {
+			o.box = boxes[0]
+		}
+		if err := convert(inputs[0], out, o); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if o.format == "" {
+		o.format = "png"
+	}
+	var jobs []job
+	for _, in := range inputs {
+		base := strings.TrimSuffix(in, filepath.Ext(in))
+		if dir != "" {
+			base = filepath.Join(dir, filepath.Base(base))
+		}
+		ext := ".png"
+		switch o.format {
+		case "jpeg":
+			ext = ".jpg"
+		case "ico":
+			ext = ".ico"
+		}
+		if len(boxes) == 0 {
+			jobs = append(jobs, job{in, base + ext, o})
+		}
+		for _, n := range boxes {
+			jo := o
+			jo.box = n
+			jobs = append(jobs, job{in, fmt.Sprintf("%s-%d%s", base, n, ext), jo})
+		}
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !run(jobs, workers) {
+		os.Exit(1)
+	}
+}
+
+// job is the conversion of one input to one output.
+type job struct {
+	in, out string
+	o       options
+}
+
+// run converts the jobs with the given number of parallel workers,
+// reporting failures. It returns whether all succeeded.
+func run(jobs []job, workers int) bool {
+	if workers < 1 {
+		workers = 1
+	}
+	ch := make(chan job)
+	var mu sync.Mutex
+	ok := true
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				if err := convert(j.in, j.out, j.o); err != nil {
+					mu.Lock()
+					ok = false
+					fmt.Fprintln(os.Stderr, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, j := range jobs {
+		ch <- j
+	}
+	close(ch)
+	wg.Wait()
+	return ok
+}
+
+// expandInputs expands the command line arguments into SVG files: a
+// directory stands for the .svg and .svgz files in it, and a pattern for
+// the files it matches.
+func expandInputs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err == nil {
+			if !fi.IsDir() {
+				files = append(files, arg)
+				continue
+			}
+			for _, pattern := range []string{"*.svg", "*.svgz"} {
+				m, err := filepath.Glob(filepath.Join(arg, pattern))
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, m...)
+			}
+			continue
+		}
+		m, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		if len(m) == 0 {
+			return nil, fmt.Errorf("%s: no such file", arg)
+		}
+		files = append(files, m...)
+	}
+	return files, nil
+}
+
+// parseSizes parses a comma-separated list of sizes in pixels.
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid size %q", f)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// formatOf returns the image format for an output path.
+func formatOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".ico":
+		return "ico"
+	}
+	return "png"
+}
+
+// convert rasterizes the SVG file in to the image file out, or to standard
+// output if out is "-". Errors are prefixed with the input path.
+func convert(in, out string, o options) error {
+	doc, err := svgg.LoadDocument(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	write, wf, err := draw(doc, o)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if out == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("%s: %w", in, err)
+	}
+	if o.worldFile && wf != nil {
+		f, err := os.Create(svgg.WorldFilePath(out))
+		if err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+		if _, err := wf.WriteTo(f); err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %w", in, err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+	}
+	return nil
+}
+
+// draw draws doc as o says and returns a function writing the image, and
+// the image's world file unless it is an icon.
+func draw(doc *svgg.Document, o options) (func(w io.Writer) error, *svgg.WorldFile, error) {
+	if o.format == "ico" {
+		ims, err := svgg.ExportIconSet(doc, o.icons)
+		if err != nil {
+			return nil, nil, err
+		}
+		list := make([]image.Image, len(ims))
+		for i, im := range ims {
+			list[i] = im
+		}
+		return func(w io.Writer) error { return svgg.EncodeICO(w, list) }, nil, nil
+	}
+	w, h := size(doc, o)
+	if w <= 0 || h <= 0 {
+		return nil, nil, fmt.Errorf("empty image size %dx%d", w, h)
+	}
+	// the viewBox is fitted to the output size as the document's
+	// preserveAspectRatio says
+	doc.Width, doc.Height = float64(w), float64(h)
+	bg := o.background
+	if bg == nil && o.format == "jpeg" {
+		bg = color.White
+	}
+	dc := gg.NewContext(w, h)
+	wf := &svgg.WorldFile{}
+	if err := doc.DrawWithOptions(dc, &svgg.RenderOptions{Background: bg, WorldFile: wf}); err != nil {
+		return nil, nil, err
+	}
+	return func(w io.Writer) error { return encode(w, dc.Image(), o) }, wf, nil
+}
+
+// encode writes im to w in the format of o.
+func encode(w io.Writer, im image.Image, o options) error {
+	switch o.format {
+	case "png":
+		return png.Encode(w, im)
+	case "jpeg":
+		return jpeg.Encode(w, im, &jpeg.Options{Quality: o.quality})
+	}
+	return fmt.Errorf("unknown format %q", o.format)
+}
+
+// size returns the size in pixels of the image of doc.
+func size(doc *svgg.Document, o options) (w, h int) {
+	switch {
+	case o.box > 0 && doc.Width > 0 && doc.Height > 0:
+		k := float64(o.box) / math.Max(doc.Width, doc.Height)
+		return int(math.Round(doc.Width * k)), int(math.Round(doc.Height * k))
+	case o.width > 0 && o.height > 0:
+		return o.width, o.height
+	case o.width > 0 && doc.Width > 0:
+		return o.width, int(math.Round(float64(o.width) * doc.Height / doc.Width))
+	case o.height > 0 && doc.Height > 0:
+		return int(math.Round(float64(o.height) * doc.Width / doc.Height)), o.height
+	}
+	k := o.scale * o.dpi / 96
+	return int(math.Ceil(doc.Width*k - 1e-9)), int(math.Ceil(doc.Height*k - 1e-9))
+}
+
+// parseColor parses a color name, such as "white", or a hex color in the
+// form #rgb, #rgba, #rrggbb or #rrggbbaa.
+func parseColor(s string) (color.Color, error) {
+	switch strings.ToLower(s) {
+	case "white":
+		return color.White, nil
+	case "black":
+		return color.Black, nil
+	case "none", "transparent":
+		return color.Transparent, nil
+	}
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 3 || len(h) == 4 {
+		var b strings.Builder
+		for _, c := range h {
+			b.WriteRune(c)
+			b.WriteRune(c)
+		}
+		h = b.String()
+	}
+	if len(h) == 6 {
+		h += "ff"
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if len(h) != 8 || err != nil {
+		return nil, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
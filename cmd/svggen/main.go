@@ -0,0 +1,283 @@
+// Command svggen compiles SVG files into a Go source file, so that
+// applications can embed icons without parsing them at runtime.
+//
+// Usage:
+//
+//	svggen [-pkg name] [-var Icons] [-o icons.go] inputs...
+//
+// Inputs may be SVG files, directories, whose .svg and .svgz files are
+// read, or glob patterns such as "icons/*.svg". The generated file holds a
+// map from each file's name without its extension to an *svgg.Document
+// written as a Go literal, with the data of every path compiled, so that
+// neither the XML nor the path data is parsed at runtime. Documents with
+// invalid path data are reported when generating. It is meant to be run
+// by go generate:
+//
+//	//go:generate svggen -o icons_gen.go icons
+//
+// The package name defaults to $GOPACKAGE, which go generate sets.
+// Whitespace between elements is dropped outside of text.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/engelsjk/svgg"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("svggen: ")
+	var pkg, name, out string
+	flag.StringVar(&pkg, "pkg", os.Getenv("GOPACKAGE"), "`package` of the generated file (default $GOPACKAGE, or icons)")
+	flag.StringVar(&name, "var", "Icons", "`name` of the generated map of documents")
+	flag.StringVar(&out, "o", "icons.go", "output `path`, or - for standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: svggen [flags] inputs...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if pkg == "" {
+		pkg = "icons"
+	}
+	inputs, err := expandInputs(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(pkg, name, inputs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if out == "-" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = os.WriteFile(out, src, 0644)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// expandInputs turns the arguments into a list of files, reading the SVG
+// files of directories and expanding glob patterns.
+func expandInputs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err == nil {
+			if !fi.IsDir() {
+				files = append(files, arg)
+				continue
+			}
+			for _, pattern := range []string{"*.svg", "*.svgz"} {
+				m, err := filepath.Glob(filepath.Join(arg, pattern))
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, m...)
+			}
+			continue
+		}
+		m, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		if len(m) == 0 {
+			return nil, fmt.Errorf("%s: no such file", arg)
+		}
+		files = append(files, m...)
+	}
+	return files, nil
+}
+
+// generate returns the formatted source of package pkg, declaring the map
+// name of the documents in files by name.
+func generate(pkg, name string, files []string) ([]byte, error) {
+	docs := make(map[string]*svgg.Document)
+	paths := make(map[string]string)
+	for _, f := range files {
+		n := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		if prev, ok := paths[n]; ok {
+			return nil, fmt.Errorf("%s and %s have the same name %q", prev, f, n)
+		}
+		doc, err := svgg.LoadDocument(f)
+		if err != nil {
+			return nil, err
+		}
+		docs[n], paths[n] = doc, f
+	}
+	names := make([]string, 0, len(docs))
+	for n := range docs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	g := &generator{}
+	for _, n := range names {
+		doc := docs[n]
+		fmt.Fprintf(&g.body, "%q: {Root: &svgg.Element", n)
+		if err := g.element(doc.Root, false); err != nil {
+			return nil, fmt.Errorf("%s: %w", paths[n], err)
+		}
+		vb := doc.ViewBox
+		fmt.Fprintf(&g.body, ",\nWidth: %s, Height: %s, ViewBox: svgg.ViewBox{X: %s, Y: %s, W: %s, H: %s}},\n",
+			g.float(doc.Width), g.float(doc.Height), g.float(vb.X), g.float(vb.Y), g.float(vb.W), g.float(vb.H))
+		if g.err != nil {
+			return nil, fmt.Errorf("%s: %w", paths[n], g.err)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by svggen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	if g.attrs {
+		b.WriteString("\t\"encoding/xml\"\n\n")
+	}
+	b.WriteString("\t\"github.com/engelsjk/svgg\"\n")
+	if g.points {
+		b.WriteString("\t\"github.com/fogleman/gg\"\n")
+	}
+	b.WriteString(")\n\n")
+	fmt.Fprintf(&b, "// %s holds the compiled documents by the names of their SVG files.\n", name)
+	fmt.Fprintf(&b, "var %s = map[string]*svgg.Document{\n", name)
+	b.Write(g.body.Bytes())
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// generator writes element trees as Go composite literals.
+type generator struct {
+	body   bytes.Buffer
+	attrs  bool  // whether encoding/xml is used
+	points bool  // whether gg is used
+	err    error // the first number that has no literal
+}
+
+// segmentOps are the names of the segment operations.
+var segmentOps = map[svgg.SegmentOp]string{
+	svgg.MoveOp:  "svgg.MoveOp",
+	svgg.LineOp:  "svgg.LineOp",
+	svgg.QuadOp:  "svgg.QuadOp",
+	svgg.CubicOp: "svgg.CubicOp",
+	svgg.ArcOp:   "svgg.ArcOp",
+	svgg.CloseOp: "svgg.CloseOp",
+}
+
+// element writes the literal of e and its children, without its type. inText is set if e is
+// within a text element, where whitespace is kept. The path data of path
+// elements is compiled, and an error is returned if it is invalid.
+func (g *generator) element(e *svgg.Element, inText bool) error {
+	b := &g.body
+	fmt.Fprintf(b, "{Name: %q", e.Name)
+	if e.Space != "" {
+		fmt.Fprintf(b, ", Space: %q", e.Space)
+	}
+	if len(e.Attrs) > 0 {
+		g.attrs = true
+		b.WriteString(", Attrs: []xml.Attr{\n")
+		for _, a := range e.Attrs {
+			b.WriteString("{Name: xml.Name{")
+			if a.Name.Space != "" {
+				fmt.Fprintf(b, "Space: %q, ", a.Name.Space)
+			}
+			fmt.Fprintf(b, "Local: %q}, Value: %q},\n", a.Name.Local, a.Value)
+		}
+		b.WriteString("}")
+	}
+	if e.Name == "path" {
+		if err := g.compiled(e.Attr("d")); err != nil {
+			return err
+		}
+	}
+	// the tail is part of the parent's content, the text of e's own
+	inner := inText || e.Name == "text"
+	if keepText(e.Text, inner) {
+		fmt.Fprintf(b, ", Text: %q", e.Text)
+	}
+	if keepText(e.Tail, inText) {
+		fmt.Fprintf(b, ", Tail: %q", e.Tail)
+	}
+	if len(e.Children) > 0 {
+		b.WriteString(", Children: []*svgg.Element{\n")
+		for _, c := range e.Children {
+			if err := g.element(c, inner); err != nil {
+				return err
+			}
+			b.WriteString(",\n")
+		}
+		b.WriteString("}")
+	}
+	b.WriteString("}")
+	return g.err
+}
+
+// compiled writes the Compiled field of a path element with path data d.
+func (g *generator) compiled(d string) error {
+	c := &svgg.CompiledPath{}
+	p := svgg.NewSinkParser(c)
+	p.ErrorMode = svgg.CollectErrorMode
+	if err := p.CompilePath(d); err != nil {
+		return fmt.Errorf("path data %q: %w", d, err)
+	}
+	if len(c.Segments) == 0 {
+		return nil
+	}
+	g.points = true
+	b := &g.body
+	b.WriteString(", Compiled: &svgg.CompiledPath{Segments: []svgg.Segment{\n")
+	for _, s := range c.Segments {
+		fmt.Fprintf(b, "{Op: %s, P: [3]gg.Point{", segmentOps[s.Op])
+		n := 1
+		switch s.Op {
+		case svgg.QuadOp:
+			n = 2
+		case svgg.CubicOp:
+			n = 3
+		}
+		for i, pt := range s.P[:n] {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, "{X: %s, Y: %s}", g.float(pt.X), g.float(pt.Y))
+		}
+		b.WriteString("}")
+		if s.Op == svgg.ArcOp {
+			a := s.Arc
+			fmt.Fprintf(b, ", Arc: svgg.Arc{Cx: %s, Cy: %s, Rx: %s, Ry: %s, Phi: %s, Theta1: %s, DTheta: %s}",
+				g.float(a.Cx), g.float(a.Cy), g.float(a.Rx), g.float(a.Ry), g.float(a.Phi), g.float(a.Theta1), g.float(a.DTheta))
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}}")
+	return g.err
+}
+
+// float returns the shortest literal of v that reads back exactly. NaN and
+// infinities have none and are recorded in g.err.
+func (g *generator) float(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		if g.err == nil {
+			g.err = fmt.Errorf("number %v has no Go literal", v)
+		}
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// keepText reports whether character data s is written: whitespace only
+// matters within text.
+func keepText(s string, inText bool) bool {
+	return s != "" && (inText || strings.TrimSpace(s) != "")
+}
@@ -0,0 +1,219 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// CompareOptions configures Compare. A nil *CompareOptions compares
+// exactly, without a background.
+type CompareOptions struct {
+	// Tolerance is how far, from 0 to 255, the red, green, blue and alpha
+	// channels of a pixel may stray before the pixel counts as different.
+	Tolerance [4]uint8
+
+	// Background, if non-nil, is composited under both images before they
+	// are compared, so that a transparent image and one drawn on an opaque
+	// background of that color compare equal. Otherwise premultiplied
+	// colors are compared, so fully transparent pixels are all equal.
+	Background color.Color
+}
+
+// Comparison is the result of Compare.
+type Comparison struct {
+	// DiffPixels counts the pixels with a channel beyond its tolerance,
+	// and DiffFraction is their fraction of all pixels.
+	DiffPixels   int
+	DiffFraction float64
+
+	// MaxError is the largest difference, from 0 to 255, of each of the
+	// red, green, blue and alpha channels, and MeanError the mean
+	// difference over all channels and pixels, from 0 to 1.
+	MaxError  [4]uint8
+	MeanError float64
+
+	// MeanDeltaE and MaxDeltaE are the mean and largest CIE76 color
+	// difference of the pixels, composited over the Background or white,
+	// where a difference of about 2.3 is just noticeable.
+	MeanDeltaE, MaxDeltaE float64
+
+	// SSIM is the structural similarity of the images' luma, from 1 for
+	// identical images down to 0 or less for unrelated ones, which tracks
+	// perceived quality better than per-pixel errors.
+	SSIM float64
+
+	// Diff shows where the images differ: the pixels beyond tolerance in
+	// red, over a faded gray copy of the first image.
+	Diff *image.RGBA
+}
+
+// Compare compares two images of the same size pixel by pixel and
+// perceptually, for golden image tests. It returns an error if the sizes
+// differ.
+func Compare(a, b image.Image, opts *CompareOptions) (*Comparison, error) {
+	var o CompareOptions
+	if opts != nil {
+		o = *opts
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return nil, fmt.Errorf("svgg: comparing images of %dx%d and %dx%d pixels", ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	}
+	w, h := ab.Dx(), ab.Dy()
+	bg := o.Background
+	if bg == nil {
+		bg = color.White
+	}
+	c := &Comparison{Diff: image.NewRGBA(image.Rect(0, 0, w, h))}
+	if w == 0 || h == 0 {
+		c.SSIM = 1
+		return c, nil
+	}
+	lumaA := make([]float64, w*h)
+	lumaB := make([]float64, w*h)
+	var errSum, deltaSum float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pa, pb := a.At(ab.Min.X+x, ab.Min.Y+y), b.At(bb.Min.X+x, bb.Min.Y+y)
+			ca, cb := channels(pa, o.Background), channels(pb, o.Background)
+			differs := false
+			for i := range ca {
+				d := ca[i] - cb[i]
+				if d < 0 {
+					d = -d
+				}
+				if uint8(d) > c.MaxError[i] {
+					c.MaxError[i] = uint8(d)
+				}
+				if d > int(o.Tolerance[i]) {
+					differs = true
+				}
+				errSum += float64(d)
+			}
+
+			oa, ob := over(pa, bg), over(pb, bg)
+			de := deltaE(oa, ob)
+			deltaSum += de
+			c.MaxDeltaE = math.Max(c.MaxDeltaE, de)
+			lumaA[y*w+x] = luma(oa)
+			lumaB[y*w+x] = luma(ob)
+
+			if differs {
+				c.DiffPixels++
+				c.Diff.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				v := uint8(255 - (255-lumaA[y*w+x])/4)
+				c.Diff.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+			}
+		}
+	}
+	n := float64(w * h)
+	c.DiffFraction = float64(c.DiffPixels) / n
+	c.MeanError = errSum / (n * 4 * 255)
+	c.MeanDeltaE = deltaSum / n
+	c.SSIM = ssim(lumaA, lumaB, w, h)
+	return c, nil
+}
+
+// channels returns the 8-bit red, green, blue and alpha channels of c,
+// premultiplied, or composited over bg if it is non-nil.
+func channels(c color.Color, bg color.Color) [4]int {
+	if bg != nil {
+		o := over(c, bg)
+		return [4]int{int(o[0]), int(o[1]), int(o[2]), 255}
+	}
+	r, g, b, a := c.RGBA()
+	return [4]int{int(r >> 8), int(g >> 8), int(b >> 8), int(a >> 8)}
+}
+
+// over returns the 8-bit channels of c composited over the color bg, whose
+// own alpha is ignored.
+func over(c, bg color.Color) [3]uint8 {
+	r, g, b, a := c.RGBA()
+	br, bgr, bb, _ := bg.RGBA()
+	k := 0xffff - a
+	return [3]uint8{
+		uint8((r + br*k/0xffff) >> 8),
+		uint8((g + bgr*k/0xffff) >> 8),
+		uint8((b + bb*k/0xffff) >> 8),
+	}
+}
+
+// luma returns the luma of an sRGB color, from 0 to 255.
+func luma(c [3]uint8) float64 {
+	return 0.299*float64(c[0]) + 0.587*float64(c[1]) + 0.114*float64(c[2])
+}
+
+// deltaE returns the CIE76 difference of two sRGB colors: their distance
+// in CIELAB.
+func deltaE(a, b [3]uint8) float64 {
+	la, aa, ba := lab(a)
+	lb, ab, bb := lab(b)
+	return math.Sqrt((la-lb)*(la-lb) + (aa-ab)*(aa-ab) + (ba-bb)*(ba-bb))
+}
+
+// lab converts an sRGB color to CIELAB under the D65 white point.
+func lab(c [3]uint8) (l, a, b float64) {
+	r, g, bl := srgbToLinear[c[0]], srgbToLinear[c[1]], srgbToLinear[c[2]]
+	x := (0.4124*r + 0.3576*g + 0.1805*bl) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*bl
+	z := (0.0193*r + 0.1192*g + 0.9505*bl) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// ssimWindow is the edge length of the windows over which SSIM is
+// computed.
+const ssimWindow = 8
+
+// ssim returns the mean structural similarity of the w by h luma planes
+// a and b over windows of ssimWindow pixels, or one window for smaller
+// images.
+func ssim(a, b []float64, w, h int) float64 {
+	const c1, c2 = (0.01 * 255) * (0.01 * 255), (0.03 * 255) * (0.03 * 255)
+	sum, windows := 0.0, 0
+	for y0 := 0; y0 < h; y0 += ssimWindow {
+		for x0 := 0; x0 < w; x0 += ssimWindow {
+			x1, y1 := x0+ssimWindow, y0+ssimWindow
+			if x1 > w {
+				x1 = w
+			}
+			if y1 > h {
+				y1 = h
+			}
+			var ma, mb float64
+			n := float64((x1 - x0) * (y1 - y0))
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					ma += a[y*w+x]
+					mb += b[y*w+x]
+				}
+			}
+			ma /= n
+			mb /= n
+			var va, vb, cov float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					da, db := a[y*w+x]-ma, b[y*w+x]-mb
+					va += da * da
+					vb += db * db
+					cov += da * db
+				}
+			}
+			va /= n
+			vb /= n
+			cov /= n
+			sum += (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			windows++
+		}
+	}
+	return sum / float64(windows)
+}
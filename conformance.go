@@ -0,0 +1,184 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// DefaultConformanceThreshold and DefaultConformanceTolerance are the
+// Threshold and Tolerance used by RunConformance when they are zero and
+// Exact is not set.
+const (
+	DefaultConformanceThreshold = 32
+	DefaultConformanceTolerance = 0.01
+)
+
+// ConformanceOptions configures RunConformance. A nil *ConformanceOptions
+// uses the defaults described with each field.
+type ConformanceOptions struct {
+	// Threshold is how far, from 0 to 255, a color channel of a pixel may
+	// stray from the reference before the pixel counts as different. Both
+	// images are composited over white first, so transparent and white
+	// backgrounds compare equal. Zero means DefaultConformanceThreshold.
+	Threshold uint8
+
+	// Tolerance is the fraction of pixels that may differ for a test to
+	// pass. Zero means DefaultConformanceTolerance.
+	Tolerance float64
+
+	// Exact uses Threshold and Tolerance as given, so that zero means
+	// zero rather than the default, and with both zero every pixel must
+	// match its reference, as golden image tests need.
+	Exact bool
+
+	// Reference maps the path of a test's SVG in the suite to the path of
+	// its reference PNG. If nil, the reference is the PNG of the same name
+	// next to the SVG, as in the resvg test suite. The W3C suite, whose
+	// references for svg/name.svg are png/full-name.png, needs
+	//
+	//	func(name string) string {
+	//		return "png/full-" + strings.TrimSuffix(path.Base(name), ".svg") + ".png"
+	//	}
+	Reference func(name string) string
+
+	// Options configures how the tests are drawn. It may be nil.
+	Options *RenderOptions
+}
+
+// ConformanceResult is the outcome of one test of a conformance suite.
+type ConformanceResult struct {
+	Name      string // path of the test's SVG in the suite
+	Reference string // path of its reference PNG
+
+	// Diff is the fraction of pixels that differ from the reference, and
+	// Comparison the full comparison, including an image of the
+	// differences. Comparison is nil if the test could not be run.
+	Diff       float64
+	Comparison *Comparison
+	Passed     bool
+
+	// Err is why the test could not be run, such as a missing reference
+	// or a document that cannot be decoded or drawn.
+	Err error
+}
+
+// ConformanceReport holds the results of RunConformance, in the order of
+// the tests' paths, with counts of the tests that passed, failed and could
+// not be run.
+type ConformanceReport struct {
+	Results                []ConformanceResult
+	Passed, Failed, Errors int
+}
+
+// PassRate returns the fraction of the tests that passed.
+func (r *ConformanceReport) PassRate() float64 {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	return float64(r.Passed) / float64(len(r.Results))
+}
+
+// RunConformance draws every .svg file in suite, such as a checkout of the
+// resvg or W3C SVG test suite opened with os.DirFS, and compares it with
+// its reference PNG, so that users can measure the fidelity of their
+// build. Each test is drawn at the size of its reference, with relative
+// references such as <image> files read from the suite. The error is only
+// non-nil if the suite cannot be walked; tests that cannot be run are
+// reported in their results.
+func RunConformance(suite fs.FS, opts *ConformanceOptions) (*ConformanceReport, error) {
+	var o ConformanceOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Threshold == 0 && !o.Exact {
+		o.Threshold = DefaultConformanceThreshold
+	}
+	if o.Tolerance == 0 && !o.Exact {
+		o.Tolerance = DefaultConformanceTolerance
+	}
+	if o.Reference == nil {
+		o.Reference = func(name string) string {
+			return strings.TrimSuffix(name, path.Ext(name)) + ".png"
+		}
+	}
+
+	report := &ConformanceReport{}
+	err := fs.WalkDir(suite, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".svg" {
+			return nil
+		}
+		res := ConformanceResult{Name: name, Reference: o.Reference(name)}
+		res.Comparison, res.Err = runConformanceTest(suite, res.Name, res.Reference, &o)
+		if res.Comparison != nil {
+			res.Diff = res.Comparison.DiffFraction
+		}
+		switch {
+		case res.Err != nil:
+			report.Errors++
+		case res.Diff <= o.Tolerance:
+			res.Passed = true
+			report.Passed++
+		default:
+			report.Failed++
+		}
+		report.Results = append(report.Results, res)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// runConformanceTest draws the SVG name of suite at the size of the PNG
+// ref and compares it with the PNG.
+func runConformanceTest(suite fs.FS, name, ref string, o *ConformanceOptions) (*Comparison, error) {
+	want, err := readPNG(suite, ref)
+	if err != nil {
+		return nil, fmt.Errorf("svgg: reference: %w", err)
+	}
+	f, err := suite.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := NewDecoder(f).Decode()
+	if err != nil {
+		return nil, err
+	}
+	doc.Resolver = ResolverFunc(func(href string) (io.ReadCloser, error) {
+		if strings.Contains(href, ":") {
+			return nil, fmt.Errorf("%w %q", ErrExternalRef, href)
+		}
+		return suite.Open(path.Join(path.Dir(name), href))
+	})
+	b := want.Bounds()
+	im := NewImage(doc, b.Dx(), b.Dy(), o.Options)
+	if err := im.Err(); err != nil {
+		return nil, err
+	}
+	t := o.Threshold
+	return Compare(im.Image(), want, &CompareOptions{
+		Tolerance:  [4]uint8{t, t, t, t},
+		Background: color.White,
+	})
+}
+
+// readPNG decodes the PNG file name of fsys.
+func readPNG(fsys fs.FS, name string) (image.Image, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
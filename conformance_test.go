@@ -0,0 +1,56 @@
+package svgg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestConformanceSuite(t *testing.T) {
+	report, err := RunConformance(os.DirFS("testdata/conformance"), &ConformanceOptions{Exact: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) == 0 {
+		t.Fatal("no tests found")
+	}
+	for _, r := range report.Results {
+		if !r.Passed {
+			t.Errorf("%s: %d pixels differ, error %v", r.Name, r.Comparison.DiffPixels, r.Err)
+		}
+	}
+}
+
+func TestConformanceExact(t *testing.T) {
+	// the reference is one pixel off
+	ref := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	ref.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, ref); err != nil {
+		t.Fatal(err)
+	}
+	suite := fstest.MapFS{
+		"empty.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`)},
+		"empty.png": {Data: buf.Bytes()},
+	}
+	for _, tt := range []struct {
+		opts *ConformanceOptions
+		pass bool
+	}{
+		{nil, true},
+		{&ConformanceOptions{Exact: true}, false},
+		{&ConformanceOptions{Exact: true, Tolerance: 0.01}, true},
+	} {
+		report, err := RunConformance(suite, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := report.Results[0]; r.Passed != tt.pass || r.Err != nil {
+			t.Errorf("%+v: passed %v, error %v; want passed %v", tt.opts, r.Passed, r.Err, tt.pass)
+		}
+	}
+}
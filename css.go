@@ -0,0 +1,392 @@
+package svgg
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stylesheet holds the rules and keyframes of the <style> elements of a
+// document. Only what CSS animations need is read from it; rules do not
+// otherwise style elements.
+type stylesheet struct {
+	rules     []cssRule
+	keyframes map[string][]keyframe
+}
+
+// cssRule is a rule with a single complex selector.
+type cssRule struct {
+	sel   []cssCompound
+	spec  int // specificity, as ids, classes and types in base 1000
+	order int
+	decls [][2]string
+}
+
+// cssCompound is a compound selector, such as "circle.dot:nth-child(2)",
+// and the combinator joining it to the compound before it: ' ' for a
+// descendant, '>' for a child, or 0 for the first.
+type cssCompound struct {
+	combinator byte
+	tag        string
+	id         string
+	classes    []string
+	nth        [][2]int // nth-child(a n + b) conditions
+	lastChild  bool
+}
+
+// keyframe is one step of a @keyframes rule.
+type keyframe struct {
+	offset float64
+	props  map[string]string
+	timing easing // nil if the step does not set animation-timing-function
+}
+
+// parseStylesheets parses the <style> elements of the document.
+func (doc *Document) parseStylesheets() *stylesheet {
+	ss := &stylesheet{keyframes: make(map[string][]keyframe)}
+	var walk func(e *Element)
+	walk = func(e *Element) {
+		if e.Name == "style" {
+			ss.parse(e.Text)
+			return
+		}
+		for _, c := range e.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+	return ss
+}
+
+// parse adds the rules and keyframes of the CSS text s. Other at-rules and
+// rules with selectors that cannot be matched are skipped.
+func (ss *stylesheet) parse(s string) {
+	s = stripComments(s)
+	for {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return
+		}
+		open := strings.IndexByte(s, '{')
+		if s[0] == '@' {
+			semi := strings.IndexByte(s, ';')
+			if semi >= 0 && (open < 0 || semi < open) {
+				// @import, @charset and the like
+				s = s[semi+1:]
+				continue
+			}
+		}
+		if open < 0 {
+			return
+		}
+		prelude := strings.TrimSpace(s[:open])
+		body, rest := block(s[open+1:])
+		s = rest
+		if strings.HasPrefix(prelude, "@") {
+			name := strings.Fields(prelude)
+			if len(name) == 2 && strings.HasSuffix(name[0], "keyframes") {
+				ss.keyframes[name[1]] = parseKeyframes(body)
+			}
+			continue
+		}
+		decls := parseDeclarations(body)
+		for _, sel := range strings.Split(prelude, ",") {
+			compounds, spec, ok := parseSelector(sel)
+			if ok {
+				ss.rules = append(ss.rules, cssRule{sel: compounds, spec: spec, order: len(ss.rules), decls: decls})
+			}
+		}
+	}
+}
+
+// stripComments removes /* */ comments from s.
+func stripComments(s string) string {
+	for {
+		i := strings.Index(s, "/*")
+		if i < 0 {
+			return s
+		}
+		j := strings.Index(s[i+2:], "*/")
+		if j < 0 {
+			return s[:i]
+		}
+		s = s[:i] + " " + s[i+2+j+2:]
+	}
+}
+
+// block splits s, which follows an opening brace, into the contents of the
+// block and the text after its closing brace.
+func block(s string) (body, rest string) {
+	depth := 1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[:i], s[i+1:]
+			}
+		}
+	}
+	return s, ""
+}
+
+// parseDeclarations parses a declaration block into property and value
+// pairs, dropping !important.
+func parseDeclarations(s string) [][2]string {
+	var decls [][2]string
+	for _, d := range strings.Split(s, ";") {
+		kv := strings.SplitN(d, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(kv[1]), "!important"))
+		decls = append(decls, [2]string{strings.ToLower(strings.TrimSpace(kv[0])), v})
+	}
+	return decls
+}
+
+// parseKeyframes parses the body of a @keyframes rule into steps sorted by
+// offset.
+func parseKeyframes(s string) []keyframe {
+	var frames []keyframe
+	for {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			break
+		}
+		sel := s[:open]
+		body, rest := block(s[open+1:])
+		s = rest
+		props := make(map[string]string)
+		var timing easing
+		for _, d := range parseDeclarations(body) {
+			if d[0] == "animation-timing-function" {
+				timing, _ = parseEasing(d[1])
+				continue
+			}
+			props[d[0]] = d[1]
+		}
+		for _, k := range strings.Split(sel, ",") {
+			var offset float64
+			switch k = strings.TrimSpace(k); k {
+			case "from":
+				offset = 0
+			case "to":
+				offset = 1
+			default:
+				f, err := strconv.ParseFloat(strings.TrimSuffix(k, "%"), 64)
+				if err != nil || !strings.HasSuffix(k, "%") || f < 0 || f > 100 {
+					continue
+				}
+				offset = f / 100
+			}
+			frames = append(frames, keyframe{offset: offset, props: props, timing: timing})
+		}
+	}
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].offset < frames[j].offset })
+	return frames
+}
+
+// parseSelector parses a complex selector made of type, universal, id and
+// class selectors, the :first-child, :last-child and :nth-child pseudo
+// classes, and descendant and child combinators. ok is false for any
+// other selector.
+func parseSelector(s string) (sel []cssCompound, spec int, ok bool) {
+	s = strings.TrimSpace(strings.Replace(s, ">", " > ", -1))
+	var comb byte
+	for _, f := range strings.Fields(s) {
+		if f == ">" {
+			if comb != ' ' {
+				return nil, 0, false
+			}
+			comb = '>'
+			continue
+		}
+		c := cssCompound{combinator: comb}
+		for i := 0; i < len(f); {
+			j := i + 1
+			for j < len(f) && !strings.ContainsRune("#.:", rune(f[j])) {
+				j++
+			}
+			part := f[i:j]
+			switch {
+			case part[0] == '#':
+				c.id = part[1:]
+				spec += 1000000
+			case part[0] == '.':
+				c.classes = append(c.classes, part[1:])
+				spec += 1000
+			case part[0] == ':':
+				if strings.HasPrefix(part, ":nth-child(") {
+					// the argument may hold no separators, so scan to the
+					// closing parenthesis
+					k := strings.IndexByte(f[i:], ')')
+					if k < 0 {
+						return nil, 0, false
+					}
+					j = i + k + 1
+					part = f[i:j]
+				}
+				switch {
+				case part == ":first-child":
+					c.nth = append(c.nth, [2]int{0, 1})
+				case part == ":last-child":
+					c.lastChild = true
+				case strings.HasPrefix(part, ":nth-child(") && strings.HasSuffix(part, ")"):
+					ab, ok := parseNth(part[len(":nth-child(") : len(part)-1])
+					if !ok {
+						return nil, 0, false
+					}
+					c.nth = append(c.nth, ab)
+				default:
+					return nil, 0, false
+				}
+				spec += 1000
+			case i == 0 && part == "*":
+			case i == 0 && isLetter(part[0]):
+				c.tag = part
+				spec++
+			default:
+				return nil, 0, false
+			}
+			i = j
+		}
+		sel = append(sel, c)
+		comb = ' '
+	}
+	if len(sel) == 0 || comb == '>' {
+		return nil, 0, false
+	}
+	return sel, spec, true
+}
+
+// parseNth parses the argument of :nth-child, such as "2", "odd" or
+// "3n+1", into a and b.
+func parseNth(s string) ([2]int, bool) {
+	s = strings.ToLower(strings.Replace(s, " ", "", -1))
+	switch s {
+	case "odd":
+		return [2]int{2, 1}, true
+	case "even":
+		return [2]int{2, 0}, true
+	}
+	n := strings.IndexByte(s, 'n')
+	if n < 0 {
+		b, err := strconv.Atoi(s)
+		return [2]int{0, b}, err == nil
+	}
+	var a, b int
+	switch s[:n] {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		var err error
+		if a, err = strconv.Atoi(s[:n]); err != nil {
+			return [2]int{}, false
+		}
+	}
+	if rest := s[n+1:]; rest != "" {
+		var err error
+		if b, err = strconv.Atoi(strings.TrimPrefix(rest, "+")); err != nil {
+			return [2]int{}, false
+		}
+	}
+	return [2]int{a, b}, true
+}
+
+// cssNode is an element with its position among its siblings, as needed
+// to match selectors.
+type cssNode struct {
+	e        *Element
+	index    int // 1-based
+	siblings int
+}
+
+// matches reports whether the last node of path, which runs from the root
+// element down, is matched by sel.
+func matches(sel []cssCompound, path []cssNode) bool {
+	if len(sel) == 0 {
+		return true
+	}
+	if len(path) == 0 {
+		return false
+	}
+	c := sel[len(sel)-1]
+	if !c.matches(path[len(path)-1]) {
+		return false
+	}
+	switch c.combinator {
+	case '>':
+		return matches(sel[:len(sel)-1], path[:len(path)-1])
+	case ' ':
+		for i := len(path) - 1; i > 0; i-- {
+			if matches(sel[:len(sel)-1], path[:i]) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matches reports whether the compound selector matches n.
+func (c cssCompound) matches(n cssNode) bool {
+	e := n.e
+	if c.tag != "" && c.tag != e.Name {
+		return false
+	}
+	if c.id != "" && c.id != e.Attr("id") {
+		return false
+	}
+	classes := strings.Fields(e.Attr("class"))
+	for _, want := range c.classes {
+		found := false
+		for _, have := range classes {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, ab := range c.nth {
+		a, b := ab[0], ab[1]
+		if a == 0 {
+			if n.index != b {
+				return false
+			}
+		} else if k := n.index - b; k%a != 0 || k/a < 0 {
+			return false
+		}
+	}
+	return !c.lastChild || n.index == n.siblings
+}
+
+// declarations returns the declarations of the rules matching the last
+// node of path, in cascade order, followed by those of its style
+// attribute.
+func (ss *stylesheet) declarations(path []cssNode) [][2]string {
+	var matched []cssRule
+	for _, r := range ss.rules {
+		if matches(r.sel, path) {
+			matched = append(matched, r)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].spec != matched[j].spec {
+			return matched[i].spec < matched[j].spec
+		}
+		return matched[i].order < matched[j].order
+	})
+	var decls [][2]string
+	for _, r := range matched {
+		decls = append(decls, r.decls...)
+	}
+	return append(decls, parseDeclarations(path[len(path)-1].e.Attr("style"))...)
+}
@@ -0,0 +1,163 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// DebugOverlay selects the diagnostics RenderOptions.Debug draws over a
+// document, for comparing its rendering with a browser's.
+type DebugOverlay uint8
+
+const (
+	// DebugPoints marks the end points of path segments, and the control
+	// points of curves with the handles joining them to their ends.
+	DebugPoints DebugOverlay = 1 << iota
+
+	// DebugBounds outlines the bounding box of each shape, text and image
+	// in its own user space.
+	DebugBounds
+
+	// DebugMarkers marks the vertices markers are placed on, with the
+	// direction of the path through them.
+	DebugMarkers
+
+	// DebugAll selects every diagnostic.
+	DebugAll = DebugPoints | DebugBounds | DebugMarkers
+)
+
+// debugLayer collects the diagnostics of a draw in device space, to be
+// drawn over the document once it is done.
+type debugLayer struct {
+	dc    *gg.Context // the context whose drawing is diagnosed
+	flags DebugOverlay
+
+	points   []float64    // x, y of segment end points
+	controls []float64    // x, y of curve control points
+	handles  []float64    // x0, y0, x1, y1 of control handles
+	boxes    [][8]float64 // corners of bounding boxes
+	anchors  []float64    // x, y, direction of marker vertices
+
+	x, y, x0, y0 float64 // current and subpath start points
+}
+
+// debugging reports whether the diagnostics in flags are collected for
+// what is being drawn. The contents of masks, patterns and markers are
+// left out.
+func (r *renderer) debugging(flags DebugOverlay) bool {
+	return r.debug != nil && r.debug.flags&flags != 0 && len(r.defs) == 0
+}
+
+// shape collects the points of the shape e in user space m.
+func (d *debugLayer) shape(e *Element, m gg.Matrix) {
+	buildShape(NewSinkParser(&transformSink{sink: d, m: m}), e)
+}
+
+// box collects the box x0, y0, x1, y1 in user space m.
+func (d *debugLayer) box(x0, y0, x1, y1 float64, m gg.Matrix) {
+	var c [8]float64
+	for i, p := range [4][2]float64{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}} {
+		c[2*i], c[2*i+1] = m.TransformPoint(p[0], p[1])
+	}
+	d.boxes = append(d.boxes, c)
+}
+
+// anchor collects the marker vertex v in user space m.
+func (d *debugLayer) anchor(v vertex, m gg.Matrix) {
+	x, y := m.TransformPoint(v.x, v.y)
+	a := bisector(v.in, v.out)
+	dx, dy := m.TransformVector(math.Cos(a), math.Sin(a))
+	d.anchors = append(d.anchors, x, y, math.Atan2(dy, dx))
+}
+
+func (d *debugLayer) MoveTo(x, y float64) {
+	d.x0, d.y0 = x, y
+	d.LineTo(x, y)
+}
+
+func (d *debugLayer) LineTo(x, y float64) {
+	d.points = append(d.points, x, y)
+	d.x, d.y = x, y
+}
+
+func (d *debugLayer) QuadraticTo(x1, y1, x, y float64) {
+	d.controls = append(d.controls, x1, y1)
+	d.handles = append(d.handles, d.x, d.y, x1, y1, x1, y1, x, y)
+	d.LineTo(x, y)
+}
+
+func (d *debugLayer) CubicTo(x1, y1, x2, y2, x, y float64) {
+	d.controls = append(d.controls, x1, y1, x2, y2)
+	d.handles = append(d.handles, d.x, d.y, x1, y1, x2, y2, x, y)
+	d.LineTo(x, y)
+}
+
+func (d *debugLayer) ClosePath() {
+	d.x, d.y = d.x0, d.y0
+}
+
+// draw draws the collected diagnostics to the layer's context, with lines
+// and marks sized in output pixels of pixel device pixels.
+func (d *debugLayer) draw(pixel float64) {
+	dc := d.dc
+	dc.Push()
+	defer dc.Pop()
+	dc.Identity()
+	dc.SetDash()
+	dc.SetLineWidth(pixel)
+
+	dc.SetRGBA(0, 0.5, 1, 0.8)
+	for _, c := range d.boxes {
+		dc.MoveTo(c[0], c[1])
+		for i := 2; i < 8; i += 2 {
+			dc.LineTo(c[i], c[i+1])
+		}
+		dc.ClosePath()
+	}
+	dc.Stroke()
+
+	dc.SetRGBA(0.5, 0.5, 0.5, 0.8)
+	for i := 0; i+3 < len(d.handles); i += 4 {
+		dc.MoveTo(d.handles[i], d.handles[i+1])
+		dc.LineTo(d.handles[i+2], d.handles[i+3])
+	}
+	dc.Stroke()
+	dc.SetRGBA(1, 0, 1, 0.9)
+	for i := 0; i+1 < len(d.controls); i += 2 {
+		dc.NewSubPath()
+		dc.DrawCircle(d.controls[i], d.controls[i+1], 2*pixel)
+	}
+	dc.Stroke()
+	dc.SetRGBA(1, 0, 0, 0.9)
+	for i := 0; i+1 < len(d.points); i += 2 {
+		dc.DrawRectangle(d.points[i]-1.5*pixel, d.points[i+1]-1.5*pixel, 3*pixel, 3*pixel)
+	}
+	dc.Fill()
+
+	dc.SetRGBA(0, 0.7, 0, 0.9)
+	for i := 0; i+2 < len(d.anchors); i += 3 {
+		x, y, a := d.anchors[i], d.anchors[i+1], d.anchors[i+2]
+		dc.NewSubPath()
+		dc.DrawCircle(x, y, 3*pixel)
+		dc.MoveTo(x, y)
+		dc.LineTo(x+10*pixel*math.Cos(a), y+10*pixel*math.Sin(a))
+	}
+	dc.Stroke()
+}
+
+// debugShape collects the diagnostics of the shape e, drawn in the current
+// user space.
+func (r *renderer) debugShape(e *Element) {
+	d := r.debug
+	m := currentMatrix(r.dc)
+	if r.debugging(DebugPoints) {
+		d.shape(e, m)
+	}
+	if r.debugging(DebugBounds) {
+		b := &boundsSink{}
+		if buildShape(NewSinkParser(b), e) == nil && b.started {
+			d.box(b.x0, b.y0, b.x1, b.y1, m)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package svgg
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// A Decoder reads an SVG document from an input stream. Its fields
+// configure how tolerant the XML parsing is; they must be set before
+// calling Decode.
+type Decoder struct {
+	// Lenient makes the decoder recover from common real-world breakage
+	// instead of failing: unknown entities are kept as text, HTML entities
+	// such as &nbsp; are expanded, mismatched or missing end tags are
+	// closed automatically, and duplicate attributes keep their first value.
+	// Path errors are still governed by the document's ErrorMode.
+	Lenient bool
+
+	// MaxEntityDepth limits how deeply entities declared in the document's
+	// DTD may reference each other. Zero means DefaultMaxEntityDepth.
+	MaxEntityDepth int
+
+	// MaxEntityExpansion limits the total number of bytes produced by
+	// expanding declared entities, protecting against "billion laughs"
+	// documents. Zero means DefaultMaxEntityExpansion.
+	MaxEntityExpansion int
+
+	// ExternalRefs sets how references to resources outside the document
+	// are treated. The default, KeepRefs, keeps them without fetching.
+	ExternalRefs RefPolicy
+
+	// Limits bounds the size and complexity of the document. The limits
+	// are also kept on the Document and enforced when drawing it.
+	Limits Limits
+
+	// MaxDecompressedSize caps the size of gzip-compressed (SVGZ) input
+	// after decompression. Zero means DefaultMaxDecompressedSize.
+	MaxDecompressedSize int64
+
+	r        io.Reader
+	warnings []error
+	limits   limitCounter
+
+	entities    []string // values of the declared entities, by placeholder
+	entityMark  string   // prefix of the placeholders
+	entityBytes int      // bytes added by expanding them
+}
+
+// DefaultMaxDecompressedSize is the default limit on decompressed SVGZ input.
+const DefaultMaxDecompressedSize = 64 << 20
+
+// NewDecoder returns a Decoder reading from r. Gzip-compressed (SVGZ)
+// input is detected and decompressed automatically.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode parses the whole input as an SVG document.
+func (d *Decoder) Decode() (*Document, error) {
+	r, err := d.decompress(d.r)
+	if err != nil {
+		return nil, err
+	}
+	lc := &lineCounter{r: r}
+	d.limits = limitCounter{Limits: d.Limits}
+	x := xml.NewDecoder(lc)
+	x.Entity = make(map[string]string)
+	if d.Lenient {
+		x.Strict = false
+		for k, v := range xml.HTMLEntity {
+			x.Entity[k] = v
+		}
+	}
+	root, err := d.readElements(x, lc)
+	if err != nil {
+		return nil, err
+	}
+	if root == nil || root.Name != "svg" {
+		return nil, ErrNoSVGElement
+	}
+	doc := &Document{Root: root, Limits: d.Limits, Warnings: d.warnings}
+	if err := doc.readViewport(); err != nil {
+		return nil, err
+	}
+	if err := d.limits.checkCanvas(doc); err != nil {
+		return nil, err
+	}
+	doc.indexIDs()
+	return doc, nil
+}
+
+// decompress returns a reader of the decompressed contents of r if it is
+// gzip-compressed, and a reader of r's contents otherwise.
+func (d *Decoder) decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	max := d.MaxDecompressedSize
+	if max <= 0 {
+		max = DefaultMaxDecompressedSize
+	}
+	return &sizeLimitReader{r: zr, n: max, max: max}, nil
+}
+
+// sizeLimitReader fails with ErrLimitExceeded once more than max bytes
+// have been read from r.
+type sizeLimitReader struct {
+	r   io.Reader
+	n   int64 // bytes remaining
+	max int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, fmt.Errorf("%w: decompressed size exceeds %d bytes", ErrLimitExceeded, l.max)
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, fmt.Errorf("%w: decompressed size exceeds %d bytes", ErrLimitExceeded, l.max)
+	}
+	return n, err
+}
+
+// ParseError is returned when a document cannot be parsed. It locates the
+// problem in the source and in the element tree.
+type ParseError struct {
+	Line, Column int    // 1-based position in the input; Column counts bytes
+	Path         string // slash-separated tag path of the enclosing element
+	Err          error
+}
+
+func (e *ParseError) Error() string {
+	msg := strings.TrimPrefix(e.Err.Error(), "svgg: ")
+	if e.Path == "" {
+		return fmt.Sprintf("svgg: line %d, column %d: %s", e.Line, e.Column, msg)
+	}
+	return fmt.Sprintf("svgg: line %d, column %d: in %s: %s", e.Line, e.Column, e.Path, msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// lineCounter records the offsets at which lines start as input is read,
+// so decoder offsets can be turned into line and column numbers.
+type lineCounter struct {
+	r      io.Reader
+	offset int64
+	lines  []int64
+}
+
+func (lc *lineCounter) Read(p []byte) (int, error) {
+	n, err := lc.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			lc.lines = append(lc.lines, lc.offset+int64(i)+1)
+		}
+	}
+	lc.offset += int64(n)
+	return n, err
+}
+
+// position returns the 1-based line and column of the byte at offset.
+func (lc *lineCounter) position(offset int64) (line, col int) {
+	i := sort.Search(len(lc.lines), func(i int) bool { return lc.lines[i] > offset })
+	start := int64(0)
+	if i > 0 {
+		start = lc.lines[i-1]
+	}
+	return i + 1, int(offset-start) + 1
+}
+
+// readElements builds the element tree from the token stream of x and
+// returns the root element. Errors are reported as a *ParseError.
+func (d *Decoder) readElements(x *xml.Decoder, lc *lineCounter) (*Element, error) {
+	var root *Element
+	var stack []*Element
+	fail := func(offset int64, err error) error {
+		names := make([]string, len(stack))
+		for i, e := range stack {
+			names[i] = e.Name
+		}
+		line, col := lc.position(offset)
+		return &ParseError{Line: line, Column: col, Path: strings.Join(names, "/"), Err: err}
+	}
+	for {
+		offset := x.InputOffset()
+		tok, err := x.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if d.Lenient && root != nil && isUnexpectedEOF(err) {
+				// treat a truncated document as if it were closed
+				break
+			}
+			return nil, fail(x.InputOffset(), err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := t.Attr
+			if d.Lenient {
+				attrs = dedupAttrs(attrs)
+			}
+			for i := range attrs {
+				if attrs[i].Value, err = d.expandEntities(attrs[i].Value); err != nil {
+					return nil, fail(offset, err)
+				}
+			}
+			e := &Element{Name: t.Name.Local, Space: t.Name.Space, Attrs: attrs}
+			if err := d.checkRefs(e); err != nil {
+				return nil, fail(offset, err)
+			}
+			if err := d.limits.addElement(e, len(stack)+1); err != nil {
+				return nil, fail(offset, err)
+			}
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, fail(offset, fmt.Errorf("unexpected second root element <%s>", e.Name))
+				}
+				root = e
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, e)
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.Directive:
+			if err := d.readDoctype(string(t), x.Entity); err != nil {
+				return nil, fail(offset, err)
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				text, err := d.expandEntities(string(t))
+				if err != nil {
+					return nil, fail(offset, err)
+				}
+				e := stack[len(stack)-1]
+				if n := len(e.Children); n > 0 {
+					e.Children[n-1].Tail += text
+				} else {
+					e.Text += text
+				}
+			}
+		}
+	}
+	return root, nil
+}
+
+// isUnexpectedEOF reports whether err is the syntax error returned for
+// input that ends inside an element.
+func isUnexpectedEOF(err error) bool {
+	se, ok := err.(*xml.SyntaxError)
+	return ok && se.Msg == "unexpected EOF"
+}
+
+// dedupAttrs drops repeated attributes, keeping the first occurrence.
+func dedupAttrs(attrs []xml.Attr) []xml.Attr {
+	out := attrs[:0]
+	seen := make(map[xml.Name]bool, len(attrs))
+	for _, a := range attrs {
+		if !seen[a.Name] {
+			seen[a.Name] = true
+			out = append(out, a)
+		}
+	}
+	return out
+}
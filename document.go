@@ -0,0 +1,228 @@
+package svgg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// A Document is a parsed SVG file. Documents hold the element tree as read
+// from the XML and are drawn to a gg.Context with Draw.
+
+// ErrNoSVGElement is returned when a document has no <svg> root element.
+var ErrNoSVGElement = errors.New("no svg element")
+
+// Element is a node of the SVG element tree.
+type Element struct {
+	Name     string     // local tag name, e.g. "path"
+	Space    string     // namespace URL, or the prefix if it is undeclared
+	Attrs    []xml.Attr // attributes in document order
+	Children []*Element
+	Text     string // character data inside the element before its first child
+	Tail     string // character data after the element, before its next sibling
+
+	// Compiled, if set, is the compiled d attribute of a <path>, which is
+	// drawn without parsing the path data again. SetAttr and RemoveAttr
+	// clear it when they change d; code changing d through Attrs must
+	// clear it too. See Document.CompilePaths.
+	Compiled *CompiledPath
+}
+
+// Attr returns the value of the attribute with the given local name,
+// or "" if it is not set.
+func (e *Element) Attr(name string) string {
+	v, _ := e.LookupAttr(name)
+	return v
+}
+
+// LookupAttr returns the value of the attribute with the given local name
+// and whether it is set.
+func (e *Element) LookupAttr(name string) (string, bool) {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// Document is a parsed SVG document.
+//
+// Drawing never modifies a Document, so once decoded it may be drawn by
+// any number of goroutines at once, each to its own gg.Context. This lets
+// services parse an icon set once and share it across requests. Changing
+// the exported fields or the element tree while drawing is not safe.
+type Document struct {
+	Root          *Element
+	Width, Height float64
+	ViewBox       ViewBox
+	ErrorMode     ErrorMode
+	ErrorHandler  ErrorHandler
+
+	// Resolver fetches external resources such as <image> targets. If nil,
+	// only data: URLs are loaded.
+	Resolver Resolver
+
+	// Fonts resolves the font-family of text. If nil, DefaultFonts is used.
+	Fonts *FontRegistry
+
+	// Limits bounds the resources used when drawing, such as the nesting
+	// of <use> references. It is copied from the Decoder.
+	Limits Limits
+
+	// Warnings holds problems the decoder recovered from.
+	Warnings []error
+
+	ids     map[string]*Element
+	classes map[string]map[string]string // properties set by SetClassStyle
+}
+
+// ViewBox is the user coordinate rectangle of an svg element.
+type ViewBox struct {
+	X, Y, W, H float64
+}
+
+// ReadDocument parses an SVG document from r.
+func ReadDocument(r io.Reader) (*Document, error) {
+	return NewDecoder(r).Decode()
+}
+
+// ParseDocument parses an SVG document from data.
+func ParseDocument(data []byte) (*Document, error) {
+	return ReadDocument(bytes.NewReader(data))
+}
+
+// LoadDocument parses the SVG document stored in the named file.
+func LoadDocument(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadDocument(f)
+}
+
+// indexIDs records the elements of the document by id. When ids are
+// repeated the first element wins.
+func (doc *Document) indexIDs() {
+	doc.ids = make(map[string]*Element)
+	var walk func(e *Element)
+	walk = func(e *Element) {
+		if id := e.Attr("id"); id != "" {
+			if _, ok := doc.ids[id]; !ok {
+				doc.ids[id] = e
+			}
+		}
+		for _, c := range e.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+}
+
+// lookupRef returns the element referenced by a local IRI such as "#icon".
+func (doc *Document) lookupRef(ref string) (*Element, error) {
+	ref = strings.TrimSpace(ref)
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("%w %q", ErrExternalRef, ref)
+	}
+	id := ref[1:]
+	if id == "" {
+		return nil, ErrZeroLengthID
+	}
+	e := doc.elementByID(id)
+	if e == nil {
+		return nil, fmt.Errorf("%w %q", ErrMissingID, id)
+	}
+	return e, nil
+}
+
+// elementByID returns the first element with the id, from the index if
+// the document has one. Documents built as literals, such as those
+// generated by svggen, are searched instead, since indexing them would
+// modify them while they may be drawn concurrently.
+func (doc *Document) elementByID(id string) *Element {
+	if doc.ids != nil {
+		return doc.ids[id]
+	}
+	var find func(e *Element) *Element
+	find = func(e *Element) *Element {
+		if e.Attr("id") == id {
+			return e
+		}
+		for _, c := range e.Children {
+			if f := find(c); f != nil {
+				return f
+			}
+		}
+		return nil
+	}
+	if doc.Root == nil {
+		return nil
+	}
+	return find(doc.Root)
+}
+
+// readViewport reads the width, height and viewBox attributes of the root
+// element. Missing dimensions are taken from each other.
+func (doc *Document) readViewport() error {
+	if vb := doc.Root.Attr("viewBox"); vb != "" {
+		f, err := parseFloats(vb)
+		if err != nil || len(f) != 4 {
+			return fmt.Errorf("svgg: invalid viewBox %q", vb)
+		}
+		doc.ViewBox = ViewBox{f[0], f[1], f[2], f[3]}
+	}
+	var err error
+	if doc.Width, err = parseLength(doc.Root.Attr("width"), doc.ViewBox.W); err != nil {
+		return err
+	}
+	if doc.Height, err = parseLength(doc.Root.Attr("height"), doc.ViewBox.H); err != nil {
+		return err
+	}
+	if doc.ViewBox.W == 0 && doc.ViewBox.H == 0 {
+		doc.ViewBox.W, doc.ViewBox.H = doc.Width, doc.Height
+	}
+	return nil
+}
+
+// parseLength parses a length attribute, returning def if it is empty.
+// Percentages are resolved against def.
+func parseLength(s string, def float64) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return def, nil
+	}
+	if strings.HasSuffix(s, "%") {
+		f, err := parseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+		}
+		return def * f / 100, nil
+	}
+	f, err := parseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+	}
+	return f, nil
+}
+
+// parseFloats parses a list of numbers separated by whitespace and/or commas.
+func parseFloats(s string) ([]float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	f := make([]float64, 0, len(fields))
+	for _, v := range fields {
+		n, err := parseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", ErrInvalidNumber, v)
+		}
+		f = append(f, n)
+	}
+	return f, nil
+}
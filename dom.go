@@ -0,0 +1,223 @@
+package svgg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Documents can be edited through their elements and written back out
+// with WriteTo. Elements, attributes and namespaces the renderer does not
+// know are kept as they were read.
+
+// SetAttr sets the attribute with the given local name, adding it at the
+// end if it is not set.
+func (e *Element) SetAttr(name, value string) {
+	if name == "d" {
+		e.Compiled = nil
+	}
+	for i, a := range e.Attrs {
+		if a.Name.Local == name {
+			e.Attrs[i].Value = value
+			return
+		}
+	}
+	e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+}
+
+// RemoveAttr removes the attribute with the given local name.
+func (e *Element) RemoveAttr(name string) {
+	if name == "d" {
+		e.Compiled = nil
+	}
+	attrs := e.Attrs[:0]
+	for _, a := range e.Attrs {
+		if a.Name.Local != name {
+			attrs = append(attrs, a)
+		}
+	}
+	e.Attrs = attrs
+}
+
+// Style returns the value of the style property name set on e, by its
+// style attribute or else by a presentation attribute, or "" if neither
+// sets it.
+func (e *Element) Style(name string) string {
+	for _, decl := range strings.Split(e.Attr("style"), ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == name {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return strings.TrimSpace(e.Attr(name))
+}
+
+// SetStyle sets the style property name in the style attribute of e,
+// which takes precedence over presentation attributes. An empty value
+// removes the declaration.
+func (e *Element) SetStyle(name, value string) {
+	var decls []string
+	found := false
+	for _, decl := range strings.Split(e.Attr("style"), ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if strings.TrimSpace(decl) == "" {
+			continue
+		}
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) == name {
+			if found || value == "" {
+				continue
+			}
+			decl, found = name+":"+value, true
+		}
+		decls = append(decls, strings.TrimSpace(decl))
+	}
+	if !found && value != "" {
+		decls = append(decls, name+":"+value)
+	}
+	if len(decls) == 0 {
+		e.RemoveAttr("style")
+		return
+	}
+	e.SetAttr("style", strings.Join(decls, ";"))
+}
+
+// AppendChild adds c as the last child of e.
+func (e *Element) AppendChild(c *Element) {
+	e.Children = append(e.Children, c)
+}
+
+// InsertChild adds c as the child of e at index i, shifting later children
+// along.
+func (e *Element) InsertChild(i int, c *Element) {
+	e.Children = append(e.Children, nil)
+	copy(e.Children[i+1:], e.Children[i:])
+	e.Children[i] = c
+}
+
+// RemoveChild removes c from the children of e, reporting whether it was
+// there.
+func (e *Element) RemoveChild(c *Element) bool {
+	for i, x := range e.Children {
+		if x == c {
+			e.Children = append(e.Children[:i], e.Children[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ElementByID returns the element with the given id, or nil.
+func (doc *Document) ElementByID(id string) *Element {
+	return doc.elementByID(id)
+}
+
+// Update brings the document up to date after its element tree has been
+// edited: ids are indexed again, and the size and viewBox are read again
+// from the root element.
+func (doc *Document) Update() error {
+	doc.indexIDs()
+	return doc.readViewport()
+}
+
+// WriteTo writes the document as SVG to w. Comments and processing
+// instructions are not kept.
+func (doc *Document) WriteTo(w io.Writer) (int64, error) {
+	return writeDocument(w, doc.Root)
+}
+
+// writeDocument writes the element tree under root as an XML document.
+func writeDocument(w io.Writer, root *Element) (int64, error) {
+	x := &xmlWriter{prefixes: map[string]string{
+		"http://www.w3.org/XML/1998/namespace": "xml",
+	}}
+	x.collectPrefixes(root)
+	x.defaultNS = root.Attr("xmlns")
+	x.element(root, 0)
+	x.b.WriteByte('\n')
+	return x.b.WriteTo(w)
+}
+
+// xmlWriter formats an element tree as indented XML.
+type xmlWriter struct {
+	b         bytes.Buffer
+	prefixes  map[string]string // namespace URLs to prefixes
+	defaultNS string
+}
+
+// collectPrefixes records the namespace prefixes declared under e. The
+// first declaration of a namespace wins.
+func (x *xmlWriter) collectPrefixes(e *Element) {
+	for _, a := range e.Attrs {
+		if a.Name.Space == "xmlns" {
+			if _, ok := x.prefixes[a.Value]; !ok {
+				x.prefixes[a.Value] = a.Name.Local
+			}
+		}
+	}
+	for _, c := range e.Children {
+		x.collectPrefixes(c)
+	}
+}
+
+// name writes a qualified name, mapping its namespace back to a prefix.
+func (x *xmlWriter) name(space, local string) {
+	if space != "" && space != x.defaultNS {
+		p, ok := x.prefixes[space]
+		if !ok && !strings.Contains(space, ":") {
+			// an undeclared prefix, which the XML decoder leaves as is
+			p, ok = space, true
+		}
+		// a namespace without a prefix is the default one declared on the
+		// element or an ancestor, so it needs none either
+		if ok {
+			x.b.WriteString(p)
+			x.b.WriteByte(':')
+		}
+	}
+	x.b.WriteString(local)
+}
+
+func (x *xmlWriter) element(e *Element, depth int) {
+	b := &x.b
+	b.WriteByte('<')
+	x.name(e.Space, e.Name)
+	for _, a := range e.Attrs {
+		b.WriteByte(' ')
+		x.name(a.Name.Space, a.Name.Local)
+		b.WriteString(`="`)
+		xml.EscapeText(b, []byte(a.Value))
+		b.WriteByte('"')
+	}
+	// elements with character data between their children, such as
+	// <text>, are written without indentation, which would add spaces
+	mixed := strings.TrimSpace(e.Text) != ""
+	for _, c := range e.Children {
+		mixed = mixed || strings.TrimSpace(c.Tail) != ""
+	}
+	if len(e.Children) == 0 && !mixed {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteByte('>')
+	if mixed {
+		xml.EscapeText(b, []byte(e.Text))
+		for _, c := range e.Children {
+			x.element(c, depth+1)
+			xml.EscapeText(b, []byte(c.Tail))
+		}
+	} else {
+		for _, c := range e.Children {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("  ", depth+1))
+			x.element(c, depth+1)
+		}
+		if len(e.Children) > 0 {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("  ", depth))
+		}
+	}
+	b.WriteString("</")
+	x.name(e.Space, e.Name)
+	b.WriteByte('>')
+}
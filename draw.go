@@ -0,0 +1,794 @@
+package svgg
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// elementAttrs lists, for every element the renderer draws, the geometry
+// attributes it reads. Presentation properties and coreAttrs are accepted
+// on all of them.
+var elementAttrs = map[string][]string{
+	"svg":      {"x", "y", "width", "height", "viewBox", "preserveAspectRatio", "version", "baseProfile"},
+	"g":        nil,
+	"a":        {"href", "target"},
+	"path":     {"d"},
+	"rect":     {"x", "y", "width", "height", "rx", "ry"},
+	"circle":   {"cx", "cy", "r"},
+	"ellipse":  {"cx", "cy", "rx", "ry"},
+	"line":     {"x1", "y1", "x2", "y2"},
+	"polyline": {"points"},
+	"polygon":  {"points"},
+	"image":    {"x", "y", "width", "height", "href", "preserveAspectRatio"},
+	"use":      {"x", "y", "width", "height", "href"},
+	"symbol":   {"x", "y", "width", "height", "viewBox", "preserveAspectRatio"},
+	"text":     {"x", "y", "dx", "dy", "rotate"},
+	"tspan":    {"x", "y", "dx", "dy", "rotate"},
+}
+
+// skippedElements are elements that are never rendered directly, so
+// skipping them does not lose fidelity.
+var skippedElements = map[string]bool{
+	"title":    true,
+	"desc":     true,
+	"metadata": true,
+
+	"linearGradient": true,
+	"radialGradient": true,
+	"pattern":        true,
+	"clipPath":       true,
+	"mask":           true,
+	"marker":         true,
+}
+
+// coreAttrs are attributes accepted on any element.
+var coreAttrs = map[string]bool{
+	"id":        true,
+	"class":     true,
+	"style":     true,
+	"transform": true,
+	"xmlns":     true,
+	"space":     true,
+	"lang":      true,
+}
+
+// parserPool holds Parsers for reuse across Draw calls.
+var parserPool = sync.Pool{
+	New: func() interface{} { return NewParser(nil) },
+}
+
+// renderer holds the state of a single Draw call.
+type renderer struct {
+	doc    *Document
+	dc     *gg.Context
+	parser *Parser
+	opts   RenderOptions
+	stats  *Stats
+	errs   ErrorList
+	uses   []*Element   // <use> elements being drawn, innermost last
+	inst   int          // elements drawn through <use> so far
+	pixel  float64      // output pixel size in device pixels
+	layer  *gg.Context  // scratch context for linearRGB compositing
+	anims  *animations  // animations by target, if drawing at a time
+	clip   *image.Alpha // coverage of the current clip paths and masks
+	defs   []*Element   // masks and patterns being drawn, innermost last
+	debug  *debugLayer  // diagnostics to draw over the document, if any
+}
+
+// Draw draws the document to dc. The viewBox is mapped onto a rectangle of
+// the document's width and height with its top-left corner at the origin of
+// dc's current coordinate system.
+func (doc *Document) Draw(dc *gg.Context) error {
+	return doc.DrawWithOptions(dc, nil)
+}
+
+// DrawWithStats draws the document like Draw and returns counts of the
+// elements, path commands and points it drew.
+func (doc *Document) DrawWithStats(dc *gg.Context) (*Stats, error) {
+	stats := &Stats{}
+	err := doc.DrawWithOptions(dc, &RenderOptions{Stats: stats})
+	return stats, err
+}
+
+// DrawWithOptions draws the document like Draw, configured by opts.
+// A nil opts is the same as the zero RenderOptions.
+func (doc *Document) DrawWithOptions(dc *gg.Context, opts *RenderOptions) error {
+	var o RenderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.WorldFile != nil {
+		vb := viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, doc.Root.Attr("preserveAspectRatio"))
+		*o.WorldFile = NewWorldFile(vb.Multiply(currentMatrix(dc)))
+	}
+	if o.Background != nil {
+		dc.Push()
+		dc.Identity()
+		dc.SetColor(o.Background)
+		dc.DrawRectangle(0, 0, float64(dc.Width()), float64(dc.Height()))
+		dc.Fill()
+		dc.Pop()
+	}
+	if len(o.Filters) > 0 {
+		return doc.drawFiltered(dc, o)
+	}
+	if o.Supersample > 1 {
+		return doc.drawSupersampled(dc, o)
+	}
+	return doc.draw(dc, o, 1)
+}
+
+// draw draws the document to dc. pixel is the size of an output pixel in
+// device pixels of dc, which is larger than 1 when supersampling.
+func (doc *Document) draw(dc *gg.Context, opts RenderOptions, pixel float64) error {
+	r := &renderer{doc: doc, dc: dc, parser: parserPool.Get().(*Parser), opts: opts, pixel: pixel}
+	r.parser.Reset(dc)
+	defer func() {
+		r.parser.Reset(nil)
+		parserPool.Put(r.parser)
+	}()
+	r.stats = r.opts.Stats
+	r.parser.ErrorMode = doc.ErrorMode
+	r.parser.ErrorHandler = doc.ErrorHandler
+	r.parser.Stats = r.stats
+	r.parser.Epsilon = r.opts.Epsilon
+	r.parser.Tolerance = r.opts.Tolerance
+	r.parser.Deterministic = r.opts.Deterministic
+	if r.opts.Deterministic {
+		r.opts.Timeout, r.opts.ElementTimeout = 0, 0
+	}
+	if r.opts.Timeout > 0 {
+		r.parser.Deadline = time.Now().Add(r.opts.Timeout)
+	}
+	if r.opts.Animate {
+		var err error
+		if r.anims, err = doc.animations(r.fail); err != nil {
+			return err
+		}
+	}
+	dc.Push()
+	defer dc.Pop()
+	applyMatrix(dc, viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, doc.Root.Attr("preserveAspectRatio")))
+	if opts.Debug != 0 {
+		r.debug = &debugLayer{dc: dc, flags: opts.Debug}
+	}
+	err := r.drawChildren(doc.Root, doc.rootStyle())
+	if r.debug != nil {
+		r.debug.draw(pixel)
+	}
+	if err != nil {
+		return err
+	}
+	if len(r.errs) > 0 {
+		return r.errs
+	}
+	return nil
+}
+
+// fail surfaces err according to the document's ErrorMode. It returns a
+// non-nil error when drawing should stop.
+func (r *renderer) fail(e *Element, err error) error {
+	if list, ok := err.(ErrorList); ok {
+		// collected by the parser, which has already counted them
+		for _, err := range list {
+			r.errs = append(r.errs, fmt.Errorf("svgg: <%s>: %w", e.Name, err))
+		}
+		return nil
+	}
+	err = fmt.Errorf("svgg: <%s>: %w", e.Name, err)
+	switch r.doc.ErrorMode {
+	case CollectErrorMode:
+		r.errs = append(r.errs, err)
+	case WarnErrorMode:
+		log.Println(err)
+	case IgnoreErrorMode:
+	default:
+		return err
+	}
+	r.stats.addWarning()
+	return nil
+}
+
+// unsupported reports that e is not rendered. Like unknown path commands,
+// this is only an error in StrictErrorMode.
+func (r *renderer) unsupported(e *Element) error {
+	switch r.doc.ErrorMode {
+	case IgnoreErrorMode:
+	case WarnErrorMode:
+		log.Println("Ignoring svg element " + e.Name)
+	default:
+		return r.fail(e, ErrNotImplemented)
+	}
+	r.stats.addWarning()
+	return nil
+}
+
+func (r *renderer) drawChildren(e *Element, s style) error {
+	for _, c := range e.Children {
+		if err := r.drawElement(c, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *renderer) drawElement(e *Element, parent style) error {
+	if skippedElements[e.Name] || animationElements[e.Name] || e.Name == "defs" {
+		return nil
+	}
+	if !r.parser.Deadline.IsZero() && time.Now().After(r.parser.Deadline) {
+		return ErrDeadline
+	}
+	if len(r.uses) > 0 {
+		r.inst++
+		if max := r.doc.Limits.MaxUseInstances; max > 0 && r.inst > max {
+			return r.fail(e, fmt.Errorf("%w: more than %d elements drawn through <use>", ErrLimitExceeded, max))
+		}
+	}
+	if _, ok := elementAttrs[e.Name]; !ok {
+		if r.opts.Placeholders {
+			r.drawPlaceholder(e, parent)
+		}
+		return r.unsupported(e)
+	}
+	use := r.instance(e)
+	if e.Name == "symbol" && use == nil {
+		// symbols are only drawn through <use>
+		return nil
+	}
+	if r.anims != nil {
+		e = r.anims.apply(e, parent, r.opts.Time.Seconds())
+	}
+	s, err := parent.resolve(e)
+	if err != nil {
+		return r.fail(e, err)
+	}
+	if !s.display {
+		return nil
+	}
+	r.stats.addElement(e.Name)
+	dc := r.dc
+	dc.Push()
+	defer dc.Pop()
+	if t := e.Attr("transform"); t != "" {
+		m, err := parseTransform(t)
+		if err != nil {
+			return r.fail(e, err)
+		}
+		applyMatrix(dc, m)
+	}
+	defer func(clip *image.Alpha) {
+		// gg.Context.Pop keeps the mask, so restore it here
+		r.clip = clip
+		setClip(dc, clip)
+	}(r.clip)
+	if err := r.clipAndMask(e); err != nil {
+		return err
+	}
+	switch e.Name {
+	case "svg", "symbol":
+		rect, m, err := r.doc.viewport(e, use)
+		if err != nil {
+			return r.fail(e, err)
+		}
+		if rect[2] <= 0 || rect[3] <= 0 {
+			return nil
+		}
+		if clipsOverflow(e) {
+			r.clipRect(rect[0], rect[1], rect[2], rect[3])
+		}
+		applyMatrix(dc, m)
+		return r.drawChildren(e, s)
+	case "g", "a":
+		return r.drawChildren(e, s)
+	case "tspan":
+		// tspans are drawn as part of their text
+		return nil
+	case "image":
+		if err := r.drawImage(e); err != nil {
+			return r.fail(e, err)
+		}
+		return nil
+	case "use":
+		return r.drawUse(e, s)
+	}
+	start := time.Now()
+	draw := r.drawShape
+	if r.linear(s) {
+		draw = r.drawShapeLinear
+	}
+	if err := draw(e, s); err != nil {
+		if errors.Is(err, ErrDeadline) {
+			return err
+		}
+		return r.fail(e, err)
+	}
+	if budget := r.opts.ElementTimeout; budget > 0 && time.Since(start) > budget {
+		return fmt.Errorf("svgg: <%s> took longer than %v: %w", e.Name, budget, ErrDeadline)
+	}
+	return nil
+}
+
+// drawShape builds the outline of the basic shape or path e and paints it
+// with style s.
+func (r *renderer) drawShape(e *Element, s style) error {
+	if e.Name == "text" {
+		return r.drawText(e, s)
+	}
+	fill, stroke, err := r.paints(e, s, func() (*boundsSink, error) {
+		box := &boundsSink{}
+		return box, buildShape(NewSinkParser(box), e)
+	})
+	if err != nil {
+		return err
+	}
+	passes := [][2]gg.Pattern{{fill, stroke}}
+	if r.opts.ClipGeometry && fill != nil && stroke != nil && s.strokeWidth > 0 {
+		// the fill is clipped as polygons, whose cut edges must not be
+		// stroked, so the stroke is built again and clipped as lines
+		passes = [][2]gg.Pattern{{fill, nil}, {nil, stroke}}
+	}
+	defer func() { r.parser.ClipRect = image.Rectangle{} }()
+	for _, pass := range passes {
+		fill, stroke := pass[0], pass[1]
+		if e.Name == "path" && r.opts.ChunkSize > 0 {
+			r.parser.ChunkSize = r.opts.ChunkSize
+			r.parser.SplitSubpaths = fill == nil
+			r.parser.Flush = func() { r.paint(s, fill, stroke) }
+			defer func() { r.parser.Flush = nil }()
+		}
+		r.parser.PixelSnap = 0
+		if r.opts.Quantize > 0 {
+			r.parser.PixelSnap = r.opts.Quantize * r.pixel
+		}
+		if r.crisp(s) {
+			r.parser.PixelSnap = r.pixel
+		}
+		r.clipGeometry(s, fill, stroke)
+		if err := buildShape(r.parser, e); err != nil {
+			r.dc.ClearPath()
+			return err
+		}
+		r.paint(s, fill, stroke)
+	}
+	r.debugShape(e)
+	return r.drawMarkers(e, s)
+}
+
+// clipGeometry makes the parser clip a shape painted with fill and stroke
+// to the context as it is built, if the options ask for it. The rectangle
+// is larger than the context by the stroke width, so that cut edges and
+// line ends fall outside it. Dashed strokes are not clipped, as their
+// dashes would restart where the stroke comes back into view.
+func (r *renderer) clipGeometry(s style, fill, stroke gg.Pattern) {
+	r.parser.ClipRect = image.Rectangle{}
+	if !r.opts.ClipGeometry {
+		return
+	}
+	margin := 1.0
+	if stroke != nil && s.strokeWidth > 0 {
+		if len(s.dashes) > 0 {
+			return
+		}
+		margin += s.strokeWidth * matrixScale(currentMatrix(r.dc))
+	}
+	m := int(math.Ceil(math.Min(margin, math.MaxInt32/4)))
+	r.parser.ClipRect = image.Rect(-m, -m, r.dc.Width()+m, r.dc.Height()+m)
+	r.parser.ClipLines = fill == nil
+}
+
+// crisp reports whether shapes drawn with style s should be snapped to
+// pixel boundaries.
+func (r *renderer) crisp(s style) bool {
+	v := s.rendering
+	if r.opts.ShapeRendering != "" {
+		v = r.opts.ShapeRendering
+	}
+	return v == "crispEdges" || v == "optimizeSpeed"
+}
+
+// buildShape sends the outline of the basic shape or path e to the sink
+// of p.
+func buildShape(p *Parser, e *Element) error {
+	defer p.endClip()
+	switch e.Name {
+	case "path":
+		if e.Compiled != nil {
+			return p.drawCompiled(e.Compiled)
+		}
+		return p.CompilePath(e.Attr("d"))
+	case "polyline", "polygon":
+		pts, err := parseFloats(e.Attr("points"))
+		if err != nil {
+			return err
+		}
+		if len(pts)%2 != 0 {
+			return fmt.Errorf("%w: odd number of coordinates in points", ErrParamMismatch)
+		}
+		p.init()
+		for i := 0; i+1 < len(pts); i += 2 {
+			if i == 0 {
+				p.moveTo(pts[i], pts[i+1])
+			} else {
+				p.drawLine(pts[i], pts[i+1])
+			}
+		}
+		if e.Name == "polygon" && len(pts) > 0 {
+			p.sink.ClosePath()
+		}
+		return nil
+	}
+	f, err := floatAttrs(e, elementAttrs[e.Name]...)
+	if err != nil {
+		return err
+	}
+	p.init()
+	switch e.Name {
+	case "rect":
+		x, y, w, h, rx, ry := f[0], f[1], f[2], f[3], f[4], f[5]
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		_, hasRx := e.LookupAttr("rx")
+		_, hasRy := e.LookupAttr("ry")
+		if !hasRx {
+			rx = ry
+		}
+		if !hasRy {
+			ry = rx
+		}
+		rx = math.Min(rx, w/2)
+		ry = math.Min(ry, h/2)
+		if rx <= 0 || ry <= 0 {
+			p.moveTo(x, y)
+			p.drawLine(x+w, y)
+			p.drawLine(x+w, y+h)
+			p.drawLine(x, y+h)
+			p.sink.ClosePath()
+			return nil
+		}
+		p.moveTo(x+rx, y)
+		p.drawLine(x+w-rx, y)
+		p.arcTo(x+w-rx, y, rx, ry, x+w, y+ry)
+		p.drawLine(x+w, y+h-ry)
+		p.arcTo(x+w, y+h-ry, rx, ry, x+w-rx, y+h)
+		p.drawLine(x+rx, y+h)
+		p.arcTo(x+rx, y+h, rx, ry, x, y+h-ry)
+		p.drawLine(x, y+ry)
+		p.arcTo(x, y+ry, rx, ry, x+rx, y)
+		p.sink.ClosePath()
+	case "circle":
+		if f[2] > 0 {
+			ellipse(p, f[0], f[1], f[2], f[2])
+		}
+	case "ellipse":
+		if f[2] > 0 && f[3] > 0 {
+			ellipse(p, f[0], f[1], f[2], f[3])
+		}
+	case "line":
+		p.moveTo(f[0], f[1])
+		p.drawLine(f[2], f[3])
+	}
+	return nil
+}
+
+// ellipse sends a closed ellipse centered at cx, cy to the sink of p.
+func ellipse(p *Parser, cx, cy, rx, ry float64) {
+	p.moveTo(cx+rx, cy)
+	p.arcTo(cx+rx, cy, rx, ry, cx-rx, cy)
+	p.arcTo(cx-rx, cy, rx, ry, cx+rx, cy)
+	p.sink.ClosePath()
+}
+
+// drawUse draws the element referenced by the <use> element e, offset by
+// its x and y attributes.
+func (r *renderer) drawUse(e *Element, s style) error {
+	ref, err := r.doc.lookupRef(e.Attr("href"))
+	if err != nil {
+		return r.fail(e, err)
+	}
+	for _, u := range r.uses {
+		if u == e {
+			return r.fail(e, fmt.Errorf("circular reference to %q", e.Attr("href")))
+		}
+	}
+	if max := r.doc.Limits.MaxUseDepth; max > 0 && len(r.uses) >= max {
+		return r.fail(e, fmt.Errorf("%w: <use> nested more than %d deep", ErrLimitExceeded, max))
+	}
+	f, err := floatAttrs(e, "x", "y")
+	if err != nil {
+		return r.fail(e, err)
+	}
+	r.uses = append(r.uses, e)
+	defer func() { r.uses = r.uses[:len(r.uses)-1] }()
+	r.dc.Translate(f[0], f[1])
+	return r.drawElement(ref, s)
+}
+
+// drawImage draws the raster image referenced by e, fitting it into the
+// element's rectangle according to its preserveAspectRatio.
+func (r *renderer) drawImage(e *Element) error {
+	if strings.TrimSpace(e.Attr("href")) == "" {
+		return nil
+	}
+	f, err := floatAttrs(e, "x", "y", "width", "height")
+	if err != nil {
+		return err
+	}
+	im, err := loadImage(e.Attr("href"), r.doc.Resolver, r.doc.Limits.MaxImagePixels)
+	if err != nil {
+		return err
+	}
+	b := im.Bounds()
+	w, h := f[2], f[3]
+	if _, ok := e.LookupAttr("width"); !ok {
+		w = float64(b.Dx())
+	}
+	if _, ok := e.LookupAttr("height"); !ok {
+		h = float64(b.Dy())
+	}
+	vb := ViewBox{float64(b.Min.X), float64(b.Min.Y), float64(b.Dx()), float64(b.Dy())}
+	if r.debugging(DebugBounds) {
+		r.debug.box(f[0], f[1], f[0]+w, f[1]+h, currentMatrix(r.dc))
+	}
+	if r.opts.Wireframe {
+		r.dc.DrawRectangle(f[0], f[1], w, h)
+		r.outline()
+		return nil
+	}
+	dc := r.dc
+	dc.Push()
+	defer dc.Pop()
+	dc.Translate(f[0], f[1])
+	applyMatrix(dc, viewBoxTransform(vb, w, h, e.Attr("preserveAspectRatio")))
+	dc.DrawImage(im, 0, 0)
+	return nil
+}
+
+// paints returns the patterns that fill and stroke the element e with
+// style s, or nil for none. Paint servers are resolved against the
+// bounding box returned by bounds; one that cannot be used is reported and
+// replaced by its fallback color.
+func (r *renderer) paints(e *Element, s style, bounds func() (*boundsSink, error)) (fill, stroke gg.Pattern, err error) {
+	if r.opts.Wireframe {
+		return nil, nil, nil
+	}
+	var box *boundsSink
+	paint := func(server string, c color.Color, opacity float64) (gg.Pattern, error) {
+		if server != "" {
+			if box == nil {
+				if box, err = bounds(); err != nil {
+					return nil, err
+				}
+			}
+			p, err := r.paintServer(e, server, box, opacity)
+			if err == nil {
+				return p, nil
+			}
+			if err := r.fail(e, err); err != nil {
+				return nil, err
+			}
+		}
+		if c = r.mapColor(c, e); c == nil {
+			return nil, nil
+		}
+		return gg.NewSolidPattern(withAlpha(c, opacity)), nil
+	}
+	if fill, err = paint(s.fillServer, s.fill, s.fillOpacity*s.opacity); err != nil {
+		return nil, nil, err
+	}
+	if stroke, err = paint(s.strokeServer, s.stroke, s.strokeOpacity*s.opacity); err != nil {
+		return nil, nil, err
+	}
+	return fill, stroke, nil
+}
+
+// paintServer returns a pattern painting the gradient or pattern element
+// referenced by ref for the shape e with the bounding box b, with its
+// alpha multiplied by opacity. It returns nil if the server paints
+// nothing.
+func (r *renderer) paintServer(e *Element, ref string, b *boundsSink, opacity float64) (gg.Pattern, error) {
+	server, err := r.doc.lookupRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if server.Name == "pattern" {
+		return r.tilePattern(server, b, opacity)
+	}
+	g, err := r.doc.gradient(ref)
+	if err != nil {
+		return nil, err
+	}
+	if r.opts.MapColor != nil || r.opts.DarkMode {
+		for i, stop := range g.stops {
+			var n color.NRGBA // a stop mapped to nil is transparent
+			if c := r.mapColor(stop.color, e); c != nil {
+				n = color.NRGBAModel.Convert(c).(color.NRGBA)
+			}
+			g.stops[i].color = n
+		}
+	}
+	r.stats.addGradient()
+	if p, ok := g.pattern(currentMatrix(r.dc), b.x0, b.y0, b.x1, b.y1, opacity, r.opts.LinearRGB); ok {
+		return p, nil
+	}
+	return nil, nil
+}
+
+// mapColor returns the color c painted on e as mapped by the MapColor
+// and DarkMode options.
+func (r *renderer) mapColor(c color.Color, e *Element) color.Color {
+	if c != nil && r.opts.MapColor != nil {
+		c = r.opts.MapColor(c, e)
+	}
+	if c != nil && r.opts.DarkMode {
+		c = invertLightness(c)
+	}
+	return c
+}
+
+// paint fills and strokes the current path with style s and clears it.
+func (r *renderer) paint(s style, fill, stroke gg.Pattern) {
+	dc := r.dc
+	if r.opts.Wireframe {
+		r.outline()
+		return
+	}
+	if fill != nil {
+		dc.SetFillRule(s.fillRule)
+		dc.SetFillStyle(fill)
+		dc.FillPreserve()
+	}
+	if stroke != nil && s.strokeWidth > 0 {
+		// gg strokes in device space, so scale the width by the current matrix
+		scale := matrixScale(currentMatrix(dc))
+		dashes := make([]float64, len(s.dashes))
+		for i, d := range s.dashes {
+			dashes[i] = d * scale
+		}
+		dc.SetStrokeStyle(stroke)
+		dc.SetLineWidth(s.strokeWidth * scale)
+		dc.SetLineCap(s.lineCap)
+		dc.SetLineJoin(s.lineJoin)
+		dc.SetDash(dashes...)
+		dc.StrokePreserve()
+	}
+	dc.ClearPath()
+}
+
+// outline strokes the current path with the thin line of wireframe mode
+// and clears it.
+func (r *renderer) outline() {
+	dc := r.dc
+	c := r.opts.WireframeColor
+	if c == nil {
+		c = color.Black
+	}
+	dc.SetColor(c)
+	dc.SetLineWidth(r.pixel)
+	dc.SetLineCap(gg.LineCapButt)
+	dc.SetLineJoin(gg.LineJoinRound)
+	dc.SetDash()
+	dc.Stroke()
+}
+
+// floatAttrs parses the named attributes of e as lengths, defaulting to 0.
+func floatAttrs(e *Element, names ...string) ([]float64, error) {
+	f := make([]float64, len(names))
+	for i, n := range names {
+		v, err := parseLength(e.Attr(n), 0)
+		if err != nil {
+			return f, fmt.Errorf("%s: %w", n, err)
+		}
+		f[i] = v
+	}
+	return f, nil
+}
+
+// currentMatrix recovers the current transformation matrix of dc.
+func currentMatrix(dc *gg.Context) gg.Matrix {
+	x0, y0 := dc.TransformPoint(0, 0)
+	x1, y1 := dc.TransformPoint(1, 0)
+	x2, y2 := dc.TransformPoint(0, 1)
+	return gg.Matrix{XX: x1 - x0, YX: y1 - y0, XY: x2 - x0, YY: y2 - y0, X0: x0, Y0: y0}
+}
+
+// matrixScale returns the mean scale factor of m, used for stroke widths.
+func matrixScale(m gg.Matrix) float64 {
+	return math.Sqrt(math.Abs(m.XX*m.YY - m.YX*m.XY))
+}
+
+// viewBoxTransform returns the matrix mapping vb onto a w by h viewport
+// according to the preserveAspectRatio value par.
+func viewBoxTransform(vb ViewBox, w, h float64, par string) gg.Matrix {
+	if vb.W <= 0 || vb.H <= 0 {
+		return gg.Identity()
+	}
+	sx, sy := w/vb.W, h/vb.H
+	fields := strings.Fields(par)
+	align := "xMidYMid"
+	if len(fields) > 0 {
+		align = fields[0]
+	}
+	if align == "none" {
+		return gg.Translate(-vb.X, -vb.Y).Multiply(gg.Scale(sx, sy))
+	}
+	if len(fields) > 1 && fields[1] == "slice" {
+		sx = math.Max(sx, sy)
+	} else {
+		sx = math.Min(sx, sy)
+	}
+	sy = sx
+	tx, ty := 0.0, 0.0
+	switch {
+	case strings.Contains(align, "xMid"):
+		tx = (w - vb.W*sx) / 2
+	case strings.Contains(align, "xMax"):
+		tx = w - vb.W*sx
+	}
+	switch {
+	case strings.Contains(align, "YMid"):
+		ty = (h - vb.H*sy) / 2
+	case strings.Contains(align, "YMax"):
+		ty = h - vb.H*sy
+	}
+	return gg.Translate(-vb.X, -vb.Y).Multiply(gg.Scale(sx, sy)).Multiply(gg.Translate(tx, ty))
+}
+
+// instance returns the <use> element that e is drawn for, if e is the
+// element it references rather than a descendant of it.
+func (r *renderer) instance(e *Element) *Element {
+	if n := len(r.uses); n > 0 {
+		if ref, err := r.doc.lookupRef(r.uses[n-1].Attr("href")); err == nil && ref == e {
+			return r.uses[n-1]
+		}
+	}
+	return nil
+}
+
+// viewport returns the rectangle x, y, w, h in its parent's user space of
+// the viewport of the nested svg or symbol element e, and the matrix from
+// the user space of e to its parent's. The width and height of use, the
+// <use> element e is drawn for if any, take precedence over those of e.
+func (doc *Document) viewport(e, use *Element) (rect [4]float64, m gg.Matrix, err error) {
+	for i, a := range [4]struct {
+		name string
+		size float64
+	}{{"x", doc.ViewBox.W}, {"y", doc.ViewBox.H}, {"width", doc.ViewBox.W}, {"height", doc.ViewBox.H}} {
+		v := e.Attr(a.name)
+		if use != nil && i >= 2 {
+			if uv, ok := use.LookupAttr(a.name); ok {
+				v = uv
+			}
+		}
+		switch {
+		case strings.TrimSpace(v) != "":
+		case i < 2:
+			v = "0"
+		default:
+			v = "100%"
+		}
+		if rect[i], err = parseLength(v, a.size); err != nil {
+			return rect, m, fmt.Errorf("%s: %w", a.name, err)
+		}
+	}
+	m = gg.Translate(rect[0], rect[1])
+	if v := e.Attr("viewBox"); v != "" {
+		f, err := parseFloats(v)
+		if err != nil || len(f) != 4 {
+			return rect, m, fmt.Errorf("%w: viewBox %q", ErrSyntax, v)
+		}
+		vb := ViewBox{f[0], f[1], f[2], f[3]}
+		m = viewBoxTransform(vb, rect[2], rect[3], e.Attr("preserveAspectRatio")).Multiply(m)
+	}
+	return rect, m, nil
+}
@@ -0,0 +1,59 @@
+package svgg
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/fogleman/gg"
+)
+
+// Drawer composites a Document onto any draw.Image, for code built on the
+// standard image/draw pipeline that would otherwise draw to a gg.Context
+// and copy the result itself. Only the part of the document that falls
+// within the destination's bounds is drawn.
+type Drawer struct {
+	Doc *Document
+
+	// Options configures how the document is drawn. It may be nil.
+	Options *RenderOptions
+
+	// Op composites the drawn document onto the destination. The zero
+	// value is draw.Over.
+	Op draw.Op
+
+	// Fit fits the document's viewBox into the rectangle as its root's
+	// preserveAspectRatio says, rather than stretching the document's
+	// width and height onto it.
+	Fit bool
+}
+
+// Draw draws the document scaled to fill the rectangle r of dst. Pixels
+// of dst outside r are left as they are.
+func (d *Drawer) Draw(dst draw.Image, r image.Rectangle) error {
+	vis := r.Intersect(dst.Bounds())
+	if vis.Empty() {
+		return nil
+	}
+	doc := d.Doc
+	if d.Fit {
+		// the viewBox is fitted to a copy sized as r, as the Handler does
+		fitted := *doc
+		fitted.Width, fitted.Height = float64(r.Dx()), float64(r.Dy())
+		doc = &fitted
+	}
+	dc := gg.NewContext(vis.Dx(), vis.Dy())
+	var err error
+	if doc.Width > 0 && doc.Height > 0 {
+		dc.Translate(float64(r.Min.X-vis.Min.X), float64(r.Min.Y-vis.Min.Y))
+		dc.Scale(float64(r.Dx())/doc.Width, float64(r.Dy())/doc.Height)
+		err = doc.DrawWithOptions(dc, d.Options)
+	}
+	draw.Draw(dst, vis, dc.Image(), image.Point{}, d.Op)
+	return err
+}
+
+// DrawDocument draws doc scaled to fill the rectangle r of dst over its
+// pixels, like a Drawer with opts and no other fields set.
+func DrawDocument(dst draw.Image, r image.Rectangle, doc *Document, opts *RenderOptions) error {
+	return (&Drawer{Doc: doc, Options: opts}).Draw(dst, r)
+}
@@ -0,0 +1,341 @@
+package svgg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/fogleman/gg"
+)
+
+// The typed elements below are views of the elements of a Document: they
+// unmarshal with encoding/xml, from SVG source or from an Element with
+// Decode, and convert back to an Element with NewElement without losing
+// what they hold, so documents can be built and inspected with type
+// safety. The Document itself holds the generic Element tree rather than
+// typed elements, since it must also keep the elements and attributes the
+// typed views leave out; converting an element to its typed view and back
+// keeps every attribute as written.
+
+// Attributes are the attributes common to the typed elements.
+type Attributes struct {
+	ID    string `xml:"id,attr,omitempty"`
+	Class string `xml:"class,attr,omitempty"`
+	Style string `xml:"style,attr,omitempty"`
+
+	// Other holds the attributes without a field of their own, such as
+	// most presentation attributes.
+	Other []xml.Attr `xml:",any,attr"`
+}
+
+// Presentation holds the transform and paints of a typed graphics element
+// or group. The paints are as written, such as "red" or "url(#g)".
+type Presentation struct {
+	Transform string `xml:"transform,attr,omitempty"`
+	Fill      string `xml:"fill,attr,omitempty"`
+	Stroke    string `xml:"stroke,attr,omitempty"`
+}
+
+// Length is a coordinate or length attribute as written, such as "10",
+// "50%" or "10mm", so that elements keep their units and percentages when
+// converted back. The empty Length is an attribute that is not set, which
+// differs from "0" for the rx and ry of a rect, which default to each
+// other.
+type Length string
+
+// LengthOf returns the Length of v user units.
+func LengthOf(v float64) Length {
+	return Length((&Encoder{Precision: -1}).format(v))
+}
+
+// Value returns the length in user units as the renderer reads it: units
+// are ignored, percentages are of ref, and the empty Length is zero.
+func (l Length) Value(ref float64) (float64, error) {
+	if l == "" {
+		return 0, nil
+	}
+	return parseLength(string(l), ref)
+}
+
+// Points is the points attribute of a polyline or polygon.
+type Points []gg.Point
+
+// UnmarshalXMLAttr implements xml.UnmarshalerAttr.
+func (p *Points) UnmarshalXMLAttr(attr xml.Attr) error {
+	f, err := parseFloats(attr.Value)
+	if err != nil {
+		return fmt.Errorf("points: %w", err)
+	}
+	if len(f)%2 != 0 {
+		return fmt.Errorf("%w: odd number of coordinates in points", ErrParamMismatch)
+	}
+	pts := make(Points, len(f)/2)
+	for i := range pts {
+		pts[i] = gg.Point{X: f[2*i], Y: f[2*i+1]}
+	}
+	*p = pts
+	return nil
+}
+
+// MarshalXMLAttr implements xml.MarshalerAttr.
+func (p Points) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if len(p) == 0 {
+		return xml.Attr{}, nil
+	}
+	return xml.Attr{Name: name, Value: (&Encoder{Precision: -1}).points(p)}, nil
+}
+
+// Group is a <g> element.
+type Group struct {
+	XMLName xml.Name `xml:"g"`
+	Attributes
+	Presentation
+	Children []*Element `xml:",any"`
+}
+
+// Path is a <path> element.
+type Path struct {
+	XMLName xml.Name `xml:"path"`
+	Attributes
+	Presentation
+	D string `xml:"d,attr,omitempty"`
+}
+
+// Rect is a <rect> element.
+type Rect struct {
+	XMLName xml.Name `xml:"rect"`
+	Attributes
+	Presentation
+	X      Length `xml:"x,attr,omitempty"`
+	Y      Length `xml:"y,attr,omitempty"`
+	Width  Length `xml:"width,attr,omitempty"`
+	Height Length `xml:"height,attr,omitempty"`
+	RX     Length `xml:"rx,attr,omitempty"`
+	RY     Length `xml:"ry,attr,omitempty"`
+}
+
+// Circle is a <circle> element.
+type Circle struct {
+	XMLName xml.Name `xml:"circle"`
+	Attributes
+	Presentation
+	CX Length `xml:"cx,attr,omitempty"`
+	CY Length `xml:"cy,attr,omitempty"`
+	R  Length `xml:"r,attr,omitempty"`
+}
+
+// Ellipse is an <ellipse> element.
+type Ellipse struct {
+	XMLName xml.Name `xml:"ellipse"`
+	Attributes
+	Presentation
+	CX Length `xml:"cx,attr,omitempty"`
+	CY Length `xml:"cy,attr,omitempty"`
+	RX Length `xml:"rx,attr,omitempty"`
+	RY Length `xml:"ry,attr,omitempty"`
+}
+
+// Line is a <line> element.
+type Line struct {
+	XMLName xml.Name `xml:"line"`
+	Attributes
+	Presentation
+	X1 Length `xml:"x1,attr,omitempty"`
+	Y1 Length `xml:"y1,attr,omitempty"`
+	X2 Length `xml:"x2,attr,omitempty"`
+	Y2 Length `xml:"y2,attr,omitempty"`
+}
+
+// Polyline is a <polyline> element.
+type Polyline struct {
+	XMLName xml.Name `xml:"polyline"`
+	Attributes
+	Presentation
+	Points Points `xml:"points,attr"`
+}
+
+// Polygon is a <polygon> element.
+type Polygon struct {
+	XMLName xml.Name `xml:"polygon"`
+	Attributes
+	Presentation
+	Points Points `xml:"points,attr"`
+}
+
+// Use is a <use> element. Href is read from href or xlink:href, and
+// written as href.
+type Use struct {
+	XMLName xml.Name `xml:"use"`
+	Attributes
+	Presentation
+	Href   string `xml:"href,attr,omitempty"`
+	X      Length `xml:"x,attr,omitempty"`
+	Y      Length `xml:"y,attr,omitempty"`
+	Width  Length `xml:"width,attr,omitempty"`
+	Height Length `xml:"height,attr,omitempty"`
+}
+
+// LinearGradient is a <linearGradient> element. Its coordinates are often
+// percentages of the bounding box, and empty ones take defaults other
+// than zero.
+type LinearGradient struct {
+	XMLName xml.Name `xml:"linearGradient"`
+	Attributes
+	X1                Length `xml:"x1,attr,omitempty"`
+	Y1                Length `xml:"y1,attr,omitempty"`
+	X2                Length `xml:"x2,attr,omitempty"`
+	Y2                Length `xml:"y2,attr,omitempty"`
+	GradientUnits     string `xml:"gradientUnits,attr,omitempty"`
+	GradientTransform string `xml:"gradientTransform,attr,omitempty"`
+	SpreadMethod      string `xml:"spreadMethod,attr,omitempty"`
+	Href              string `xml:"href,attr,omitempty"`
+	Stops             []Stop `xml:"stop"`
+}
+
+// RadialGradient is a <radialGradient> element, whose coordinates are read
+// like those of a LinearGradient.
+type RadialGradient struct {
+	XMLName xml.Name `xml:"radialGradient"`
+	Attributes
+	CX                Length `xml:"cx,attr,omitempty"`
+	CY                Length `xml:"cy,attr,omitempty"`
+	R                 Length `xml:"r,attr,omitempty"`
+	FX                Length `xml:"fx,attr,omitempty"`
+	FY                Length `xml:"fy,attr,omitempty"`
+	FR                Length `xml:"fr,attr,omitempty"`
+	GradientUnits     string `xml:"gradientUnits,attr,omitempty"`
+	GradientTransform string `xml:"gradientTransform,attr,omitempty"`
+	SpreadMethod      string `xml:"spreadMethod,attr,omitempty"`
+	Href              string `xml:"href,attr,omitempty"`
+	Stops             []Stop `xml:"stop"`
+}
+
+// Stop is a <stop> of a gradient. Offset is a number or a percentage.
+type Stop struct {
+	XMLName xml.Name `xml:"stop"`
+	Attributes
+	Offset      string `xml:"offset,attr,omitempty"`
+	StopColor   string `xml:"stop-color,attr,omitempty"`
+	StopOpacity string `xml:"stop-opacity,attr,omitempty"`
+}
+
+// typedElements makes the typed element for each tag that has one.
+var typedElements = map[string]func() interface{}{
+	"g":              func() interface{} { return new(Group) },
+	"path":           func() interface{} { return new(Path) },
+	"rect":           func() interface{} { return new(Rect) },
+	"circle":         func() interface{} { return new(Circle) },
+	"ellipse":        func() interface{} { return new(Ellipse) },
+	"line":           func() interface{} { return new(Line) },
+	"polyline":       func() interface{} { return new(Polyline) },
+	"polygon":        func() interface{} { return new(Polygon) },
+	"use":            func() interface{} { return new(Use) },
+	"linearGradient": func() interface{} { return new(LinearGradient) },
+	"radialGradient": func() interface{} { return new(RadialGradient) },
+	"stop":           func() interface{} { return new(Stop) },
+}
+
+// Typed returns e as a pointer to its typed element, such as a *Rect for a
+// <rect>, for inspecting it with a type switch. Elements without a typed
+// element are returned as they are.
+func (e *Element) Typed() (interface{}, error) {
+	newTyped, ok := typedElements[e.Name]
+	if !ok {
+		return e, nil
+	}
+	v := newTyped()
+	if err := e.Decode(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Decode unmarshals e and its children into v with encoding/xml, as if
+// from the element's source.
+func (e *Element) Decode(v interface{}) error {
+	b, err := xml.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(b, v)
+}
+
+// NewElement returns the element tree that v, such as a *Rect, marshals
+// to with encoding/xml, for adding to a document.
+func NewElement(v interface{}) (*Element, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	e := &Element{}
+	if err := xml.Unmarshal(b, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// UnmarshalXML implements xml.Unmarshaler, so that Elements can hold the
+// children of typed elements, or any other part of an XML document read
+// with encoding/xml. Comments and processing instructions are dropped.
+func (e *Element) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*e = Element{Name: start.Name.Local, Space: start.Name.Space}
+	e.Attrs = append([]xml.Attr(nil), start.Attr...)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			c := &Element{}
+			if err := c.UnmarshalXML(d, t); err != nil {
+				return err
+			}
+			e.Children = append(e.Children, c)
+		case xml.EndElement:
+			return nil
+		case xml.CharData:
+			if n := len(e.Children); n > 0 {
+				e.Children[n-1].Tail += string(t)
+			} else {
+				e.Text += string(t)
+			}
+		}
+	}
+}
+
+// MarshalXML implements xml.Marshaler. The element is written by its local
+// name, and the namespaces of prefixed attributes are declared by the
+// encoder; use Document.WriteTo to write whole documents with their own
+// prefixes.
+func (e *Element) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Local: e.Name}}
+	for _, a := range e.Attrs {
+		if a.Name.Space == "xmlns" {
+			continue
+		}
+		start.Attr = append(start.Attr, a)
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if e.Text != "" {
+		if err := enc.EncodeToken(xml.CharData(e.Text)); err != nil {
+			return err
+		}
+	}
+	for _, c := range e.Children {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+		if c.Tail != "" {
+			if err := enc.EncodeToken(xml.CharData(c.Tail)); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,52 @@
+package svgg
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestTypedRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		`<rect width="50%" height="10mm" rx="0" ry="5"></rect>`,
+		`<circle cx="8" cy="8" r="4" fill="red" stroke-width="2"></circle>`,
+		`<linearGradient id="g" x2="100%"><stop offset="0.5" stop-color="blue"></stop></linearGradient>`,
+	} {
+		var e Element
+		if err := xml.Unmarshal([]byte(src), &e); err != nil {
+			t.Fatal(err)
+		}
+		v, err := e.Typed()
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		back, err := NewElement(v)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		for _, a := range e.Attrs {
+			if got := back.Attr(a.Name.Local); got != a.Value {
+				t.Errorf("%s: %s = %q, want %q", src, a.Name.Local, got, a.Value)
+			}
+		}
+		if len(back.Attrs) != len(e.Attrs) {
+			t.Errorf("%s: %d attributes after the round trip, want %d", src, len(back.Attrs), len(e.Attrs))
+		}
+	}
+}
+
+func TestLengthValue(t *testing.T) {
+	for _, tt := range []struct {
+		l    Length
+		want float64
+	}{
+		{"", 0},
+		{"12", 12},
+		{"50%", 50},
+		{LengthOf(2.5), 2.5},
+	} {
+		got, err := tt.l.Value(100)
+		if err != nil || got != tt.want {
+			t.Errorf("Length(%q).Value(100) = %v, %v; want %v", tt.l, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package svgg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// Style is how an Encoder paints a shape.
+type Style struct {
+	// Fill and Stroke are the paints of the shape; nil means none. Their
+	// alpha becomes fill-opacity and stroke-opacity.
+	Fill   color.Color
+	Stroke color.Color
+
+	FillRule gg.FillRule
+
+	// StrokeStyle describes the stroke. Nothing is stroked unless its
+	// Width is positive. A positive MiterLimit writes mitered joins.
+	StrokeStyle
+}
+
+// An Encoder builds an SVG document from shapes, paths and groups, the
+// inverse of Document, so that a scene drawn with gg can also be written
+// out resolution independent. Shapes are added to the innermost open group
+// and the document is written when the Encoder is closed.
+type Encoder struct {
+	// Precision is the number of digits kept after the decimal point of
+	// coordinates and lengths. Negative values, the default, keep every
+	// digit.
+	Precision int
+
+	w    io.Writer
+	root *Element
+	open []*Element // the root and the open groups, innermost last
+}
+
+// NewEncoder returns an Encoder writing a width by height document to w.
+func NewEncoder(w io.Writer, width, height float64) *Encoder {
+	enc := &Encoder{Precision: -1, w: w}
+	enc.root = &Element{Name: "svg"}
+	enc.root.Attrs = []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: "http://www.w3.org/2000/svg"},
+		{Name: xml.Name{Local: "width"}, Value: enc.format(width)},
+		{Name: xml.Name{Local: "height"}, Value: enc.format(height)},
+	}
+	enc.open = []*Element{enc.root}
+	return enc
+}
+
+// SetViewBox sets the user coordinate rectangle mapped onto the document.
+func (enc *Encoder) SetViewBox(vb ViewBox) {
+	enc.root.Attrs = append(enc.root.Attrs, xml.Attr{
+		Name:  xml.Name{Local: "viewBox"},
+		Value: enc.formatList(vb.X, vb.Y, vb.W, vb.H),
+	})
+}
+
+// BeginGroup opens a group whose contents are transformed by m, and
+// returns its element so that attributes such as an id can be added.
+func (enc *Encoder) BeginGroup(m gg.Matrix) *Element {
+	g := enc.add("g", nil)
+	if t := enc.transform(m); t != "" {
+		g.Attrs = append(g.Attrs, xml.Attr{Name: xml.Name{Local: "transform"}, Value: t})
+	}
+	enc.open = append(enc.open, g)
+	return g
+}
+
+// EndGroup closes the innermost open group.
+func (enc *Encoder) EndGroup() {
+	if len(enc.open) > 1 {
+		enc.open = enc.open[:len(enc.open)-1]
+	}
+}
+
+// Path adds the compiled path c.
+func (enc *Encoder) Path(c *CompiledPath, s Style) *Element {
+	return enc.PathData((&PathEncoder{Precision: enc.Precision}).Encode(c), s)
+}
+
+// PathData adds a path with the path data d, which is written as given.
+func (enc *Encoder) PathData(d string, s Style) *Element {
+	return enc.add("path", &s, "d", d)
+}
+
+// Rect adds a rectangle.
+func (enc *Encoder) Rect(x, y, w, h float64, s Style) *Element {
+	return enc.add("rect", &s, "x", enc.format(x), "y", enc.format(y), "width", enc.format(w), "height", enc.format(h))
+}
+
+// Circle adds a circle.
+func (enc *Encoder) Circle(cx, cy, r float64, s Style) *Element {
+	return enc.add("circle", &s, "cx", enc.format(cx), "cy", enc.format(cy), "r", enc.format(r))
+}
+
+// Ellipse adds an ellipse.
+func (enc *Encoder) Ellipse(cx, cy, rx, ry float64, s Style) *Element {
+	return enc.add("ellipse", &s, "cx", enc.format(cx), "cy", enc.format(cy), "rx", enc.format(rx), "ry", enc.format(ry))
+}
+
+// Line adds a line segment.
+func (enc *Encoder) Line(x1, y1, x2, y2 float64, s Style) *Element {
+	return enc.add("line", &s, "x1", enc.format(x1), "y1", enc.format(y1), "x2", enc.format(x2), "y2", enc.format(y2))
+}
+
+// Polyline adds an open polyline through pts.
+func (enc *Encoder) Polyline(pts []gg.Point, s Style) *Element {
+	return enc.add("polyline", &s, "points", enc.points(pts))
+}
+
+// Polygon adds a closed polygon through pts.
+func (enc *Encoder) Polygon(pts []gg.Point, s Style) *Element {
+	return enc.add("polygon", &s, "points", enc.points(pts))
+}
+
+// Document returns the document built so far, for drawing it without
+// writing it out first. It shares its elements with the Encoder.
+func (enc *Encoder) Document() (*Document, error) {
+	doc := &Document{Root: enc.root}
+	if err := doc.readViewport(); err != nil {
+		return nil, err
+	}
+	doc.indexIDs()
+	return doc, nil
+}
+
+// Close closes any open groups and writes the document.
+func (enc *Encoder) Close() error {
+	enc.open = enc.open[:1]
+	_, err := writeDocument(enc.w, enc.root)
+	return err
+}
+
+// add appends an element with the given attribute names and values, and
+// the attributes of s if it is not nil, to the innermost open group.
+func (enc *Encoder) add(name string, s *Style, attrs ...string) *Element {
+	e := &Element{Name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		e.Attrs = append(e.Attrs, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	if s != nil {
+		e.Attrs = append(e.Attrs, enc.styleAttrs(*s)...)
+	}
+	parent := enc.open[len(enc.open)-1]
+	parent.Children = append(parent.Children, e)
+	return e
+}
+
+// styleAttrs returns the presentation attributes for s.
+func (enc *Encoder) styleAttrs(s Style) []xml.Attr {
+	var attrs []xml.Attr
+	set := func(name, value string) {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+	paint := func(name string, c color.Color) {
+		if c == nil {
+			set(name, "none")
+			return
+		}
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		set(name, fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B))
+		if n.A != 255 {
+			// 8-bit alpha needs no more than three digits
+			set(name+"-opacity", (&PathEncoder{Precision: 3}).format(float64(n.A)/255))
+		}
+	}
+	paint("fill", s.Fill)
+	if s.Fill != nil && s.FillRule == gg.FillRuleEvenOdd {
+		set("fill-rule", "evenodd")
+	}
+	if s.Stroke == nil || s.Width <= 0 {
+		return attrs
+	}
+	paint("stroke", s.Stroke)
+	set("stroke-width", enc.format(s.Width))
+	switch s.Cap {
+	case gg.LineCapRound:
+		set("stroke-linecap", "round")
+	case gg.LineCapButt:
+		set("stroke-linecap", "butt")
+	case gg.LineCapSquare:
+		set("stroke-linecap", "square")
+	}
+	switch {
+	case s.MiterLimit > 0:
+		set("stroke-linejoin", "miter")
+		set("stroke-miterlimit", enc.format(s.MiterLimit))
+	case s.Join == gg.LineJoinBevel:
+		set("stroke-linejoin", "bevel")
+	default:
+		set("stroke-linejoin", "round")
+	}
+	if len(s.Dashes) > 0 {
+		set("stroke-dasharray", enc.formatList(s.Dashes...))
+		if s.DashOffset != 0 {
+			set("stroke-dashoffset", enc.format(s.DashOffset))
+		}
+	}
+	return attrs
+}
+
+// transform returns the transform attribute for m, or "" for the identity.
+func (enc *Encoder) transform(m gg.Matrix) string {
+	switch {
+	case m == gg.Identity():
+		return ""
+	case m.XX == 1 && m.YX == 0 && m.XY == 0 && m.YY == 1:
+		return "translate(" + enc.formatList(m.X0, m.Y0) + ")"
+	}
+	// rounding the linear part would distort everything inside the group
+	exact := NewPathEncoder()
+	linear := []string{exact.format(m.XX), exact.format(m.YX), exact.format(m.XY), exact.format(m.YY)}
+	return "matrix(" + strings.Join(linear, " ") + " " + enc.formatList(m.X0, m.Y0) + ")"
+}
+
+func (enc *Encoder) points(pts []gg.Point) string {
+	s := make([]string, len(pts))
+	for i, p := range pts {
+		s[i] = enc.format(p.X) + "," + enc.format(p.Y)
+	}
+	return strings.Join(s, " ")
+}
+
+func (enc *Encoder) formatList(vs ...float64) string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = enc.format(v)
+	}
+	return strings.Join(s, " ")
+}
+
+func (enc *Encoder) format(v float64) string {
+	return (&PathEncoder{Precision: enc.Precision}).format(v)
+}
@@ -0,0 +1,242 @@
+package svgg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrLimitExceeded is returned when a document exceeds one of the limits
+// configured on the Decoder.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Default limits on the expansion of entities declared in a document's DTD.
+const (
+	DefaultMaxEntityDepth     = 8
+	DefaultMaxEntityExpansion = 1 << 20
+)
+
+// entityExpander expands internal entity declarations from a DOCTYPE
+// internal subset while enforcing depth and size limits.
+type entityExpander struct {
+	decls    map[string]string // raw replacement text
+	expanded map[string]string
+	maxDepth int
+	maxTotal int
+	total    int
+}
+
+// readDoctype parses the <!ENTITY> declarations of a DOCTYPE directive and
+// adds placeholders for their expansions to entities, which expandEntities
+// replaces as the document is read. Parameter entities and external
+// entities are ignored; external entities are never fetched.
+func (d *Decoder) readDoctype(directive string, entities map[string]string) error {
+	if !strings.HasPrefix(directive, "DOCTYPE") {
+		return nil
+	}
+	ex := &entityExpander{
+		decls:    make(map[string]string),
+		expanded: make(map[string]string),
+		maxDepth: d.MaxEntityDepth,
+		maxTotal: d.MaxEntityExpansion,
+	}
+	if ex.maxDepth <= 0 {
+		ex.maxDepth = DefaultMaxEntityDepth
+	}
+	if ex.maxTotal <= 0 {
+		ex.maxTotal = DefaultMaxEntityExpansion
+	}
+	var names []string
+	s := directive
+	for {
+		i := strings.Index(s, "<!ENTITY")
+		if i < 0 {
+			break
+		}
+		s = strings.TrimLeft(s[i+len("<!ENTITY"):], " \t\r\n")
+		if strings.HasPrefix(s, "%") {
+			continue
+		}
+		end := strings.IndexAny(s, " \t\r\n")
+		if end < 0 {
+			break
+		}
+		name := s[:end]
+		s = strings.TrimLeft(s[end:], " \t\r\n")
+		if s == "" || (s[0] != '"' && s[0] != '\'') {
+			// SYSTEM or PUBLIC entity
+			continue
+		}
+		close := strings.IndexByte(s[1:], s[0])
+		if close < 0 {
+			return fmt.Errorf("unterminated entity declaration %q", name)
+		}
+		if _, ok := ex.decls[name]; !ok {
+			// the first declaration is binding
+			ex.decls[name] = s[1 : close+1]
+			names = append(names, name)
+		}
+		s = s[close+2:]
+	}
+	for _, name := range names {
+		v, err := ex.expand(name, 0)
+		if err != nil {
+			return err
+		}
+		// encoding/xml would copy the value in at every reference with no
+		// bound, so it is given a placeholder that expandEntities replaces
+		// and charges against the limit
+		entities[name] = d.entityPlaceholder(len(d.entities))
+		d.entities = append(d.entities, v)
+	}
+	return nil
+}
+
+// entityPlaceholder returns the text standing for the i'th declared
+// entity. XML text may hold any other character, so placeholders start
+// with a noncharacter and a random nonce that documents cannot guess.
+func (d *Decoder) entityPlaceholder(i int) string {
+	if d.entityMark == "" {
+		var nonce [8]byte
+		if _, err := rand.Read(nonce[:]); err != nil {
+			panic(err)
+		}
+		d.entityMark = "\ufdd0" + hex.EncodeToString(nonce[:]) + ":"
+	}
+	return d.entityMark + strconv.Itoa(i) + "\ufdd1"
+}
+
+// expandEntities replaces the entity placeholders in s with their values,
+// counting the bytes they add against MaxEntityExpansion, so that a large
+// entity referenced many times cannot blow up the document.
+func (d *Decoder) expandEntities(s string) (string, error) {
+	if len(d.entities) == 0 || !strings.Contains(s, d.entityMark) {
+		return s, nil
+	}
+	max := d.MaxEntityExpansion
+	if max <= 0 {
+		max = DefaultMaxEntityExpansion
+	}
+	// the values are looked up and charged before any is copied
+	var values []string
+	var rest []string // the text around the placeholders
+	for {
+		i := strings.Index(s, d.entityMark)
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i:], "\ufdd1")
+		if j < 0 {
+			break
+		}
+		k, err := strconv.Atoi(s[i+len(d.entityMark) : i+j])
+		if err != nil || k < 0 || k >= len(d.entities) {
+			break
+		}
+		v := d.entities[k]
+		d.entityBytes += len(v)
+		if d.entityBytes > max {
+			return "", fmt.Errorf("%w: entity expansion exceeds %d bytes", ErrLimitExceeded, max)
+		}
+		rest = append(rest, s[:i])
+		values = append(values, v)
+		s = s[i+j+len("\ufdd1"):]
+	}
+	var b strings.Builder
+	for i, v := range values {
+		b.WriteString(rest[i])
+		b.WriteString(v)
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+// expand returns the replacement text of the entity name with all nested
+// references expanded.
+func (ex *entityExpander) expand(name string, depth int) (string, error) {
+	if v, ok := ex.expanded[name]; ok {
+		ex.total += len(v)
+		if ex.total > ex.maxTotal {
+			return "", fmt.Errorf("%w: entity expansion exceeds %d bytes", ErrLimitExceeded, ex.maxTotal)
+		}
+		return v, nil
+	}
+	if depth >= ex.maxDepth {
+		return "", fmt.Errorf("%w: entity &%s; nested more than %d deep", ErrLimitExceeded, name, ex.maxDepth)
+	}
+	raw := ex.decls[name]
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(raw, '&')
+		if i < 0 {
+			b.WriteString(raw)
+			break
+		}
+		b.WriteString(raw[:i])
+		raw = raw[i+1:]
+		j := strings.IndexByte(raw, ';')
+		if j < 0 {
+			b.WriteByte('&')
+			continue
+		}
+		ref := raw[:j]
+		raw = raw[j+1:]
+		if r, ok := charRef(ref); ok {
+			b.WriteString(r)
+			continue
+		}
+		if _, ok := ex.decls[ref]; !ok {
+			b.WriteString("&" + ref + ";")
+			continue
+		}
+		v, err := ex.expand(ref, depth+1)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(v)
+		if b.Len() > ex.maxTotal {
+			return "", fmt.Errorf("%w: entity expansion exceeds %d bytes", ErrLimitExceeded, ex.maxTotal)
+		}
+	}
+	v := b.String()
+	ex.expanded[name] = v
+	ex.total += len(v)
+	if ex.total > ex.maxTotal {
+		return "", fmt.Errorf("%w: entity expansion exceeds %d bytes", ErrLimitExceeded, ex.maxTotal)
+	}
+	return v, nil
+}
+
+// predefinedEntities are the entities every XML processor recognizes.
+var predefinedEntities = map[string]string{
+	"lt":   "<",
+	"gt":   ">",
+	"amp":  "&",
+	"apos": "'",
+	"quot": `"`,
+}
+
+// charRef decodes a character reference or predefined entity, given the
+// text between '&' and ';'.
+func charRef(ref string) (string, bool) {
+	if v, ok := predefinedEntities[ref]; ok {
+		return v, true
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	var n uint64
+	var err error
+	if strings.HasPrefix(ref, "#x") {
+		n, err = strconv.ParseUint(ref[2:], 16, 32)
+	} else {
+		n, err = strconv.ParseUint(ref[1:], 10, 32)
+	}
+	if err != nil {
+		return "", false
+	}
+	return string(rune(n)), true
+}
@@ -0,0 +1,37 @@
+package svgg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEntityExpansion(t *testing.T) {
+	doc, err := ParseDocument([]byte(`<!DOCTYPE svg [<!ENTITY c "red"><!ENTITY t "a &amp; b">]>` +
+		`<svg xmlns="http://www.w3.org/2000/svg"><rect fill="&c;"/><text>&t; &lt; &t;</text></svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Root.Children[0].Attr("fill"); got != "red" {
+		t.Errorf("fill = %q, want red", got)
+	}
+	if got := doc.Root.Children[1].Text; got != "a & b < a & b" {
+		t.Errorf("text = %q, want %q", got, "a & b < a & b")
+	}
+}
+
+func TestEntityExpansionRepeatedReferences(t *testing.T) {
+	// each reference alone is within the limit, but not all of them
+	decl := `<!DOCTYPE svg [<!ENTITY a "` + strings.Repeat("x", 1000) + `">]>`
+	for _, body := range []string{
+		`<text>` + strings.Repeat("&a;", 100) + `</text>`,
+		strings.Repeat(`<text>&a;</text>`, 100),
+		strings.Repeat(`<g id="&a;"/>`, 100),
+	} {
+		d := NewDecoder(strings.NewReader(decl + `<svg xmlns="http://www.w3.org/2000/svg">` + body + `</svg>`))
+		d.MaxEntityExpansion = 50000
+		if _, err := d.Decode(); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%.40s...: got error %v, want ErrLimitExceeded", body, err)
+		}
+	}
+}
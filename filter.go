@@ -0,0 +1,104 @@
+package svgg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/fogleman/gg"
+)
+
+// PostFilter maps the color of each pixel of a drawn document, such as to
+// gray out a disabled icon. See RenderOptions.Filters.
+type PostFilter func(c color.NRGBA) color.NRGBA
+
+// Grayscale returns a PostFilter replacing each color by its luminance.
+func Grayscale() PostFilter {
+	return func(c color.NRGBA) color.NRGBA {
+		y := luminance(c)
+		return color.NRGBA{y, y, y, c.A}
+	}
+}
+
+// Sepia returns a PostFilter toning colors brown, as the CSS sepia(1)
+// filter does.
+func Sepia() PostFilter {
+	return func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		return color.NRGBA{
+			R: clampByte(0.393*r + 0.769*g + 0.189*b),
+			G: clampByte(0.349*r + 0.686*g + 0.168*b),
+			B: clampByte(0.272*r + 0.534*g + 0.131*b),
+			A: c.A,
+		}
+	}
+}
+
+// Tint returns a PostFilter mixing each color with tint by amount, from 0
+// for none to 1 for tint alone, keeping the alpha of each pixel, such as
+// to recolor a hovered icon.
+func Tint(tint color.Color, amount float64) PostFilter {
+	t := color.NRGBAModel.Convert(tint).(color.NRGBA)
+	a := clamp(amount, 0, 1) * float64(t.A) / 255
+	mix := func(v, w uint8) uint8 {
+		return clampByte(float64(v) + (float64(w)-float64(v))*a)
+	}
+	return func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{mix(c.R, t.R), mix(c.G, t.G), mix(c.B, t.B), c.A}
+	}
+}
+
+// Opacity returns a PostFilter multiplying the alpha of each pixel by
+// alpha, for a translucent document whose overlapping shapes do not show
+// through each other.
+func Opacity(alpha float64) PostFilter {
+	alpha = clamp(alpha, 0, 1)
+	return func(c color.NRGBA) color.NRGBA {
+		c.A = clampByte(float64(c.A) * alpha)
+		return c
+	}
+}
+
+// luminance returns the Rec. 709 luma of c, as CSS grayscale() uses.
+func luminance(c color.NRGBA) uint8 {
+	return clampByte(0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B))
+}
+
+func clampByte(v float64) uint8 {
+	return uint8(clamp(v, 0, 255) + 0.5)
+}
+
+// drawFiltered draws the document to a layer the size of dc with dc's
+// matrix, applies the filters to it and composites it onto dc, ignoring
+// dc's clip.
+func (doc *Document) drawFiltered(dc *gg.Context, opts RenderOptions) error {
+	filters := opts.Filters
+	// the background is painted before the document and not filtered
+	opts.Filters, opts.Background = nil, nil
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	applyMatrix(layer, currentMatrix(dc))
+	err := doc.DrawWithOptions(layer, &opts)
+	im := layer.Image().(*image.RGBA)
+	applyFilters(im, filters)
+	if dst, ok := dc.Image().(*image.RGBA); ok {
+		draw.Draw(dst, dst.Bounds(), im, image.Point{}, draw.Over)
+	}
+	return err
+}
+
+// applyFilters applies the filters in turn to the pixels of im that are
+// not transparent.
+func applyFilters(im *image.RGBA, filters []PostFilter) {
+	p := im.Pix
+	for i := 0; i+3 < len(p); i += 4 {
+		if p[i+3] == 0 {
+			continue
+		}
+		c := color.NRGBAModel.Convert(color.RGBA{p[i], p[i+1], p[i+2], p[i+3]}).(color.NRGBA)
+		for _, f := range filters {
+			c = f(c)
+		}
+		r, g, b, a := c.RGBA()
+		p[i], p[i+1], p[i+2], p[i+3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+	}
+}
@@ -0,0 +1,88 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// DefaultTolerance is the flattening tolerance used when a tolerance of
+// zero or less is given: a quarter of a unit, which is invisible when a
+// unit is a pixel.
+const DefaultTolerance = 0.25
+
+// Flatten returns the subpaths of the path as polylines that stay within
+// tol of the curves they replace. Closed subpaths end with their first
+// point repeated. Subpaths that draw nothing are left out.
+func (c *CompiledPath) Flatten(tol float64) [][]gg.Point {
+	if tol <= 0 {
+		tol = DefaultTolerance
+	}
+	var subpaths [][]gg.Point
+	var cur []gg.Point
+	end := func() {
+		if len(cur) > 1 {
+			subpaths = append(subpaths, cur)
+		}
+		cur = nil
+	}
+	c.forEach(func(from gg.Point, s Segment) {
+		if s.Op == MoveOp {
+			end()
+			return
+		}
+		if cur == nil {
+			cur = append(cur, from)
+		}
+		flattenSegment(from, s, tol, func(p gg.Point) {
+			if p != cur[len(cur)-1] {
+				cur = append(cur, p)
+			}
+		})
+		if s.Op == CloseOp {
+			end()
+		}
+	})
+	end()
+	return subpaths
+}
+
+// flattenSegment passes the points of a polyline within tol of segment s,
+// starting at from, to emit. The start point itself is not emitted.
+func flattenSegment(from gg.Point, s Segment, tol float64, emit func(gg.Point)) {
+	n := flattenSteps(from, s, tol)
+	for i := 1; i < n; i++ {
+		emit(pointOn(from, s, float64(i)/float64(n)))
+	}
+	emit(s.End())
+}
+
+// flattenSteps returns the number of line segments needed to approximate
+// segment s within tol, from bounds on its curvature.
+func flattenSteps(from gg.Point, s Segment, tol float64) int {
+	var n float64
+	switch s.Op {
+	case QuadOp:
+		dd := math.Hypot(from.X-2*s.P[0].X+s.P[1].X, from.Y-2*s.P[0].Y+s.P[1].Y)
+		n = math.Sqrt(dd / (4 * tol))
+	case CubicOp:
+		dd1 := math.Hypot(from.X-2*s.P[0].X+s.P[1].X, from.Y-2*s.P[0].Y+s.P[1].Y)
+		dd2 := math.Hypot(s.P[0].X-2*s.P[1].X+s.P[2].X, s.P[0].Y-2*s.P[1].Y+s.P[2].Y)
+		n = math.Sqrt(0.75 * math.Max(dd1, dd2) / tol)
+	case ArcOp:
+		r := math.Max(s.Arc.Rx, s.Arc.Ry)
+		if tol >= r {
+			n = math.Abs(s.Arc.DTheta) / (2 * math.Pi / 3)
+		} else {
+			n = math.Abs(s.Arc.DTheta) / (2 * math.Acos(1-tol/r))
+		}
+	}
+	const maxSteps = 1 << 16
+	if n > maxSteps {
+		return maxSteps
+	}
+	if n < 1 {
+		return 1
+	}
+	return int(math.Ceil(n))
+}
@@ -0,0 +1,282 @@
+package svgg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomediumitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// Font is a TrueType or OpenType font that text is drawn with.
+type Font struct {
+	Family string // family name matched against font-family
+	Weight int    // from 100 to 900, where 400 is normal and 700 bold
+	Italic bool
+
+	sfnt *sfnt.Font
+}
+
+// ParseFont parses TrueType or OpenType font data. The family is read from
+// the font's name table, and the weight and style from its subfamily name,
+// such as "Bold Italic". A weight at the end of the family name, as in
+// "Go Medium", is moved to the weight.
+func ParseFont(data []byte) (*Font, error) {
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("svgg: parsing font: %w", err)
+	}
+	var buf sfnt.Buffer
+	family, err := f.Name(&buf, sfnt.NameIDTypographicFamily)
+	if err != nil {
+		if family, err = f.Name(&buf, sfnt.NameIDFamily); err != nil {
+			return nil, fmt.Errorf("svgg: reading font family: %w", err)
+		}
+	}
+	sub, err := f.Name(&buf, sfnt.NameIDTypographicSubfamily)
+	if err != nil {
+		sub, _ = f.Name(&buf, sfnt.NameIDSubfamily)
+	}
+	font := &Font{Family: family, Weight: 400, sfnt: f}
+	if i := strings.LastIndexByte(family, ' '); i > 0 {
+		if _, ok := fontWeights[strings.ToLower(family[i+1:])]; ok {
+			font.Family, sub = family[:i], family[i+1:]+" "+sub
+		}
+	}
+	for _, w := range strings.Fields(strings.ToLower(sub)) {
+		switch w {
+		case "italic", "oblique":
+			font.Italic = true
+		case "regular", "normal":
+		default:
+			if n, ok := fontWeights[w]; ok {
+				font.Weight = n
+			}
+		}
+	}
+	return font, nil
+}
+
+// fontWeights are the numeric weights of the names used for them in font
+// subfamilies and font-weight values.
+var fontWeights = map[string]int{
+	"thin":       100,
+	"extralight": 200,
+	"ultralight": 200,
+	"light":      300,
+	"normal":     400,
+	"regular":    400,
+	"medium":     500,
+	"semibold":   600,
+	"demibold":   600,
+	"bold":       700,
+	"extrabold":  800,
+	"ultrabold":  800,
+	"black":      900,
+	"heavy":      900,
+}
+
+// FontRegistry resolves font-family names to Fonts. It is safe for
+// concurrent use.
+type FontRegistry struct {
+	mu       sync.RWMutex
+	families map[string][]*Font // by lower-case family name or alias
+	fallback []string           // families tried for missing glyphs
+	once     sync.Once
+	init     func(*FontRegistry)
+}
+
+// NewFontRegistry returns an empty FontRegistry.
+func NewFontRegistry() *FontRegistry {
+	return &FontRegistry{}
+}
+
+// DefaultFonts holds the Go fonts, which are used for text in documents
+// whose Fonts registry is nil. Go and Go Mono are also registered as the
+// generic families sans-serif, serif and monospace.
+var DefaultFonts = &FontRegistry{init: registerGoFonts}
+
+func registerGoFonts(reg *FontRegistry) {
+	for _, ttf := range [][]byte{
+		goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF,
+		gomedium.TTF, gomediumitalic.TTF,
+	} {
+		reg.register(mustParseFont(ttf), "sans-serif", "serif")
+	}
+	for _, ttf := range [][]byte{gomono.TTF, gomonobold.TTF, gomonoitalic.TTF, gomonobolditalic.TTF} {
+		reg.register(mustParseFont(ttf), "monospace")
+	}
+}
+
+func mustParseFont(data []byte) *Font {
+	f, err := ParseFont(data)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (reg *FontRegistry) load() {
+	reg.once.Do(func() {
+		if reg.init != nil {
+			reg.init(reg)
+		}
+	})
+}
+
+// Register adds f under its family name and the given aliases, such as the
+// generic families "serif" or "monospace".
+func (reg *FontRegistry) Register(f *Font, aliases ...string) {
+	reg.load()
+	reg.register(f, aliases...)
+}
+
+func (reg *FontRegistry) register(f *Font, aliases ...string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.families == nil {
+		reg.families = make(map[string][]*Font)
+	}
+	for _, name := range append([]string{f.Family}, aliases...) {
+		name = strings.ToLower(name)
+		reg.families[name] = append(reg.families[name], f)
+	}
+}
+
+// SetFallback sets the families whose fonts are tried, in order, for a
+// character that none of the families named by the font-family of the text
+// has a glyph for, such as a CJK font and then a symbol font after Latin
+// ones. The last resort is sans-serif.
+func (reg *FontRegistry) SetFallback(families ...string) {
+	reg.load()
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.fallback = append([]string(nil), families...)
+}
+
+// Family returns the fonts registered under the family name or alias.
+func (reg *FontRegistry) Family(name string) []*Font {
+	reg.load()
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.families[strings.ToLower(name)]
+}
+
+// fontMatch is the face chosen for a character of text. bold and oblique
+// report that the face is lighter than a requested bold weight or upright
+// where italic was requested, so that the renderer may synthesize them.
+type fontMatch struct {
+	font          *Font
+	index         sfnt.GlyphIndex
+	bold, oblique bool
+}
+
+// match returns the face for the character r in text set in the font
+// families, with the given weight and style. Each family is tried in turn,
+// then the fallback families and sans-serif, and the first whose closest
+// face has a glyph for r is used. If none has, the closest face of the
+// first registered family draws its missing glyph.
+func (reg *FontRegistry) match(buf *sfnt.Buffer, r rune, families []string, weight int, italic bool) fontMatch {
+	reg.mu.RLock()
+	fallback := reg.fallback
+	reg.mu.RUnlock()
+	var first fontMatch
+	names := append(append(families[:len(families):len(families)], fallback...), "sans-serif")
+	for _, name := range names {
+		faces := reg.Family(name)
+		if len(faces) == 0 {
+			continue
+		}
+		f := matchFace(faces, weight, italic)
+		m := fontMatch{font: f, bold: weight >= 600 && f.Weight < 600, oblique: italic && !f.Italic}
+		var err error
+		if m.index, err = f.sfnt.GlyphIndex(buf, r); err == nil && m.index != 0 {
+			return m
+		}
+		if first.font == nil {
+			first = m
+		}
+	}
+	return first
+}
+
+// matchFace returns the face closest to the given weight and style, as CSS
+// matches them: the style first, then the weight among faces of that
+// style.
+func matchFace(faces []*Font, weight int, italic bool) *Font {
+	var styled []*Font
+	for _, f := range faces {
+		if f.Italic == italic {
+			styled = append(styled, f)
+		}
+	}
+	if len(styled) == 0 {
+		styled = faces
+	}
+	f := styled[0]
+	for _, c := range styled[1:] {
+		if weightDistance(weight, c.Weight) < weightDistance(weight, f.Weight) {
+			f = c
+		}
+	}
+	return f
+}
+
+// weightDistance orders the weights of faces by how well they match the
+// desired weight, following the CSS font matching algorithm: for weights
+// from 400 to 500, heavier faces up to 500 are tried first, then lighter
+// faces, then heavier ones; lighter desired weights prefer lighter faces,
+// and heavier ones heavier faces.
+func weightDistance(want, have int) int {
+	switch {
+	case want >= 400 && want <= 500:
+		if have >= want && have <= 500 {
+			return have - want
+		}
+		if have < want {
+			return 1000 + want - have
+		}
+		return 2000 + have - want
+	case want < 400:
+		if have <= want {
+			return want - have
+		}
+		return 1000 + have - want
+	default:
+		if have >= want {
+			return have - want
+		}
+		return 1000 + want - have
+	}
+}
+
+// fonts returns the registry text in the document is drawn with.
+func (doc *Document) fonts() *FontRegistry {
+	if doc.Fonts != nil {
+		return doc.Fonts
+	}
+	return DefaultFonts
+}
+
+// parseFontFamily splits a font-family value into family names, removing
+// quotes.
+func parseFontFamily(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.Trim(strings.TrimSpace(name), `"'`)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,277 @@
+package svgg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// DefaultFPS is the frame rate of a FrameRenderer whose FPS is zero.
+const DefaultFPS = 25
+
+// FrameRenderer samples the animations of a Document at a fixed frame
+// rate, for exporting animated icons and loaders to GIF or APNG.
+type FrameRenderer struct {
+	Doc *Document
+
+	// FPS is the number of frames drawn per second of animation.
+	FPS float64
+
+	// Duration is the length of animation to sample. If zero, the
+	// document's AnimationDuration is used, which loops seamlessly for
+	// animations that repeat forever.
+	Duration time.Duration
+
+	// Scale is the size of a document pixel in frame pixels. If zero, the
+	// document is drawn at its own size.
+	Scale float64
+
+	// Options configures how each frame is drawn. It may be nil; Animate
+	// and Time are set for every frame.
+	Options *RenderOptions
+}
+
+// NewFrameRenderer returns a FrameRenderer drawing doc at its own size and
+// DefaultFPS.
+func NewFrameRenderer(doc *Document) *FrameRenderer {
+	return &FrameRenderer{Doc: doc, FPS: DefaultFPS, Scale: 1}
+}
+
+func (f *FrameRenderer) fps() float64 {
+	if f.FPS > 0 {
+		return f.FPS
+	}
+	return DefaultFPS
+}
+
+func (f *FrameRenderer) scale() float64 {
+	if f.Scale > 0 {
+		return f.Scale
+	}
+	return 1
+}
+
+// FrameCount returns the number of frames sampled, which is at least one.
+func (f *FrameRenderer) FrameCount() int {
+	d := f.Duration
+	if d <= 0 {
+		d = f.Doc.AnimationDuration()
+	}
+	n := int(math.Ceil(d.Seconds()*f.fps() - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// FrameTime returns the time into the animation at which frame i is drawn.
+func (f *FrameRenderer) FrameTime(i int) time.Duration {
+	return time.Duration(float64(i) / f.fps() * float64(time.Second))
+}
+
+// RenderFrame draws frame i.
+func (f *FrameRenderer) RenderFrame(i int) (*image.RGBA, error) {
+	s := f.scale()
+	w := int(math.Ceil(f.Doc.Width*s - 1e-9))
+	h := int(math.Ceil(f.Doc.Height*s - 1e-9))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dc := gg.NewContext(w, h)
+	dc.Scale(s, s)
+	var opts RenderOptions
+	if f.Options != nil {
+		opts = *f.Options
+	}
+	opts.Animate, opts.Time = true, f.FrameTime(i)
+	err := f.Doc.DrawWithOptions(dc, &opts)
+	return dc.Image().(*image.RGBA), err
+}
+
+// delays returns the display time of each of n frames in units of unit,
+// rounded so that they add up to the right total.
+func (f *FrameRenderer) delays(n int, unit time.Duration) []int {
+	d := make([]int, n)
+	prev := 0
+	for i := range d {
+		next := int(math.Round(float64(f.FrameTime(i+1)) / float64(unit)))
+		d[i] = next - prev
+		prev = next
+	}
+	return d
+}
+
+// apngDelay returns the fraction of a second, as written in an fcTL chunk,
+// closest to ms milliseconds. The denominator is coarsened until the
+// numerator fits in 16 bits, and delays too long even in whole seconds
+// are clamped.
+func apngDelay(ms int) (num, den uint16) {
+	n, d := ms, 1000
+	for n > math.MaxUint16 && d > 1 {
+		n, d = (n+5)/10, d/10
+	}
+	if n > math.MaxUint16 {
+		n = math.MaxUint16
+	}
+	return uint16(n), uint16(d)
+}
+
+// EncodeGIF writes the frames as a looping animated GIF. Colors are
+// dithered to the web-safe palette, and pixels less than half opaque are
+// left transparent.
+func (f *FrameRenderer) EncodeGIF(w io.Writer) error {
+	n := f.FrameCount()
+	pal := append(color.Palette{color.RGBA{}}, palette.WebSafe...)
+	anim := &gif.GIF{Delay: f.delays(n, 10*time.Millisecond)}
+	for i, d := range anim.Delay {
+		// GIF delays are 16 bits
+		if d > math.MaxUint16 {
+			anim.Delay[i] = math.MaxUint16
+		}
+	}
+	for i := 0; i < n; i++ {
+		im, err := f.RenderFrame(i)
+		if err != nil {
+			return err
+		}
+		// GIF has no partial transparency, so make every pixel either
+		// transparent or opaque before dithering
+		for p := 3; p < len(im.Pix); p += 4 {
+			if im.Pix[p] < 128 {
+				im.Pix[p-3], im.Pix[p-2], im.Pix[p-1], im.Pix[p] = 0, 0, 0, 0
+			} else if a := im.Pix[p]; a < 255 {
+				for k := p - 3; k < p; k++ {
+					im.Pix[k] = uint8(int(im.Pix[k]) * 255 / int(a))
+				}
+				im.Pix[p] = 255
+			}
+		}
+		pm := image.NewPaletted(im.Bounds(), pal)
+		draw.FloydSteinberg.Draw(pm, im.Bounds(), im, image.Point{})
+		anim.Image = append(anim.Image, pm)
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// EncodeAPNG writes the frames as a looping animated PNG, which keeps full
+// color and alpha. Viewers without APNG support show the first frame.
+func (f *FrameRenderer) EncodeAPNG(w io.Writer) error {
+	n := f.FrameCount()
+	delays := f.delays(n, time.Millisecond)
+	pw := &pngWriter{w: w}
+	pw.write([]byte("\x89PNG\r\n\x1a\n"))
+	var seq uint32
+	for i := 0; i < n; i++ {
+		im, err := f.RenderFrame(i)
+		if err != nil {
+			return err
+		}
+		b := im.Bounds()
+		if i == 0 {
+			pw.chunk("IHDR", pngUint32(uint32(b.Dx())), pngUint32(uint32(b.Dy())), []byte{8, 6, 0, 0, 0})
+			pw.chunk("acTL", pngUint32(uint32(n)), pngUint32(0))
+		}
+		// frame control: size, offset, delay, no disposal and source
+		// blending, so every frame replaces the last outright
+		fc := []byte{0, 0, 0, 0, 0, 0, 0, 0}
+		num, den := apngDelay(delays[i])
+		delay := []byte{0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint16(delay, num)
+		binary.BigEndian.PutUint16(delay[2:], den)
+		pw.chunk("fcTL", pngUint32(seq), pngUint32(uint32(b.Dx())), pngUint32(uint32(b.Dy())), fc, delay)
+		seq++
+		data, err := pngImageData(im)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			pw.chunk("IDAT", data)
+		} else {
+			pw.chunk("fdAT", pngUint32(seq), data)
+			seq++
+		}
+	}
+	pw.chunk("IEND")
+	return pw.err
+}
+
+// pngWriter writes PNG chunks, keeping the first error.
+type pngWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (pw *pngWriter) write(b []byte) {
+	if pw.err == nil {
+		_, pw.err = pw.w.Write(b)
+	}
+}
+
+// chunk writes a chunk of the given type whose data is the concatenation
+// of parts.
+func (pw *pngWriter) chunk(typ string, parts ...[]byte) {
+	size := 0
+	for _, p := range parts {
+		size += len(p)
+	}
+	pw.write(pngUint32(uint32(size)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(typ))
+	pw.write([]byte(typ))
+	for _, p := range parts {
+		crc.Write(p)
+		pw.write(p)
+	}
+	pw.write(pngUint32(crc.Sum32()))
+}
+
+func pngUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// pngImageData returns the compressed scanlines of im as 8-bit RGBA with
+// straight alpha, each filtered by its difference from the pixel to the
+// left.
+func pngImageData(im *image.RGBA) ([]byte, error) {
+	b := im.Bounds()
+	nrgba := image.NewNRGBA(b)
+	draw.Draw(nrgba, b, im, b.Min, draw.Src)
+	var buf bytes.Buffer
+	z := zlib.NewWriter(&buf)
+	row := make([]byte, 1+4*b.Dx())
+	row[0] = 1 // the Sub filter
+	for y := 0; y < b.Dy(); y++ {
+		pix := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+4*b.Dx()]
+		for x := range pix {
+			left := byte(0)
+			if x >= 4 {
+				left = pix[x-4]
+			}
+			row[1+x] = pix[x] - left
+		}
+		if _, err := z.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -1,9 +1,9 @@
 module github.com/engelsjk/svgg
 
-go 1.15
+go 1.16
 
 require (
 	github.com/fogleman/gg v1.3.0
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb // indirect
+	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
 )
@@ -0,0 +1,306 @@
+package svgg
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// gradientStop is a stop of a gradient, with the alpha of its stop-color
+// already multiplied by its stop-opacity.
+type gradientStop struct {
+	offset float64
+	color  color.NRGBA
+}
+
+// gradient is a resolved linearGradient or radialGradient paint server.
+type gradient struct {
+	radial         bool
+	x1, y1, x2, y2 float64 // vector of a linear gradient
+	cx, cy, r      float64 // end circle of a radial gradient
+	fx, fy, fr     float64 // focal circle of a radial gradient
+	userSpace      bool    // gradientUnits="userSpaceOnUse"
+	transform      gg.Matrix
+	spread         string
+	linearRGB      bool // interpolate stops in linear light
+	stops          []gradientStop
+}
+
+// gradient resolves the gradient referenced by ref, following href links
+// to the gradients it inherits attributes and stops from.
+func (doc *Document) gradient(ref string) (*gradient, error) {
+	var chain []*Element
+	for ref != "" {
+		e, err := doc.lookupRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chain {
+			if c == e {
+				return nil, fmt.Errorf("circular reference to %q", ref)
+			}
+		}
+		if e.Name != "linearGradient" && e.Name != "radialGradient" {
+			if len(chain) == 0 {
+				return nil, fmt.Errorf("%w: paint server <%s>", ErrNotImplemented, e.Name)
+			}
+			break
+		}
+		chain = append(chain, e)
+		ref, _ = href(e)
+	}
+	attr := func(name, def string) string {
+		for _, e := range chain {
+			if v, ok := e.LookupAttr(name); ok {
+				return strings.TrimSpace(v)
+			}
+		}
+		return def
+	}
+
+	g := &gradient{
+		radial:    chain[0].Name == "radialGradient",
+		userSpace: attr("gradientUnits", "") == "userSpaceOnUse",
+		spread:    attr("spreadMethod", "pad"),
+		linearRGB: attr("color-interpolation", "") == "linearRGB",
+		transform: gg.Identity(),
+	}
+	if t := attr("gradientTransform", ""); t != "" {
+		m, err := parseTransform(t)
+		if err != nil {
+			return nil, err
+		}
+		g.transform = m
+	}
+	// percentages are of the bounding box, whose sides are 1, or of the
+	// viewport for user space
+	w, h := 1.0, 1.0
+	if g.userSpace {
+		w, h = doc.ViewBox.W, doc.ViewBox.H
+	}
+	var err error
+	length := func(name, def string, size float64) float64 {
+		if err != nil {
+			return 0
+		}
+		var f float64
+		f, err = parseLength(attr(name, def), size)
+		return f
+	}
+	if g.radial {
+		diag := math.Sqrt((w*w + h*h) / 2)
+		g.cx = length("cx", "50%", w)
+		g.cy = length("cy", "50%", h)
+		g.r = length("r", "50%", diag)
+		g.fx = length("fx", attr("cx", "50%"), w)
+		g.fy = length("fy", attr("cy", "50%"), h)
+		g.fr = length("fr", "0%", diag)
+	} else {
+		g.x1 = length("x1", "0%", w)
+		g.y1 = length("y1", "0%", h)
+		g.x2 = length("x2", "100%", w)
+		g.y2 = length("y2", "0%", h)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if g.radial {
+		// a focal point outside the end circle is moved onto it
+		dx, dy := g.fx-g.cx, g.fy-g.cy
+		if d := math.Hypot(dx, dy); d > g.r*0.999 && d > 0 {
+			k := g.r * 0.999 / d
+			g.fx, g.fy = g.cx+dx*k, g.cy+dy*k
+		}
+	}
+
+	for _, e := range chain {
+		if g.stops, err = gradientStops(e); err != nil {
+			return nil, err
+		}
+		if len(g.stops) > 0 {
+			break
+		}
+	}
+	return g, nil
+}
+
+// gradientStops reads the <stop> children of the gradient element e.
+// Offsets are clamped to increase, and the alpha of each stop-color is
+// multiplied by the stop's stop-opacity.
+func gradientStops(e *Element) ([]gradientStop, error) {
+	var stops []gradientStop
+	for _, c := range e.Children {
+		if c.Name != "stop" {
+			continue
+		}
+		offset := 0.0
+		if v := strings.TrimSpace(c.Attr("offset")); v != "" {
+			var err error
+			if strings.HasSuffix(v, "%") {
+				offset, err = parseLength(v, 1)
+			} else {
+				offset, err = parseFloat(v, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("offset=%q: %w", v, err)
+			}
+		}
+		offset = clamp(offset, 0, 1)
+		if n := len(stops); n > 0 && offset < stops[n-1].offset {
+			offset = stops[n-1].offset
+		}
+		props := properties(c)
+		var col color.Color = color.Black
+		if v, ok := props["stop-color"]; ok {
+			var err error
+			if col, err = parseColor(v); err != nil {
+				return nil, fmt.Errorf("stop-color=%q: %w", v, err)
+			}
+		}
+		opacity := 1.0
+		if v, ok := props["stop-opacity"]; ok {
+			var err error
+			if opacity, err = parseOpacity(v); err != nil {
+				return nil, fmt.Errorf("stop-opacity=%q: %w", v, err)
+			}
+		}
+		n := color.NRGBA{}
+		if col != nil {
+			n = withAlpha(col, opacity).(color.NRGBA)
+		}
+		stops = append(stops, gradientStop{offset: offset, color: n})
+	}
+	return stops, nil
+}
+
+// gradientTableSize is the number of colors precomputed along a gradient.
+const gradientTableSize = 1024
+
+// gradientPattern is a gg.Pattern that paints a gradient on the device
+// pixels of a context.
+type gradientPattern struct {
+	g     *gradient
+	inv   gg.Matrix // from device pixels to gradient space
+	table [gradientTableSize]color.RGBA
+}
+
+// pattern returns a pattern painting the gradient through the matrix m
+// from user space to device pixels, for a shape with the bounding box x0,
+// y0, x1, y1, with its colors' alpha multiplied by alpha. linear forces
+// interpolation in linear light. It returns false if the gradient paints
+// nothing, as when the bounding box it relies on is empty.
+func (g *gradient) pattern(m gg.Matrix, x0, y0, x1, y1, alpha float64, linear bool) (gg.Pattern, bool) {
+	if len(g.stops) == 0 {
+		return nil, false
+	}
+	t := g.transform
+	if !g.userSpace {
+		if x1 <= x0 || y1 <= y0 {
+			return nil, false
+		}
+		t = t.Multiply(gg.Scale(x1-x0, y1-y0)).Multiply(gg.Translate(x0, y0))
+	}
+	p := &gradientPattern{g: g, inv: invertMatrix(t.Multiply(m))}
+	linear = linear || g.linearRGB
+	for i := range p.table {
+		c := g.colorAt(float64(i)/(gradientTableSize-1), linear)
+		a := float64(c.A) / 255 * alpha
+		p.table[i] = color.RGBA{
+			R: uint8(float64(c.R)*a + 0.5),
+			G: uint8(float64(c.G)*a + 0.5),
+			B: uint8(float64(c.B)*a + 0.5),
+			A: uint8(255*a + 0.5),
+		}
+	}
+	return p, true
+}
+
+// colorAt interpolates the stops at offset t, without premultiplying, as
+// browsers do for SVG gradients.
+func (g *gradient) colorAt(t float64, linear bool) color.NRGBA {
+	stops := g.stops
+	if t <= stops[0].offset {
+		return stops[0].color
+	}
+	for i := 1; i < len(stops); i++ {
+		s0, s1 := stops[i-1], stops[i]
+		if t >= s1.offset {
+			continue
+		}
+		k := (t - s0.offset) / (s1.offset - s0.offset)
+		mix := func(a, b uint8) uint8 {
+			if linear {
+				l := srgbToLinear[a] + (srgbToLinear[b]-srgbToLinear[a])*k
+				return linearToSRGB[int(clamp(l, 0, 1)*4095+0.5)]
+			}
+			return uint8(float64(a) + (float64(b)-float64(a))*k + 0.5)
+		}
+		return color.NRGBA{
+			R: mix(s0.color.R, s1.color.R),
+			G: mix(s0.color.G, s1.color.G),
+			B: mix(s0.color.B, s1.color.B),
+			A: uint8(float64(s0.color.A) + (float64(s1.color.A)-float64(s0.color.A))*k + 0.5),
+		}
+	}
+	return stops[len(stops)-1].color
+}
+
+func (p *gradientPattern) ColorAt(x, y int) color.Color {
+	g := p.g
+	px, py := p.inv.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+	var t float64
+	if g.radial && g.r <= 0 {
+		return p.table[gradientTableSize-1]
+	} else if g.radial {
+		var ok bool
+		if t, ok = g.radialOffset(px, py); !ok {
+			return color.Transparent
+		}
+	} else {
+		dx, dy := g.x2-g.x1, g.y2-g.y1
+		l := dx*dx + dy*dy
+		if l == 0 {
+			return p.table[gradientTableSize-1]
+		}
+		t = ((px-g.x1)*dx + (py-g.y1)*dy) / l
+	}
+	switch g.spread {
+	case "repeat":
+		t -= math.Floor(t)
+	case "reflect":
+		t = math.Abs(t - 2*math.Floor(t/2+0.5))
+	}
+	t = clamp(t, 0, 1)
+	return p.table[int(t*(gradientTableSize-1)+0.5)]
+}
+
+// radialOffset returns the offset of the largest circle, interpolated
+// between the focal and end circles, that passes through x, y.
+func (g *gradient) radialOffset(x, y float64) (float64, bool) {
+	cdx, cdy, dr := g.cx-g.fx, g.cy-g.fy, g.r-g.fr
+	px, py := x-g.fx, y-g.fy
+	a := cdx*cdx + cdy*cdy - dr*dr
+	b := px*cdx + py*cdy + g.fr*dr
+	c := px*px + py*py - g.fr*g.fr
+	if a == 0 {
+		if b == 0 {
+			return 0, false
+		}
+		t := c / (2 * b)
+		return t, g.fr+t*dr >= 0
+	}
+	discr := b*b - a*c
+	if discr < 0 {
+		return 0, false
+	}
+	s := math.Sqrt(discr)
+	for _, t := range []float64{(b + s) / a, (b - s) / a} {
+		if g.fr+t*dr >= 0 {
+			return t, true
+		}
+	}
+	return 0, false
+}
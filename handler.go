@@ -0,0 +1,270 @@
+package svgg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fogleman/gg"
+)
+
+// DefaultMaxImageSize is the largest width or height in pixels that a
+// Handler whose MaxSize is zero draws.
+const DefaultMaxImageSize = 4096
+
+// Handler is an http.Handler that serves the SVG files of a file system
+// as rasterized images, for icon and badge services. The URL path names
+// the file, and the query parameters set the output:
+//
+//	w, h    width and height in pixels; if only one is given the other
+//	        follows the document's aspect ratio, and if neither is given
+//	        the document's own size is used
+//	format  png (the default) or jpeg
+//
+// Documents are decoded with the Handler's Limits and never fetch
+// external references. Wrap a Handler with NewCacheHandler to avoid
+// drawing the same image twice.
+type Handler struct {
+	FS fs.FS
+
+	// MaxSize bounds the width and height of served images. Zero means
+	// DefaultMaxImageSize.
+	MaxSize int
+
+	// Limits bounds the documents that are decoded.
+	Limits Limits
+
+	// Options configures how documents are drawn. It may be nil.
+	Options *RenderOptions
+}
+
+// NewHandler returns a Handler serving the SVG files in fsys with
+// DefaultLimits.
+func NewHandler(fsys fs.FS) *Handler {
+	return &Handler{FS: fsys, MaxSize: DefaultMaxImageSize, Limits: DefaultLimits}
+}
+
+func (h *Handler) maxSize() int {
+	if h.MaxSize > 0 {
+		return h.MaxSize
+	}
+	return DefaultMaxImageSize
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	switch path.Ext(name) {
+	case ".svg", ".svgz":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	var size [2]int
+	for i, k := range []string{"w", "h"} {
+		v := q.Get(k)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > h.maxSize() {
+			http.Error(w, fmt.Sprintf("invalid %s: must be from 1 to %d", k, h.maxSize()), http.StatusBadRequest)
+			return
+		}
+		size[i] = n
+	}
+	format := q.Get("format")
+	switch format {
+	case "", "png":
+		format = "png"
+	case "jpeg", "jpg":
+		format = "jpeg"
+	default:
+		http.Error(w, "invalid format: must be png or jpeg", http.StatusBadRequest)
+		return
+	}
+
+	f, err := h.FS.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, "cannot open file", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+	d := NewDecoder(f)
+	d.ExternalRefs = DenyRefs
+	d.Limits = h.Limits
+	doc, err := d.Decode()
+	if err != nil {
+		http.Error(w, "invalid svg", http.StatusUnprocessableEntity)
+		return
+	}
+	width, height, ok := imageSize(doc, size[0], size[1], h.maxSize())
+	if !ok {
+		http.Error(w, "image too large or empty", http.StatusUnprocessableEntity)
+		return
+	}
+	// the viewBox is fitted to the image as the document's
+	// preserveAspectRatio says
+	doc.Width, doc.Height = float64(width), float64(height)
+	var opts RenderOptions
+	if h.Options != nil {
+		opts = *h.Options
+	}
+	if opts.Background == nil && format == "jpeg" {
+		opts.Background = color.White
+	}
+	dc := gg.NewContext(width, height)
+	if err := doc.DrawWithOptions(dc, &opts); err != nil {
+		http.Error(w, "cannot draw svg", http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, dc.Image(), nil)
+	} else {
+		err = png.Encode(&buf, dc.Image())
+	}
+	if err != nil {
+		http.Error(w, "cannot encode image", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/"+format)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if r.Method == http.MethodGet {
+		w.Write(buf.Bytes())
+	}
+}
+
+// imageSize returns the size in pixels of an image of doc with the given
+// width and height, either of which may be zero to follow the document's
+// aspect ratio. It reports false if the size is empty or larger than max.
+func imageSize(doc *Document, w, h, max int) (int, int, bool) {
+	dw, dh := doc.Width, doc.Height
+	if dw <= 0 || dh <= 0 {
+		return 0, 0, false
+	}
+	fw, fh := float64(w), float64(h)
+	switch {
+	case w > 0 && h > 0:
+	case w > 0:
+		fh = math.Round(fw * dh / dw)
+	case h > 0:
+		fw = math.Round(fh * dw / dh)
+	default:
+		fw, fh = math.Ceil(dw-1e-9), math.Ceil(dh-1e-9)
+	}
+	if fw < 1 || fh < 1 || fw > float64(max) || fh > float64(max) {
+		return 0, 0, false
+	}
+	return int(fw), int(fh), true
+}
+
+// NewCacheHandler returns a handler that serves successful GET responses
+// of h from memory, keyed by the request URI, keeping at most maxBytes of
+// response bodies and evicting the least recently used. Responses carry
+// an ETag so that clients can revalidate them.
+func NewCacheHandler(h http.Handler, maxBytes int64) http.Handler {
+	return &cacheHandler{h: h, cache: newLRU(maxBytes)}
+}
+
+type cacheHandler struct {
+	h     http.Handler
+	mu    sync.Mutex
+	cache *lru
+}
+
+// cachedResponse is a response body with the headers it is served with.
+type cachedResponse struct {
+	header http.Header
+	body   []byte
+}
+
+func (c *cacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		c.h.ServeHTTP(w, r)
+		return
+	}
+	key := r.URL.RequestURI()
+	c.mu.Lock()
+	v, ok := c.cache.get(key)
+	c.mu.Unlock()
+	if !ok {
+		rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		get := r.Clone(r.Context())
+		get.Method = http.MethodGet
+		c.h.ServeHTTP(rec, get)
+		if rec.status != http.StatusOK {
+			rec.writeTo(w, r)
+			return
+		}
+		resp := &cachedResponse{header: rec.header, body: rec.body.Bytes()}
+		sum := fnv.New64a()
+		sum.Write(resp.body)
+		resp.header.Set("ETag", fmt.Sprintf(`"%x"`, sum.Sum64()))
+		c.mu.Lock()
+		c.cache.add(key, resp, int64(len(resp.body)))
+		c.mu.Unlock()
+		v = resp
+	}
+	resp := v.(*cachedResponse)
+	for k, vs := range resp.header {
+		w.Header()[k] = append([]string(nil), vs...)
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && match == resp.header.Get("ETag") {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodGet {
+		w.Write(resp.body)
+	}
+}
+
+// responseRecorder captures a response so that it can be cached.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	return rec.body.Write(p)
+}
+
+// writeTo passes the recorded response on to w.
+func (rec *responseRecorder) writeTo(w http.ResponseWriter, r *http.Request) {
+	for k, vs := range rec.header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(rec.status)
+	if r.Method == http.MethodGet {
+		w.Write(rec.body.Bytes())
+	}
+}
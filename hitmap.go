@@ -0,0 +1,153 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font/sfnt"
+)
+
+// HitMap maps the pixels of a drawn document to the elements drawn on
+// them, so that a UI can find the element under the cursor with a lookup
+// rather than a hit test per event.
+type HitMap struct {
+	Width, Height int
+
+	// Elements are the elements hit, in drawing order. As in HitTest,
+	// content drawn through <use> is represented by the <use> element.
+	Elements []*Element
+
+	// Index holds, row by row, one plus the index in Elements of the
+	// topmost element covering each pixel, or zero for none.
+	Index []uint32
+}
+
+// HitMap returns the hit map of the document drawn at width by height
+// pixels, onto which its width and height are stretched as by NewImage.
+// A pixel belongs to the topmost element covering at least half of it,
+// with fills and strokes hit as by HitTest.
+//
+// Elements whose geometry cannot be read are left out, unless the
+// document's ErrorMode is StrictErrorMode.
+func (doc *Document) HitMap(width, height int) (*HitMap, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("svgg: invalid hit map size %dx%d", width, height)
+	}
+	hm := &HitMap{Width: width, Height: height, Index: make([]uint32, width*height)}
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return hm, nil
+	}
+	vb := viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, doc.Root.Attr("preserveAspectRatio"))
+	vb = vb.Multiply(gg.Scale(float64(width)/doc.Width, float64(height)/doc.Height))
+
+	// each element is drawn alone onto dc, and its pixels cleared again
+	// once they are read
+	dc := gg.NewContext(width, height)
+	dc.SetRGB(1, 1, 1)
+	pix := dc.Image().(*image.RGBA)
+	indices := make(map[*Element]uint32)
+	c := &CompiledPath{}
+	t := &transformSink{sink: c}
+	p := NewSinkParser(t)
+	var buf sfnt.Buffer
+	err := doc.walkGeometry(doc.ErrorMode == StrictErrorMode, func(e *Element, m gg.Matrix, s style, use *Element) error {
+		c.Segments = c.Segments[:0]
+		t.m = m.Multiply(vb)
+		fill := s.fill != nil || s.fillServer != ""
+		stroke := (s.stroke != nil || s.strokeServer != "") && s.strokeWidth > 0
+		switch e.Name {
+		case "image":
+			_, hasW := e.LookupAttr("width")
+			_, hasH := e.LookupAttr("height")
+			f, err := floatAttrs(e, "x", "y", "width", "height")
+			if err != nil || !hasW || !hasH {
+				return err
+			}
+			t.MoveTo(f[0], f[1])
+			t.LineTo(f[0]+f[2], f[1])
+			t.LineTo(f[0]+f[2], f[1]+f[3])
+			t.LineTo(f[0], f[1]+f[3])
+			t.ClosePath()
+			fill, stroke = true, false
+		case "text":
+			l, err := doc.layoutText(e, s)
+			if err != nil {
+				return err
+			}
+			if err := l.outline(&buf, t, 0, len(l.glyphs)); err != nil {
+				return err
+			}
+		default:
+			if err := buildShape(p, e); err != nil {
+				return err
+			}
+		}
+		if (!fill && !stroke) || len(c.Segments) == 0 {
+			return nil
+		}
+
+		b := &boundsSink{}
+		c.Draw(b)
+		sw := 0.0
+		if stroke {
+			sw = s.strokeWidth * matrixScale(t.m)
+		}
+		r := image.Rect(
+			int(math.Floor(b.x0-sw)), int(math.Floor(b.y0-sw)),
+			int(math.Ceil(b.x1+sw)), int(math.Ceil(b.y1+sw)),
+		).Intersect(pix.Rect)
+		if r.Empty() {
+			return nil
+		}
+		c.Draw(dc)
+		if fill {
+			dc.SetFillRule(s.fillRule)
+			dc.FillPreserve()
+		}
+		if stroke {
+			dc.SetLineWidth(sw)
+			dc.SetLineCap(s.lineCap)
+			dc.SetLineJoin(s.lineJoin)
+			dc.StrokePreserve()
+		}
+		dc.ClearPath()
+
+		hit := e
+		if use != nil {
+			hit = use
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			row := pix.Pix[pix.PixOffset(r.Min.X, y):pix.PixOffset(r.Max.X, y)]
+			for x := 0; x < len(row); x += 4 {
+				if row[x+3] >= 128 {
+					i, ok := indices[hit]
+					if !ok {
+						hm.Elements = append(hm.Elements, hit)
+						i = uint32(len(hm.Elements))
+						indices[hit] = i
+					}
+					hm.Index[y*width+r.Min.X+x/4] = i
+				}
+			}
+			for x := range row {
+				row[x] = 0
+			}
+		}
+		return nil
+	})
+	return hm, err
+}
+
+// At returns the topmost element at the pixel x, y, or nil if there is
+// none.
+func (hm *HitMap) At(x, y int) *Element {
+	if x < 0 || y < 0 || x >= hm.Width || y >= hm.Height {
+		return nil
+	}
+	if i := hm.Index[y*hm.Width+x]; i > 0 {
+		return hm.Elements[i-1]
+	}
+	return nil
+}
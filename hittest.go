@@ -0,0 +1,105 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Contains reports whether the point x, y is inside the path filled with
+// the nonzero rule. Open subpaths are closed, as they are when filling.
+func (c *CompiledPath) Contains(x, y float64) bool {
+	return c.WindingNumber(x, y) != 0
+}
+
+// DistanceTo returns the distance from the point x, y to the nearest point
+// on the outline of the path, for hit testing strokes. It is infinite for
+// an empty path.
+func (c *CompiledPath) DistanceTo(x, y float64) float64 {
+	p := gg.Point{X: x, Y: y}
+	d := math.Inf(1)
+	for _, sub := range c.subpaths(c.hitTolerance()) {
+		pts := sub.pts
+		if sub.closed {
+			pts = append(pts, pts[0])
+		}
+		if len(pts) == 1 {
+			d = math.Min(d, p.Distance(pts[0]))
+		}
+		for i := 1; i < len(pts); i++ {
+			d = math.Min(d, segmentDistance(p, pts[i-1], pts[i]))
+		}
+	}
+	return d
+}
+
+// hitTolerance returns a flattening tolerance small relative to the size
+// of the path, so that hit tests are accurate whatever its units.
+func (c *CompiledPath) hitTolerance() float64 {
+	b := &boundsSink{}
+	c.Draw(b)
+	if size := math.Max(b.x1-b.x0, b.y1-b.y0); size > 0 {
+		return size * 1e-4
+	}
+	return DefaultTolerance
+}
+
+// HitTest returns the topmost element drawn at the point x, y, or nil if
+// there is none, so that clicks on a drawing can be mapped back to its
+// elements. x, y are in the coordinate system Draw draws into, so they are
+// pixel coordinates when the document is drawn to a context with an
+// identity matrix. A hit on content drawn through <use> returns the <use>
+// element, as the element it references may be drawn in many places.
+//
+// Fills hit according to their fill rule and strokes within half their
+// width, ignoring dashes. Images hit within their rectangle if both their
+// width and height are given.
+func (doc *Document) HitTest(x, y float64) *Element {
+	vb := viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, doc.Root.Attr("preserveAspectRatio"))
+	c := &CompiledPath{}
+	t := &transformSink{sink: c}
+	p := NewSinkParser(t)
+	var hit *Element
+	doc.walkGeometry(false, func(e *Element, m gg.Matrix, s style, use *Element) error {
+		c.Segments = c.Segments[:0]
+		t.m = m.Multiply(vb)
+		fill := s.fill != nil || s.fillServer != ""
+		stroke := (s.stroke != nil || s.strokeServer != "") && s.strokeWidth > 0
+		if e.Name == "image" {
+			_, hasW := e.LookupAttr("width")
+			_, hasH := e.LookupAttr("height")
+			f, err := floatAttrs(e, "x", "y", "width", "height")
+			if err != nil || !hasW || !hasH {
+				return err
+			}
+			t.MoveTo(f[0], f[1])
+			t.LineTo(f[0]+f[2], f[1])
+			t.LineTo(f[0]+f[2], f[1]+f[3])
+			t.LineTo(f[0], f[1]+f[3])
+			t.ClosePath()
+			fill, stroke = true, false
+		} else if err := buildShape(p, e); err != nil {
+			return err
+		}
+		in := false
+		if fill {
+			w := c.WindingNumber(x, y)
+			if s.fillRule == gg.FillRuleEvenOdd {
+				in = w%2 != 0
+			} else {
+				in = w != 0
+			}
+		}
+		if !in && stroke {
+			in = c.DistanceTo(x, y) <= s.strokeWidth*matrixScale(t.m)/2
+		}
+		if in {
+			hit = e
+			if use != nil {
+				hit = use
+			}
+		}
+		return nil
+	})
+	return hit
+}
@@ -0,0 +1,94 @@
+package svgg
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// Icon is a drop-in replacement for oksvg's SvgIcon, so that code written
+// against oksvg can switch to drawing with gg by changing its imports and
+// passing a gg.Context to Draw in place of a rasterx scanner.
+type Icon struct {
+	ViewBox      ViewBox
+	Titles       []string // text of the <title> elements
+	Descriptions []string // text of the <desc> elements
+
+	// Transform maps the coordinates of the viewBox onto the context. It
+	// is the identity until SetTarget is called.
+	Transform gg.Matrix
+
+	// Doc is the parsed document the icon draws.
+	Doc *Document
+}
+
+// ReadIcon reads the icon in the named SVG file. The optional error mode
+// sets how elements and path data that cannot be drawn are handled; the
+// default is IgnoreErrorMode.
+func ReadIcon(iconFile string, errMode ...ErrorMode) (*Icon, error) {
+	f, err := os.Open(iconFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadIconStream(f, errMode...)
+}
+
+// ReadIconStream reads an SVG icon from stream, with the optional error
+// mode of ReadIcon.
+func ReadIconStream(stream io.Reader, errMode ...ErrorMode) (*Icon, error) {
+	doc, err := ReadDocument(stream)
+	if err != nil {
+		return nil, err
+	}
+	if len(errMode) > 0 {
+		doc.ErrorMode = errMode[0]
+	}
+	ic := &Icon{ViewBox: doc.ViewBox, Transform: gg.Identity(), Doc: doc}
+	var walk func(e *Element)
+	walk = func(e *Element) {
+		switch e.Name {
+		case "title":
+			ic.Titles = append(ic.Titles, strings.TrimSpace(e.Text))
+		case "desc":
+			ic.Descriptions = append(ic.Descriptions, strings.TrimSpace(e.Text))
+		}
+		for _, c := range e.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+	return ic, nil
+}
+
+// SetTarget sets the Transform so that the viewBox is stretched over the
+// rectangle x, y, w, h, as oksvg does, ignoring preserveAspectRatio.
+func (ic *Icon) SetTarget(x, y, w, h float64) {
+	vb := ic.ViewBox
+	sx, sy := w/vb.W, h/vb.H
+	ic.Transform = gg.Matrix{XX: sx, YY: sy, X0: x - vb.X*sx, Y0: y - vb.Y*sy}
+}
+
+// Draw draws the icon to dc through its Transform, in dc's current
+// coordinate system, with its alpha multiplied by opacity. A translucent
+// icon is drawn on a layer the size of dc, which ignores dc's clip.
+func (ic *Icon) Draw(dc *gg.Context, opacity float64) error {
+	doc := ic.Doc
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return nil
+	}
+	return drawWithAlpha(dc, opacity, ic.draw)
+}
+
+func (ic *Icon) draw(dc *gg.Context) error {
+	doc := ic.Doc
+	// the document maps its viewBox onto its own size, which is undone so
+	// that the Transform applies to viewBox coordinates
+	vb := viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, doc.Root.Attr("preserveAspectRatio"))
+	dc.Push()
+	defer dc.Pop()
+	applyMatrix(dc, invertMatrix(vb).Multiply(ic.Transform))
+	return doc.Draw(dc)
+}
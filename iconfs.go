@@ -0,0 +1,124 @@
+package svgg
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LoadIconSet decodes every .svg and .svgz file in the directory dir of
+// fsys, returning the documents by file name without its extension. With
+// go:embed, an application can bundle a directory of icons and load it in
+// one call:
+//
+//	//go:embed icons
+//	var iconFS embed.FS
+//
+//	icons, err := svgg.LoadIconSet(iconFS, "icons")
+//
+// Subdirectories are not read. Use OpenIconSet to decode the documents
+// only when they are first used.
+func LoadIconSet(fsys fs.FS, dir string) (map[string]*Document, error) {
+	set, err := OpenIconSet(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]*Document, len(set.files))
+	for _, name := range set.Names() {
+		doc, err := set.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		docs[name] = doc
+	}
+	return docs, nil
+}
+
+// An IconSet holds the SVG files of a directory by name, decoding each
+// one when it is first asked for, so that applications with large icon
+// sets only pay for the icons they draw. It is safe for concurrent use.
+type IconSet struct {
+	fsys  fs.FS
+	files map[string]string // file paths by name
+
+	mu   sync.Mutex
+	docs map[string]*Document
+}
+
+// OpenIconSet lists the .svg and .svgz files in the directory dir of fsys
+// without decoding them, naming each by its file name without its
+// extension. Two files with the same name, such as a.svg and a.svgz, are
+// an error.
+func OpenIconSet(fsys fs.FS, dir string) (*IconSet, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	set := &IconSet{fsys: fsys, files: make(map[string]string), docs: make(map[string]*Document)}
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || (ext != ".svg" && ext != ".svgz") {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ext)
+		file := path.Join(dir, e.Name())
+		if prev, ok := set.files[name]; ok {
+			return nil, fmt.Errorf("svgg: %s and %s have the same icon name %q", prev, file, name)
+		}
+		set.files[name] = file
+	}
+	return set, nil
+}
+
+// Names returns the names of the icons in the set, sorted.
+func (s *IconSet) Names() []string {
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether the set has an icon with the name.
+func (s *IconSet) Has(name string) bool {
+	_, ok := s.files[name]
+	return ok
+}
+
+// Get returns the document of the named icon, decoding it the first time
+// it is asked for. Later calls return the same document, which is shared
+// and must not be modified. A name not in the set wraps fs.ErrNotExist.
+func (s *IconSet) Get(name string) (*Document, error) {
+	file, ok := s.files[name]
+	if !ok {
+		return nil, fmt.Errorf("svgg: icon %q: %w", name, fs.ErrNotExist)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc, ok := s.docs[name]; ok {
+		return doc, nil
+	}
+	f, err := s.fsys.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := ReadDocument(f)
+	if err != nil {
+		return nil, fmt.Errorf("svgg: %s: %w", file, err)
+	}
+	s.docs[name] = doc
+	return doc, nil
+}
+
+// forget drops the decoded document of the named icon, so that Get
+// decodes it again.
+func (s *IconSet) forget(name string) {
+	s.mu.Lock()
+	delete(s.docs, name)
+	s.mu.Unlock()
+}
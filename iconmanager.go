@@ -0,0 +1,142 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"sync"
+)
+
+// DefaultIconCacheSize is the number of bytes of images an IconManager
+// whose bound is zero keeps.
+const DefaultIconCacheSize = 16 << 20
+
+// IconManager draws the icons of an IconSet by name and keeps the images
+// of every size drawn, for GUI toolkits that draw the same icons over and
+// over. The least recently used images are dropped when they take more
+// than the manager's bound. It is safe for concurrent use; callers asking
+// for an icon that is being drawn wait for it rather than drawing it
+// twice.
+type IconManager struct {
+	Set *IconSet
+
+	// Options configures how the icons are drawn. It may be nil. Changing
+	// it does not affect the images already kept; call Purge.
+	Options *RenderOptions
+
+	mu    sync.Mutex
+	cache *lru
+	sizes map[string]map[int]bool // the sizes of each icon in the cache
+}
+
+// iconKey identifies an image kept by an IconManager.
+type iconKey struct {
+	name string
+	size int
+}
+
+// iconImage is an icon that is drawn once, by whichever caller asks for
+// it first.
+type iconImage struct {
+	once sync.Once
+	im   *image.RGBA
+	err  error
+}
+
+// NewIconManager returns an IconManager of set keeping at most maxBytes
+// of images, or DefaultIconCacheSize if maxBytes is zero, which is also
+// the bound of an IconManager that is not made by NewIconManager.
+func NewIconManager(set *IconSet, maxBytes int64) *IconManager {
+	if maxBytes <= 0 {
+		maxBytes = DefaultIconCacheSize
+	}
+	m := &IconManager{Set: set}
+	m.reset(maxBytes)
+	return m
+}
+
+// reset empties the cache, bounding it to maxBytes. m.mu must be held
+// unless m is new.
+func (m *IconManager) reset(maxBytes int64) {
+	m.cache = newLRU(maxBytes)
+	m.cache.evicted = func(key, _ interface{}) { m.dropSize(key.(iconKey)) }
+	m.sizes = make(map[string]map[int]bool)
+}
+
+// dropSize forgets that the image of key is in the cache. m.mu must be
+// held.
+func (m *IconManager) dropSize(key iconKey) {
+	delete(m.sizes[key.name], key.size)
+	if len(m.sizes[key.name]) == 0 {
+		delete(m.sizes, key.name)
+	}
+}
+
+// Render returns the named icon drawn centered in a square of size
+// pixels, keeping its aspect ratio. Unless the manager's Options set
+// Supersample, small icons are supersampled as by ExportIconSet. The
+// returned image is shared and must not be modified. Images that fail to
+// draw are not kept.
+func (m *IconManager) Render(name string, size int) (*image.RGBA, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("svgg: invalid icon size %d", size)
+	}
+	doc, err := m.Set.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	key := iconKey{name, size}
+	m.mu.Lock()
+	if m.cache == nil {
+		m.reset(DefaultIconCacheSize)
+	}
+	v, ok := m.cache.get(key)
+	if !ok {
+		v = &iconImage{}
+		if m.cache.add(key, v, int64(4*size*size)) {
+			if m.sizes[name] == nil {
+				m.sizes[name] = make(map[int]bool)
+			}
+			m.sizes[name][size] = true
+		}
+	}
+	m.mu.Unlock()
+
+	ic := v.(*iconImage)
+	ic.once.Do(func() {
+		ic.im, ic.err = drawIcon(doc, size, m.Options)
+	})
+	if ic.err != nil {
+		m.mu.Lock()
+		if cur, ok := m.cache.get(key); ok && cur == v {
+			m.cache.remove(key)
+			m.dropSize(key)
+		}
+		m.mu.Unlock()
+	}
+	return ic.im, ic.err
+}
+
+// Invalidate drops the images of the named icon at every size, and its
+// document from the set, so that the next Render reads the icon's file
+// again, such as after it is edited.
+func (m *IconManager) Invalidate(name string) {
+	m.mu.Lock()
+	if m.cache != nil {
+		for size := range m.sizes[name] {
+			m.cache.remove(iconKey{name, size})
+		}
+		delete(m.sizes, name)
+	}
+	m.mu.Unlock()
+	m.Set.forget(name)
+}
+
+// Purge drops every image the manager keeps. The documents of the set
+// are kept.
+func (m *IconManager) Purge() {
+	m.mu.Lock()
+	if m.cache != nil {
+		m.reset(m.cache.max)
+	}
+	m.mu.Unlock()
+}
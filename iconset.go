@@ -0,0 +1,125 @@
+package svgg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// ExportIconSet draws doc as square icons of each of the sizes in pixels,
+// such as 16, 32, 48 and 180 for a favicon and app icons. The document is
+// centered in each square, keeping its aspect ratio, and smaller sizes are
+// supersampled more so that their edges stay crisp. The images can be
+// written to an .ico file with EncodeICO.
+func ExportIconSet(doc *Document, sizes []int) ([]*image.RGBA, error) {
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return nil, fmt.Errorf("svgg: empty document size %gx%g", doc.Width, doc.Height)
+	}
+	ims := make([]*image.RGBA, len(sizes))
+	for i, n := range sizes {
+		if n <= 0 {
+			return nil, fmt.Errorf("svgg: invalid icon size %d", n)
+		}
+		im, err := drawIcon(doc, n, nil)
+		if err != nil {
+			return nil, err
+		}
+		ims[i] = im
+	}
+	return ims, nil
+}
+
+// drawIcon draws doc centered in a square of n pixels, keeping its aspect
+// ratio, with opts, which may be nil. Unless opts sets it, the icon is
+// supersampled by iconSupersample.
+func drawIcon(doc *Document, n int, opts *RenderOptions) (*image.RGBA, error) {
+	var o RenderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Supersample == 0 {
+		o.Supersample = iconSupersample(n)
+	}
+	dc := gg.NewContext(n, n)
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return dc.Image().(*image.RGBA), nil
+	}
+	k := math.Min(float64(n)/doc.Width, float64(n)/doc.Height)
+	dc.Translate((float64(n)-doc.Width*k)/2, (float64(n)-doc.Height*k)/2)
+	dc.Scale(k, k)
+	if err := doc.DrawWithOptions(dc, &o); err != nil {
+		return nil, fmt.Errorf("svgg: drawing %dx%d icon: %w", n, n, err)
+	}
+	return dc.Image().(*image.RGBA), nil
+}
+
+// iconSupersample returns the supersampling factor for an icon of n
+// pixels. Antialiasing errors matter most on the smallest icons, which are
+// also the cheapest to supersample.
+func iconSupersample(n int) int {
+	switch {
+	case n <= 32:
+		return 4
+	case n <= 128:
+		return 2
+	}
+	return 1
+}
+
+// EncodeICO writes the images to w as a Windows icon (.ico) file, as used
+// for favicons. Each image is stored as a PNG, which every browser and
+// Windows since Vista reads, and must be at most 256 pixels on a side.
+func EncodeICO(w io.Writer, images []image.Image) error {
+	if len(images) == 0 || len(images) > math.MaxUint16 {
+		return fmt.Errorf("svgg: an icon needs from 1 to %d images", math.MaxUint16)
+	}
+	data := make([][]byte, len(images))
+	for i, im := range images {
+		b := im.Bounds()
+		if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > 256 || b.Dy() > 256 {
+			return fmt.Errorf("svgg: icon image of %dx%d pixels: must be from 1 to 256 on a side", b.Dx(), b.Dy())
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, im); err != nil {
+			return err
+		}
+		data[i] = buf.Bytes()
+	}
+
+	// the header and directory are followed by the images
+	type header struct {
+		Reserved, Type, Count uint16
+	}
+	type entry struct {
+		Width, Height, Colors, Reserved uint8
+		Planes, BitCount                uint16
+		Size, Offset                    uint32
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, header{Type: 1, Count: uint16(len(images))})
+	offset := 6 + 16*len(images)
+	for i, im := range images {
+		b := im.Bounds()
+		// 256 pixels is stored as 0
+		binary.Write(&buf, binary.LittleEndian, entry{
+			Width:    uint8(b.Dx()),
+			Height:   uint8(b.Dy()),
+			Planes:   1,
+			BitCount: 32,
+			Size:     uint32(len(data[i])),
+			Offset:   uint32(offset),
+		})
+		offset += len(data[i])
+	}
+	for _, d := range data {
+		buf.Write(d)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,72 @@
+package svgg
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"github.com/fogleman/gg"
+)
+
+// Image is an image.Image showing a Document, which is drawn the first time
+// a pixel is read. Its Bounds are known without drawing, so it can be
+// handed to image pipelines such as draw.Draw or thumbnailers that may
+// never read it. It is safe for concurrent use.
+type Image struct {
+	doc  *Document
+	w, h int
+	opts *RenderOptions
+
+	once sync.Once
+	im   *image.RGBA
+	err  error
+}
+
+// NewImage returns an Image of doc with the given size in pixels, onto
+// which the document's width and height are stretched. A zero width or
+// height is taken from the document, rounded up. opts configures how the
+// document is drawn and may be nil.
+func NewImage(doc *Document, width, height int, opts *RenderOptions) *Image {
+	if width <= 0 {
+		width = int(math.Ceil(doc.Width - 1e-9))
+	}
+	if height <= 0 {
+		height = int(math.Ceil(doc.Height - 1e-9))
+	}
+	return &Image{doc: doc, w: width, h: height, opts: opts}
+}
+
+func (m *Image) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (m *Image) Bounds() image.Rectangle {
+	return image.Rect(0, 0, m.w, m.h)
+}
+
+func (m *Image) At(x, y int) color.Color {
+	return m.Image().At(x, y)
+}
+
+// Image returns the drawn image, drawing it if it has not been yet. An
+// error drawing it is returned by Err, and the image holds whatever was
+// drawn before the error.
+func (m *Image) Image() *image.RGBA {
+	m.once.Do(func() {
+		dc := gg.NewContext(m.w, m.h)
+		if m.doc.Width > 0 && m.doc.Height > 0 {
+			dc.Scale(float64(m.w)/m.doc.Width, float64(m.h)/m.doc.Height)
+			m.err = m.doc.DrawWithOptions(dc, m.opts)
+		}
+		m.im = dc.Image().(*image.RGBA)
+	})
+	return m.im
+}
+
+// Err returns the error from drawing the image, drawing it if it has not
+// been yet.
+func (m *Image) Err() error {
+	m.Image()
+	return m.err
+}
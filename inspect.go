@@ -0,0 +1,56 @@
+package svgg
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// ElementInfo describes an element of a document, for tools such as
+// layer outliners and element pickers. Its fields are copies, so changing
+// them does not change the document.
+type ElementInfo struct {
+	// Element is the element described, for looking it up again.
+	Element *Element
+
+	Tag     string
+	ID      string
+	Classes []string
+	Attrs   []xml.Attr // in document order, including id and class
+
+	// Depth is the number of ancestors of the element, and Parent the
+	// index of its parent in the list, or -1 for the root.
+	Depth  int
+	Parent int
+
+	// Drawn reports whether the renderer draws elements with this tag,
+	// rather than skipping or only referencing them.
+	Drawn bool
+}
+
+// Inspect walks the element tree and returns a description of every
+// element in document order, parents before their children.
+func (doc *Document) Inspect() []ElementInfo {
+	var infos []ElementInfo
+	var walk func(e *Element, depth, parent int)
+	walk = func(e *Element, depth, parent int) {
+		_, drawn := elementAttrs[e.Name]
+		infos = append(infos, ElementInfo{
+			Element: e,
+			Tag:     e.Name,
+			ID:      e.Attr("id"),
+			Classes: strings.Fields(e.Attr("class")),
+			Attrs:   append([]xml.Attr(nil), e.Attrs...),
+			Depth:   depth,
+			Parent:  parent,
+			Drawn:   drawn,
+		})
+		i := len(infos) - 1
+		for _, c := range e.Children {
+			walk(c, depth+1, i)
+		}
+	}
+	if doc.Root != nil {
+		walk(doc.Root, 0, -1)
+	}
+	return infos
+}
@@ -0,0 +1,209 @@
+package svgg
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// jsonDocument is the JSON form of a Document.
+type jsonDocument struct {
+	Width   float64      `json:"width"`
+	Height  float64      `json:"height"`
+	ViewBox [4]float64   `json:"viewBox"`
+	Root    *jsonElement `json:"root"`
+}
+
+// jsonElement is the JSON form of an element, with its computed style and
+// the transform from its user space to the document's viewport.
+type jsonElement struct {
+	Tag       string            `json:"tag"`
+	ID        string            `json:"id,omitempty"`
+	Attrs     map[string]string `json:"attrs,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Tail      string            `json:"tail,omitempty"`
+	Transform [6]float64        `json:"transform"`
+	Style     *jsonStyle        `json:"style,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Children  []*jsonElement    `json:"children,omitempty"`
+}
+
+// jsonStyle is the JSON form of the computed style of an element, by CSS
+// property name.
+type jsonStyle struct {
+	Fill             string    `json:"fill"`
+	FillOpacity      float64   `json:"fill-opacity"`
+	FillRule         string    `json:"fill-rule"`
+	Stroke           string    `json:"stroke"`
+	StrokeOpacity    float64   `json:"stroke-opacity"`
+	StrokeWidth      float64   `json:"stroke-width"`
+	StrokeLinecap    string    `json:"stroke-linecap"`
+	StrokeLinejoin   string    `json:"stroke-linejoin"`
+	StrokeDasharray  []float64 `json:"stroke-dasharray,omitempty"`
+	Opacity          float64   `json:"opacity"`
+	Display          string    `json:"display"`
+	MarkerStart      string    `json:"marker-start,omitempty"`
+	MarkerMid        string    `json:"marker-mid,omitempty"`
+	MarkerEnd        string    `json:"marker-end,omitempty"`
+	FontFamily       []string  `json:"font-family"`
+	FontSize         float64   `json:"font-size"`
+	FontWeight       int       `json:"font-weight"`
+	FontStyle        string    `json:"font-style"`
+	TextAnchor       string    `json:"text-anchor"`
+	DominantBaseline string    `json:"dominant-baseline"`
+	ShapeRendering   string    `json:"shape-rendering"`
+}
+
+// MarshalJSON implements json.Marshaler. It writes the document's size and
+// viewBox and its element tree, each element with its tag, attributes by
+// local name, text, computed style and transform, for debugging how a
+// document was understood and for web frontends that want its structure
+// without an SVG parser.
+//
+// The transform of an element is the matrix [a, b, c, d, e, f], as in SVG,
+// from its user space to the document's viewport, which spans width by
+// height units. The style is as the renderer resolves it, with paints as
+// "none", "#rrggbb", "#rrggbbaa" or a "url(#id)" followed by its fallback
+// color, and an opacity that includes those of the element's ancestors.
+// The root element has the initial style its children inherit, as its
+// own presentation attributes and transform are not drawn. An element
+// whose style or transform cannot be resolved has an error instead, and
+// its children are resolved as if it were not there.
+func (doc *Document) MarshalJSON() ([]byte, error) {
+	jd := jsonDocument{
+		Width:   doc.Width,
+		Height:  doc.Height,
+		ViewBox: [4]float64{doc.ViewBox.X, doc.ViewBox.Y, doc.ViewBox.W, doc.ViewBox.H},
+	}
+	if doc.Root != nil {
+		jd.Root = doc.jsonElement(doc.Root, gg.Identity(), doc.rootStyle())
+	}
+	return json.Marshal(jd)
+}
+
+// jsonElement returns the JSON form of e and its children, given the
+// transform m and style s of its parent.
+func (doc *Document) jsonElement(e *Element, m gg.Matrix, s style) *jsonElement {
+	je := &jsonElement{Tag: e.Name, ID: e.Attr("id")}
+	if len(e.Attrs) > 0 {
+		je.Attrs = make(map[string]string, len(e.Attrs))
+		for _, a := range e.Attrs {
+			je.Attrs[a.Name.Local] = a.Value
+		}
+	}
+	if strings.TrimSpace(e.Text) != "" {
+		je.Text = e.Text
+	}
+	if strings.TrimSpace(e.Tail) != "" {
+		je.Tail = e.Tail
+	}
+
+	var errs []string
+	switch {
+	case e == doc.Root:
+		// the root's children are drawn with the initial style, in the
+		// user space of the document's viewBox
+		je.Style = newJSONStyle(s)
+		je.Transform = [6]float64{m.XX, m.YX, m.XY, m.YY, m.X0, m.Y0}
+		vb := viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, e.Attr("preserveAspectRatio"))
+		m = vb.Multiply(m)
+	default:
+		if cs, err := s.resolve(e); err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			s = cs
+			je.Style = newJSONStyle(s)
+		}
+		if tr := e.Attr("transform"); tr != "" {
+			if local, err := parseTransform(tr); err != nil {
+				errs = append(errs, fmt.Sprintf("transform=%q: %v", tr, err))
+			} else {
+				m = local.Multiply(m)
+			}
+		}
+		je.Transform = [6]float64{m.XX, m.YX, m.XY, m.YY, m.X0, m.Y0}
+		// the children of a nested svg are in the user space of its viewBox
+		if e.Name == "svg" {
+			if _, vm, err := doc.viewport(e, nil); err != nil {
+				errs = append(errs, err.Error())
+			} else {
+				m = vm.Multiply(m)
+			}
+		}
+	}
+	je.Error = strings.Join(errs, "; ")
+
+	for _, c := range e.Children {
+		je.Children = append(je.Children, doc.jsonElement(c, m, s))
+	}
+	return je
+}
+
+// newJSONStyle returns the JSON form of the computed style s.
+func newJSONStyle(s style) *jsonStyle {
+	js := &jsonStyle{
+		Fill:             jsonPaint(s.fillServer, s.fill),
+		FillOpacity:      s.fillOpacity,
+		FillRule:         "nonzero",
+		Stroke:           jsonPaint(s.strokeServer, s.stroke),
+		StrokeOpacity:    s.strokeOpacity,
+		StrokeWidth:      s.strokeWidth,
+		StrokeLinecap:    "butt",
+		StrokeLinejoin:   "round",
+		StrokeDasharray:  s.dashes,
+		Opacity:          s.opacity,
+		Display:          "inline",
+		MarkerStart:      s.markerStart,
+		MarkerMid:        s.markerMid,
+		MarkerEnd:        s.markerEnd,
+		FontFamily:       s.fontFamily,
+		FontSize:         s.fontSize,
+		FontWeight:       s.fontWeight,
+		FontStyle:        "normal",
+		TextAnchor:       s.textAnchor,
+		DominantBaseline: s.baseline,
+		ShapeRendering:   s.rendering,
+	}
+	if s.fillRule == gg.FillRuleEvenOdd {
+		js.FillRule = "evenodd"
+	}
+	switch s.lineCap {
+	case gg.LineCapRound:
+		js.StrokeLinecap = "round"
+	case gg.LineCapSquare:
+		js.StrokeLinecap = "square"
+	}
+	if s.lineJoin == gg.LineJoinBevel {
+		js.StrokeLinejoin = "bevel"
+	}
+	if !s.display {
+		js.Display = "none"
+	}
+	if s.italic {
+		js.FontStyle = "italic"
+	}
+	return js
+}
+
+// jsonPaint formats a paint server reference with its fallback color c,
+// or c alone if there is no server, with c's alpha if it is translucent.
+func jsonPaint(server string, c color.Color) string {
+	col := "none"
+	if c != nil {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		col = fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+		if n.A != 255 {
+			col += fmt.Sprintf("%02x", n.A)
+		}
+	}
+	switch {
+	case server == "":
+		return col
+	case c == nil:
+		return "url(" + server + ")"
+	}
+	return "url(" + server + ") " + col
+}
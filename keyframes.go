@@ -0,0 +1,667 @@
+package svgg
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// easing maps progress through a keyframe step to the progress between
+// its values, as a CSS timing function does.
+type easing func(float64) float64
+
+// cssAnimation is an animation applied to an element by the CSS
+// animation properties.
+type cssAnimation struct {
+	keyframes  []keyframe
+	dur, delay float64 // seconds
+	count      float64 // +Inf for infinite
+	direction  string
+	fillMode   string
+	timing     easing
+}
+
+// cssAnimations are the CSS animations of an element, later ones taking
+// priority, and its transform-origin and transform-box.
+type cssAnimations struct {
+	list   []*cssAnimation
+	origin string
+	box    string
+}
+
+// animations matches the rules of the stylesheet against the document and
+// returns the CSS animations of every animated element.
+func (ss *stylesheet) animations(doc *Document) map[*Element]*cssAnimations {
+	anims := make(map[*Element]*cssAnimations)
+	if len(ss.keyframes) == 0 {
+		return anims
+	}
+	var walk func(path []cssNode)
+	walk = func(path []cssNode) {
+		e := path[len(path)-1].e
+		if a := ss.elementAnimations(ss.declarations(path)); a != nil {
+			anims[e] = a
+		}
+		for i, c := range e.Children {
+			walk(append(path, cssNode{e: c, index: i + 1, siblings: len(e.Children)}))
+		}
+	}
+	walk([]cssNode{{e: doc.Root, index: 1, siblings: 1}})
+	return anims
+}
+
+// elementAnimations reads the animation properties from decls, which are
+// in cascade order. It returns nil if they name no known @keyframes.
+func (ss *stylesheet) elementAnimations(decls [][2]string) *cssAnimations {
+	var names, durs, delays, counts, directions, fills, timings []string
+	a := &cssAnimations{}
+	for _, d := range decls {
+		v := splitList(d[1], ',')
+		switch d[0] {
+		case "animation":
+			names, durs, delays, counts, directions, fills, timings = nil, nil, nil, nil, nil, nil, nil
+			for _, part := range v {
+				name, dur, delay, count, direction, fill, timing := parseAnimationShorthand(part)
+				names = append(names, name)
+				durs = append(durs, dur)
+				delays = append(delays, delay)
+				counts = append(counts, count)
+				directions = append(directions, direction)
+				fills = append(fills, fill)
+				timings = append(timings, timing)
+			}
+		case "animation-name":
+			names = v
+		case "animation-duration":
+			durs = v
+		case "animation-delay":
+			delays = v
+		case "animation-iteration-count":
+			counts = v
+		case "animation-direction":
+			directions = v
+		case "animation-fill-mode":
+			fills = v
+		case "animation-timing-function":
+			timings = v
+		case "transform-origin":
+			a.origin = d[1]
+		case "transform-box":
+			a.box = d[1]
+		}
+	}
+	item := func(list []string, i int, def string) string {
+		if len(list) == 0 || list[i%len(list)] == "" {
+			return def
+		}
+		return list[i%len(list)]
+	}
+	for i, name := range names {
+		frames, ok := ss.keyframes[name]
+		if !ok || len(frames) == 0 {
+			continue
+		}
+		c := &cssAnimation{keyframes: frames}
+		c.dur, _ = parseCSSTime(item(durs, i, "0s"))
+		c.delay, _ = parseCSSTime(item(delays, i, "0s"))
+		if count := item(counts, i, "1"); count == "infinite" {
+			c.count = math.Inf(1)
+		} else if f, err := strconv.ParseFloat(count, 64); err == nil && f >= 0 {
+			c.count = f
+		} else {
+			c.count = 1
+		}
+		c.direction = item(directions, i, "normal")
+		c.fillMode = item(fills, i, "none")
+		var ok2 bool
+		if c.timing, ok2 = parseEasing(item(timings, i, "ease")); !ok2 {
+			c.timing, _ = parseEasing("ease")
+		}
+		a.list = append(a.list, c)
+	}
+	if len(a.list) == 0 {
+		return nil
+	}
+	return a
+}
+
+// parseAnimationShorthand sorts the values of one animation in the
+// animation shorthand into its longhands. Values left out are empty.
+func parseAnimationShorthand(s string) (name, dur, delay, count, direction, fill, timing string) {
+	for _, tok := range splitList(s, ' ') {
+		switch {
+		case tok == "":
+		case tok == "infinite" || isNumberStart(tok[0]) && scanNumber(tok, 0) == len(tok):
+			count = tok
+		case isNumberStart(tok[0]) && (strings.HasSuffix(tok, "s")):
+			if dur == "" {
+				dur = tok
+			} else {
+				delay = tok
+			}
+		case tok == "normal" || tok == "reverse" || tok == "alternate" || tok == "alternate-reverse":
+			direction = tok
+		case (tok == "none" && fill == "") || tok == "forwards" || tok == "backwards" || tok == "both":
+			fill = tok
+		case tok == "running" || tok == "paused":
+		default:
+			if _, ok := parseEasing(tok); ok {
+				timing = tok
+			} else {
+				name = tok
+			}
+		}
+	}
+	return
+}
+
+// splitList splits s at sep outside of parentheses, trimming the parts.
+func splitList(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || s[i] == sep && depth == 0 {
+			if p := strings.TrimSpace(s[start:i]); p != "" || sep != ' ' {
+				parts = append(parts, p)
+			}
+			start = i + 1
+			continue
+		}
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '\t', '\n', '\r':
+			if sep == ' ' && depth == 0 {
+				if p := strings.TrimSpace(s[start:i]); p != "" {
+					parts = append(parts, p)
+				}
+				start = i + 1
+			}
+		}
+	}
+	return parts
+}
+
+// parseCSSTime parses a CSS time such as "1.5s", "-200ms" or "0" into
+// seconds.
+func parseCSSTime(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	sign := 1.0
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	t, err := parseClock(s)
+	return sign * t, err
+}
+
+// parseEasing parses a CSS timing function.
+func parseEasing(s string) (easing, bool) {
+	s = strings.TrimSpace(s)
+	bezier := func(x1, y1, x2, y2 float64) easing {
+		return func(p float64) float64 { return spline([4]float64{x1, y1, x2, y2}, p) }
+	}
+	switch s {
+	case "linear":
+		return func(p float64) float64 { return p }, true
+	case "ease":
+		return bezier(0.25, 0.1, 0.25, 1), true
+	case "ease-in":
+		return bezier(0.42, 0, 1, 1), true
+	case "ease-out":
+		return bezier(0, 0, 0.58, 1), true
+	case "ease-in-out":
+		return bezier(0.42, 0, 0.58, 1), true
+	case "step-start":
+		return steps(1, "start"), true
+	case "step-end":
+		return steps(1, "end"), true
+	}
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return nil, false
+	}
+	args := splitList(s[open+1:len(s)-1], ',')
+	switch s[:open] {
+	case "cubic-bezier":
+		if len(args) != 4 {
+			return nil, false
+		}
+		var k [4]float64
+		for i, a := range args {
+			f, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return nil, false
+			}
+			k[i] = f
+		}
+		if k[0] < 0 || k[0] > 1 || k[2] < 0 || k[2] > 1 {
+			return nil, false
+		}
+		return bezier(k[0], k[1], k[2], k[3]), true
+	case "steps":
+		if len(args) < 1 || len(args) > 2 {
+			return nil, false
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return nil, false
+		}
+		pos := "end"
+		if len(args) == 2 {
+			pos = args[1]
+		}
+		if pos == "jump-none" && n < 2 {
+			return nil, false
+		}
+		return steps(n, pos), true
+	}
+	return nil, false
+}
+
+// steps returns the steps(n, pos) timing function.
+func steps(n int, pos string) easing {
+	jumps := float64(n)
+	switch pos {
+	case "jump-none":
+		jumps--
+	case "jump-both":
+		jumps++
+	}
+	start := pos == "start" || pos == "jump-start" || pos == "jump-both"
+	return func(p float64) float64 {
+		step := math.Floor(p * float64(n))
+		if start {
+			step++
+		}
+		if p <= 1 && step > jumps {
+			step = jumps
+		}
+		if p >= 0 && step < 0 {
+			step = 0
+		}
+		return step / jumps
+	}
+}
+
+// progress returns how far through its keyframes the animation is at time
+// t, or false if it has no effect then.
+func (a *cssAnimation) progress(t float64) (float64, bool) {
+	elapsed := t - a.delay
+	active := a.dur * a.count
+	if a.dur <= 0 || a.count == 0 {
+		active = 0
+	}
+	var iter, frac float64
+	switch {
+	case elapsed < 0:
+		if a.fillMode != "backwards" && a.fillMode != "both" {
+			return 0, false
+		}
+	case elapsed >= active:
+		if a.fillMode != "forwards" && a.fillMode != "both" {
+			return 0, false
+		}
+		iter = math.Floor(a.count)
+		frac = a.count - iter
+		if frac == 0 && iter > 0 {
+			iter, frac = iter-1, 1
+		}
+	default:
+		iter = math.Floor(elapsed / a.dur)
+		frac = elapsed/a.dur - iter
+	}
+	odd := math.Mod(iter, 2) == 1
+	switch a.direction {
+	case "reverse":
+		frac = 1 - frac
+	case "alternate":
+		if odd {
+			frac = 1 - frac
+		}
+	case "alternate-reverse":
+		if !odd {
+			frac = 1 - frac
+		}
+	}
+	return frac, true
+}
+
+// value returns the value of the property name at progress p through the
+// keyframes, given its base value for steps that leave it out.
+func (a *cssAnimation) value(name, base string, p float64) (string, bool) {
+	var frames []keyframe
+	for _, f := range a.keyframes {
+		if _, ok := f.props[name]; ok {
+			frames = append(frames, f)
+		}
+	}
+	if len(frames) == 0 {
+		return "", false
+	}
+	if frames[0].offset > 0 {
+		frames = append([]keyframe{{offset: 0}}, frames...)
+	}
+	if frames[len(frames)-1].offset < 1 {
+		frames = append(frames, keyframe{offset: 1})
+	}
+	get := func(f keyframe) string {
+		if v, ok := f.props[name]; ok {
+			return v
+		}
+		return base
+	}
+	k := 0
+	for k < len(frames)-2 && frames[k+1].offset <= p {
+		k++
+	}
+	from, to := frames[k], frames[k+1]
+	local := 1.0
+	if span := to.offset - from.offset; span > 0 {
+		local = (p - from.offset) / span
+	}
+	timing := from.timing
+	if timing == nil {
+		timing = a.timing
+	}
+	local = timing(local)
+	if name == "transform" {
+		return interpolateTransforms(get(from), get(to), local)
+	}
+	anim := &animation{attr: name}
+	return anim.parseValue(get(from)).interpolate(anim.parseValue(get(to)), local).String(), true
+}
+
+// apply sets the properties animated at time t on c, a copy of the
+// element, given the style of its parent. Keyframes that leave a property
+// out use the value c already has.
+func (ca *cssAnimations) apply(c *Element, parent style, t float64, vb ViewBox) {
+	for _, a := range ca.list {
+		p, ok := a.progress(t)
+		if !ok {
+			continue
+		}
+		// the properties are set in a fixed order, so that the style
+		// attribute of the copy is the same from run to run
+		seen := make(map[string]bool)
+		var names []string
+		for _, f := range a.keyframes {
+			for name := range f.props {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if name == "transform" {
+				v, ok := a.value(name, c.Attr("transform"), p)
+				if !ok {
+					continue
+				}
+				if ox, oy := ca.transformOrigin(c, vb); ox != 0 || oy != 0 {
+					v = fmt.Sprintf("translate(%s %s) %s translate(%s %s)", formatFloat(ox), formatFloat(oy), v, formatFloat(-ox), formatFloat(-oy))
+				}
+				c.SetAttr("transform", v)
+				continue
+			}
+			if !styleProperties[name] {
+				continue
+			}
+			base := (&animation{attr: name, property: true}).baseValue(c, parent)
+			if v, ok := a.value(name, base, p); ok {
+				c.SetStyle(name, v)
+			}
+		}
+	}
+}
+
+// transformOrigin resolves the transform-origin of e against its
+// transform-box: the viewBox by default, or the bounding box of the
+// element for fill-box.
+func (ca *cssAnimations) transformOrigin(e *Element, vb ViewBox) (x, y float64) {
+	x0, y0, w, h := 0.0, 0.0, vb.W, vb.H
+	if ca.box == "fill-box" {
+		b := &boundsSink{}
+		elementBounds(e, &transformSink{sink: b, m: gg.Identity()})
+		x0, y0, w, h = b.x0, b.y0, b.x1-b.x0, b.y1-b.y0
+	}
+	f := strings.Fields(ca.origin)
+	if len(f) == 1 {
+		switch f[0] {
+		case "top", "bottom":
+			f = []string{"center", f[0]}
+		default:
+			f = append(f, "center")
+		}
+	}
+	if len(f) >= 2 && (f[0] == "top" || f[0] == "bottom" || f[1] == "left" || f[1] == "right") {
+		f[0], f[1] = f[1], f[0]
+	}
+	pos := func(v string, size float64) float64 {
+		switch v {
+		case "left", "top":
+			return 0
+		case "center":
+			return size / 2
+		case "right", "bottom":
+			return size
+		}
+		if strings.HasSuffix(v, "%") {
+			p, _ := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+			return size * p / 100
+		}
+		l, _ := parseLength(v, 0)
+		return l
+	}
+	if len(f) >= 2 {
+		x, y = pos(f[0], w), pos(f[1], h)
+	}
+	return x0 + x, y0 + y
+}
+
+// elementBounds sends the outline of e, and of the elements inside it
+// with their transforms, to t.
+func elementBounds(e *Element, t *transformSink) {
+	switch e.Name {
+	case "g", "svg", "a":
+		m := t.m
+		for _, c := range e.Children {
+			t.m = m
+			if tr, err := parseTransform(c.Attr("transform")); err == nil {
+				t.m = tr.Multiply(m)
+			}
+			elementBounds(c, t)
+		}
+		t.m = m
+	default:
+		if _, ok := elementAttrs[e.Name]; ok {
+			buildShape(NewSinkParser(t), e)
+		}
+	}
+}
+
+// transformFn is a transform function of a transform list.
+type transformFn struct {
+	name string
+	args []float64
+}
+
+// parseTransformList parses a transform in CSS or SVG syntax into
+// translate, scale, rotate, skewX, skewY and matrix functions with all
+// their arguments, in user units and degrees, so that lists of the same
+// functions can be interpolated argument by argument.
+func parseTransformList(s string) ([]transformFn, error) {
+	s = strings.TrimSpace(s)
+	var list []transformFn
+	if s == "none" {
+		return nil, nil
+	}
+	for s != "" {
+		open := strings.IndexByte(s, '(')
+		close := strings.IndexByte(s, ')')
+		if open < 0 || close < open {
+			return nil, fmt.Errorf("svgg: invalid transform %q", s)
+		}
+		name := strings.TrimSpace(s[:open])
+		var args []float64
+		for _, a := range strings.FieldsFunc(s[open+1:close], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' }) {
+			f, err := parseCSSNumber(a)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, f)
+		}
+		s = strings.TrimLeft(s[close+1:], ", \t\r\n")
+		n := len(args)
+		arg := func(i int, def float64) float64 {
+			if i < n {
+				return args[i]
+			}
+			return def
+		}
+		switch {
+		case (name == "translate" && n <= 2 || name == "translate3d" && n == 3) && n >= 1:
+			list = append(list, transformFn{"translate", []float64{args[0], arg(1, 0)}})
+		case name == "translateX" && n == 1:
+			list = append(list, transformFn{"translate", []float64{args[0], 0}})
+		case name == "translateY" && n == 1:
+			list = append(list, transformFn{"translate", []float64{0, args[0]}})
+		case name == "scale" && (n == 1 || n == 2):
+			list = append(list, transformFn{"scale", []float64{args[0], arg(1, args[0])}})
+		case name == "scaleX" && n == 1:
+			list = append(list, transformFn{"scale", []float64{args[0], 1}})
+		case name == "scaleY" && n == 1:
+			list = append(list, transformFn{"scale", []float64{1, args[0]}})
+		case (name == "rotate" || name == "rotateZ") && n == 1:
+			list = append(list, transformFn{"rotate", args})
+		case name == "rotate" && n == 3:
+			list = append(list,
+				transformFn{"translate", []float64{args[1], args[2]}},
+				transformFn{"rotate", args[:1]},
+				transformFn{"translate", []float64{-args[1], -args[2]}})
+		case (name == "skewX" || name == "skewY") && n == 1:
+			list = append(list, transformFn{name, args})
+		case name == "skew" && (n == 1 || n == 2):
+			if arg(1, 0) == 0 {
+				list = append(list, transformFn{"skewX", args[:1]})
+			} else {
+				tx, ty := math.Tan(gg.Radians(args[0])), math.Tan(gg.Radians(args[1]))
+				list = append(list, transformFn{"matrix", []float64{1, ty, tx, 1, 0, 0}})
+			}
+		case name == "matrix" && n == 6:
+			list = append(list, transformFn{name, args})
+		default:
+			return nil, fmt.Errorf("%w: transform %s with %d arguments", ErrNotImplemented, name, n)
+		}
+	}
+	return list, nil
+}
+
+// parseCSSNumber parses a transform argument, converting angles to degrees
+// and dropping px units.
+func parseCSSNumber(s string) (float64, error) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{{"deg", 1}, {"grad", 0.9}, {"rad", 180 / math.Pi}, {"turn", 360}, {"px", 1}}
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			f, err := strconv.ParseFloat(strings.TrimSuffix(s, u.suffix), 64)
+			if err != nil {
+				return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+			}
+			return f * u.scale, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+	}
+	return f, nil
+}
+
+// transformListMatrix returns the matrix of the transform list.
+func transformListMatrix(list []transformFn) gg.Matrix {
+	m := gg.Identity()
+	for _, fn := range list {
+		t, _ := transformFunc(fn.name, fn.args)
+		m = t.Multiply(m)
+	}
+	return m
+}
+
+// formatTransformList writes the list in SVG syntax.
+func formatTransformList(list []transformFn) string {
+	parts := make([]string, len(list))
+	for i, fn := range list {
+		args := make([]string, len(fn.args))
+		for j, a := range fn.args {
+			args[j] = formatFloat(a)
+		}
+		parts[i] = fn.name + "(" + strings.Join(args, " ") + ")"
+	}
+	return strings.Join(parts, " ")
+}
+
+// interpolateTransforms interpolates between two transform lists, as CSS
+// does: function by function if they are made of the same functions, and
+// by their matrices otherwise. none stands for the identity version of the
+// other list.
+func interpolateTransforms(a, b string, t float64) (string, bool) {
+	la, err := parseTransformList(a)
+	if err != nil {
+		return "", false
+	}
+	lb, err := parseTransformList(b)
+	if err != nil {
+		return "", false
+	}
+	if len(la) == 0 {
+		la = identityTransforms(lb)
+	}
+	if len(lb) == 0 {
+		lb = identityTransforms(la)
+	}
+	same := len(la) == len(lb)
+	for i := 0; same && i < len(la); i++ {
+		same = la[i].name == lb[i].name
+	}
+	if !same {
+		ma, mb := transformListMatrix(la), transformListMatrix(lb)
+		la = []transformFn{{"matrix", []float64{ma.XX, ma.YX, ma.XY, ma.YY, ma.X0, ma.Y0}}}
+		lb = []transformFn{{"matrix", []float64{mb.XX, mb.YX, mb.XY, mb.YY, mb.X0, mb.Y0}}}
+	}
+	out := make([]transformFn, len(la))
+	for i := range la {
+		args := make([]float64, len(la[i].args))
+		for j := range args {
+			args[j] = la[i].args[j] + (lb[i].args[j]-la[i].args[j])*t
+		}
+		out[i] = transformFn{la[i].name, args}
+	}
+	return formatTransformList(out), true
+}
+
+// identityTransforms returns the list of the same functions as list that
+// leaves points where they are.
+func identityTransforms(list []transformFn) []transformFn {
+	out := make([]transformFn, len(list))
+	for i, fn := range list {
+		switch fn.name {
+		case "scale":
+			out[i] = transformFn{fn.name, []float64{1, 1}}
+		case "matrix":
+			out[i] = transformFn{fn.name, []float64{1, 0, 0, 1, 0, 0}}
+		default:
+			out[i] = transformFn{fn.name, make([]float64, len(fn.args))}
+		}
+	}
+	return out
+}
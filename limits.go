@@ -0,0 +1,111 @@
+package svgg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Limits bounds the resources an untrusted document may consume. Zero
+// fields are unlimited.
+type Limits struct {
+	MaxElements   int     // elements in the document
+	MaxDepth      int     // nesting depth of elements
+	MaxPathPoints int     // numbers in all path data and point lists
+	MaxUseDepth   int     // nesting depth of <use> references when drawing
+	MaxCanvas     float64 // width or height of the document
+
+	// MaxUseInstances bounds the elements drawn through <use> references
+	// in one draw, which can multiply at every level of nesting.
+	MaxUseInstances int
+
+	// MaxImagePixels bounds the width times height of each raster image
+	// drawn by an <image> element, which is checked before the image is
+	// decoded.
+	MaxImagePixels int
+}
+
+// DefaultLimits are reasonable limits for rasterizing documents uploaded
+// by untrusted users.
+var DefaultLimits = Limits{
+	MaxElements:   100000,
+	MaxDepth:      256,
+	MaxPathPoints: 4000000,
+	MaxUseDepth:   16,
+	MaxCanvas:     16384,
+
+	MaxUseInstances: 10000,
+	MaxImagePixels:  1 << 26,
+}
+
+// limitCounter tracks usage against Limits while decoding.
+type limitCounter struct {
+	Limits
+	elements int
+	points   int
+}
+
+// addElement counts e, whose nesting depth is depth, against the limits.
+func (c *limitCounter) addElement(e *Element, depth int) error {
+	c.elements++
+	if c.MaxElements > 0 && c.elements > c.MaxElements {
+		return fmt.Errorf("svgg: %w: more than %d elements", ErrLimitExceeded, c.MaxElements)
+	}
+	if c.MaxDepth > 0 && depth > c.MaxDepth {
+		return fmt.Errorf("svgg: %w: elements nested more than %d deep", ErrLimitExceeded, c.MaxDepth)
+	}
+	if c.MaxPathPoints > 0 {
+		c.points += countNumbers(e.Attr("d")) + countNumbers(e.Attr("points"))
+		if isPathAnimation(e) {
+			for _, name := range []string{"values", "from", "to", "by"} {
+				c.points += countNumbers(e.Attr(name))
+			}
+		}
+		if c.points > c.MaxPathPoints {
+			return fmt.Errorf("svgg: %w: more than %d path points", ErrLimitExceeded, c.MaxPathPoints)
+		}
+	}
+	return nil
+}
+
+// isPathAnimation reports whether e animates path data or a point list,
+// whose values are parsed as paths when drawn.
+func isPathAnimation(e *Element) bool {
+	name := e.Attr("attributeName")
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return e.Name != "animateTransform" && (name == "d" || name == "points")
+}
+
+// checkCanvas checks the document's dimensions against the limits.
+func (c *limitCounter) checkCanvas(doc *Document) error {
+	if c.MaxCanvas > 0 && (doc.Width > c.MaxCanvas || doc.Height > c.MaxCanvas) {
+		return fmt.Errorf("svgg: %w: canvas %vx%v larger than %v", ErrLimitExceeded, doc.Width, doc.Height, c.MaxCanvas)
+	}
+	return nil
+}
+
+// countNumbers returns the number of numbers in the path data or point
+// list s, splitting them as the path parser does: "1.5.5" is two numbers
+// and the packed arc flags of "a1 1 0 11 2 2" are one each.
+func countNumbers(s string) int {
+	n, k := 0, 0 // numbers in all and since the last command
+	var cmd byte
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case isArcFlag(cmd, k) && (c == '0' || c == '1'):
+			n, k = n+1, k+1
+			i++
+		case isNumberStart(c):
+			n, k = n+1, k+1
+			i = scanNumber(s, i)
+		case isLetter(c):
+			cmd, k = c, 0
+			i++
+		default:
+			i++
+		}
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package svgg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestCountNumbers(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want int
+	}{
+		{"M1 2L3 4", 4},
+		{"M0 0L.1.1.1.1", 6},
+		{"1.5.5", 2},
+		{"1e5-2E-3", 2},
+		{"M0 0a1 1 0 112 2", 9},
+		{"M0 0a1 1 0 11.5.5", 9},
+		{".1,.2 .3", 3},
+	} {
+		if got := countNumbers(tt.s); got != tt.want {
+			t.Errorf("countNumbers(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMaxPathPoints(t *testing.T) {
+	dotted := strings.Repeat(".1", 2000)
+	for _, body := range []string{
+		`<path d="M0 0L` + dotted + `"/>`,
+		`<polyline points="` + dotted + `"/>`,
+		`<path d="M0 0"><animate attributeName="d" dur="1s" values="M0 0L` + dotted + `"/></path>`,
+		`<path d="M0 0"><animate attributeName="d" dur="1s" from="M0 0" to="M0 0L` + dotted + `"/></path>`,
+	} {
+		d := NewDecoder(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg">` + body + `</svg>`))
+		d.Limits = Limits{MaxPathPoints: 1000}
+		_, err := d.Decode()
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%.50s...: got error %v, want ErrLimitExceeded", body, err)
+		} else if strings.Contains(err.Error(), "svgg: svgg:") {
+			t.Errorf("%.50s...: error %q repeats its prefix", body, err)
+		}
+	}
+}
+
+func TestMaxImagePixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatal(err)
+	}
+	src := `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10">` +
+		`<image width="10" height="10" href="data:image/png;base64,` + base64.StdEncoding.EncodeToString(buf.Bytes()) + `"/></svg>`
+	for _, tt := range []struct {
+		max int
+		ok  bool
+	}{
+		{0, true},
+		{10000, true},
+		{9999, false},
+	} {
+		doc, err := ParseDocument([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc.Limits.MaxImagePixels = tt.max
+		doc.ErrorMode = StrictErrorMode
+		dst := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		err = DrawDocument(dst, dst.Bounds(), doc, nil)
+		if tt.ok && err != nil || !tt.ok && !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("MaxImagePixels %d: got error %v", tt.max, err)
+		}
+	}
+}
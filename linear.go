@@ -0,0 +1,106 @@
+package svgg
+
+import (
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// linear reports whether shapes drawn with style s are composited in
+// linear RGB.
+func (r *renderer) linear(s style) bool {
+	return r.opts.LinearRGB || s.interpolation == "linearRGB"
+}
+
+// drawShapeLinear draws e like drawShape, but onto a transparent layer
+// that is then composited onto the context in linear light. gg blends in
+// sRGB, which darkens translucent overlaps and antialiased edges.
+func (r *renderer) drawShapeLinear(e *Element, s style) error {
+	dc := r.dc
+	if dc == r.layer {
+		// a marker of a shape being drawn on the layer, which is composited
+		// with it
+		return r.drawShape(e, s)
+	}
+	if r.layer == nil {
+		r.layer = gg.NewContext(dc.Width(), dc.Height())
+	}
+	layer := r.layer
+	layer.Push()
+	defer layer.Pop()
+	applyMatrix(layer, currentMatrix(dc))
+	setClip(layer, r.clip)
+	r.dc = layer
+	r.parser.setContext(layer)
+	err := r.drawShape(e, s)
+	r.dc = dc
+	r.parser.setContext(dc)
+	compositeLinear(dc.Image().(*image.RGBA), layer.Image().(*image.RGBA))
+	return err
+}
+
+// srgbToLinear maps 8-bit sRGB values to linear light.
+var srgbToLinear [256]float64
+
+// linearToSRGB maps linear light, quantized to 12 bits, to 8-bit sRGB.
+var linearToSRGB [4096]uint8
+
+func init() {
+	for i := range srgbToLinear {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			srgbToLinear[i] = c / 12.92
+		} else {
+			srgbToLinear[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	for i := range linearToSRGB {
+		c := float64(i) / float64(len(linearToSRGB)-1)
+		if c <= 0.0031308 {
+			c *= 12.92
+		} else {
+			c = 1.055*math.Pow(c, 1/2.4) - 0.055
+		}
+		linearToSRGB[i] = uint8(c*255 + 0.5)
+	}
+}
+
+// compositeLinear composites src over dst in linear light and clears src.
+// Both images hold premultiplied sRGB colors and have the same bounds.
+func compositeLinear(dst, src *image.RGBA) {
+	for i := 0; i+3 < len(src.Pix); i += 4 {
+		sa := src.Pix[i+3]
+		if sa == 0 {
+			continue
+		}
+		as := float64(sa) / 255
+		ad := float64(dst.Pix[i+3]) / 255
+		a := as + ad*(1-as)
+		for c := 0; c < 3; c++ {
+			// unpremultiply before converting to linear light
+			cs := srgbToLinear[unpremultiply(src.Pix[i+c], sa)]
+			cd := 0.0
+			if ad > 0 {
+				cd = srgbToLinear[unpremultiply(dst.Pix[i+c], dst.Pix[i+3])]
+			}
+			v := (cs*as + cd*ad*(1-as)) / a
+			dst.Pix[i+c] = uint8(float64(linearToSRGB[int(v*float64(len(linearToSRGB)-1)+0.5)])*a + 0.5)
+		}
+		dst.Pix[i+3] = uint8(a*255 + 0.5)
+		src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = 0, 0, 0, 0
+	}
+}
+
+// unpremultiply returns the straight 8-bit value of the premultiplied
+// channel c with alpha a.
+func unpremultiply(c, a uint8) uint8 {
+	if a == 255 {
+		return c
+	}
+	v := (int(c)*255 + int(a)/2) / int(a)
+	if v > 255 {
+		v = 255
+	}
+	return uint8(v)
+}
@@ -0,0 +1,123 @@
+package svgg
+
+import (
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font/sfnt"
+)
+
+// Link is a hyperlink of a document, with the area it covers when drawn.
+type Link struct {
+	Href    string
+	Target  string
+	Element *Element // the <a> element
+
+	// X0, Y0, X1, Y1 bound the shapes, text and images inside the link,
+	// not including strokes, in the coordinate system Draw draws into.
+	// They are pixel coordinates when the document is drawn to a context
+	// with an identity matrix, so a click on the raster can be mapped to
+	// the link it lands on.
+	X0, Y0, X1, Y1 float64
+}
+
+// Links returns the <a> elements of the document that draw something, in
+// document order, with the boxes they cover. Content drawn through <use>
+// belongs to the links around the <use> element.
+//
+// Elements whose geometry cannot be read are left out, unless the
+// document's ErrorMode is StrictErrorMode.
+func (doc *Document) Links() ([]Link, error) {
+	parents := make(map[*Element]*Element)
+	var links []*Element
+	var index func(e *Element)
+	index = func(e *Element) {
+		if e.Name == "a" {
+			links = append(links, e)
+		}
+		for _, c := range e.Children {
+			parents[c] = e
+			index(c)
+		}
+	}
+	index(doc.Root)
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	vb := viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, doc.Root.Attr("preserveAspectRatio"))
+	boxes := make(map[*Element]*boundsSink)
+	b := &boundsSink{}
+	t := &transformSink{sink: b}
+	p := NewSinkParser(t)
+	var buf sfnt.Buffer
+	err := doc.walkGeometry(doc.ErrorMode == StrictErrorMode, func(e *Element, m gg.Matrix, s style, use *Element) error {
+		*b = boundsSink{}
+		t.m = m.Multiply(vb)
+		switch e.Name {
+		case "image":
+			_, hasW := e.LookupAttr("width")
+			_, hasH := e.LookupAttr("height")
+			f, err := floatAttrs(e, "x", "y", "width", "height")
+			if err != nil || !hasW || !hasH {
+				return err
+			}
+			t.MoveTo(f[0], f[1])
+			t.LineTo(f[0]+f[2], f[1])
+			t.LineTo(f[0]+f[2], f[1]+f[3])
+			t.LineTo(f[0], f[1]+f[3])
+		case "text":
+			l, err := doc.layoutText(e, s)
+			if err != nil {
+				return err
+			}
+			if err := l.outline(&buf, t, 0, len(l.glyphs)); err != nil {
+				return err
+			}
+		default:
+			if err := buildShape(p, e); err != nil {
+				return err
+			}
+		}
+		if !b.started {
+			return nil
+		}
+		start := e
+		if use != nil {
+			start = use
+		}
+		for a := start; a != nil; a = parents[a] {
+			if a.Name != "a" {
+				continue
+			}
+			box := boxes[a]
+			if box == nil {
+				box = &boundsSink{}
+				boxes[a] = box
+			}
+			box.add(b.x0, b.y0)
+			box.add(b.x1, b.y1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Link
+	for _, a := range links {
+		box := boxes[a]
+		if box == nil {
+			continue
+		}
+		ref, _ := href(a)
+		out = append(out, Link{
+			Href:    ref,
+			Target:  a.Attr("target"),
+			Element: a,
+			X0:      box.x0,
+			Y0:      box.y0,
+			X1:      box.x1,
+			Y1:      box.y1,
+		})
+	}
+	return out, nil
+}
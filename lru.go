@@ -0,0 +1,82 @@
+package svgg
+
+import "container/list"
+
+// lru is a least recently used cache bounded by the total size of its
+// values. It is not safe for concurrent use.
+type lru struct {
+	max   int64 // bound on size; zero or less is unbounded
+	size  int64
+	order *list.List // of *lruEntry, most recently used first
+	items map[interface{}]*list.Element
+
+	// evicted, if set, is called with each value evicted to make room
+	// for another, but not with those removed.
+	evicted func(key, value interface{})
+}
+
+type lruEntry struct {
+	key   interface{}
+	value interface{}
+	size  int64
+}
+
+func newLRU(max int64) *lru {
+	return &lru{max: max, order: list.New(), items: make(map[interface{}]*list.Element)}
+}
+
+// get returns the value stored for key, marking it as recently used.
+func (c *lru) get(key interface{}) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+// add stores value of the given size for key, evicting the least recently
+// used values while the cache is over its bound, and reports whether it was
+// stored. A value larger than the bound is not.
+func (c *lru) add(key, value interface{}, size int64) bool {
+	if c.max > 0 && size > c.max {
+		return false
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		c.size += size - e.size
+		e.value, e.size = value, size
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&lruEntry{key: key, value: value, size: size})
+		c.size += size
+	}
+	for c.max > 0 && c.size > c.max {
+		el := c.order.Back()
+		e := el.Value.(*lruEntry)
+		c.order.Remove(el)
+		delete(c.items, e.key)
+		c.size -= e.size
+		if c.evicted != nil {
+			c.evicted(e.key, e.value)
+		}
+	}
+	return true
+}
+
+// remove deletes the value stored for key, if any.
+func (c *lru) remove(key interface{}) {
+	el, ok := c.items[key]
+	if !ok {
+		return
+	}
+	e := el.Value.(*lruEntry)
+	c.order.Remove(el)
+	delete(c.items, key)
+	c.size -= e.size
+}
+
+// len returns the number of values in the cache.
+func (c *lru) len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,249 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// markerElements are the elements that markers are drawn on.
+var markerElements = map[string]bool{
+	"path":     true,
+	"line":     true,
+	"polyline": true,
+	"polygon":  true,
+}
+
+// vertex is a point of a path where a marker may be drawn, with the
+// directions of the path into and out of it in radians. NaN means the path
+// has no segment on that side.
+type vertex struct {
+	x, y    float64
+	in, out float64
+}
+
+// vertexSink collects the vertices of the segments sent to it.
+type vertexSink struct {
+	vertices []vertex
+	start    int // index of the first vertex of the current subpath
+	x, y     float64
+}
+
+func (v *vertexSink) MoveTo(x, y float64) {
+	v.start = len(v.vertices)
+	v.vertices = append(v.vertices, vertex{x: x, y: y, in: math.NaN(), out: math.NaN()})
+	v.x, v.y = x, y
+}
+
+// segment adds the end point x, y of a segment leaving the current point
+// in direction out and arriving in direction in.
+func (v *vertexSink) segment(x, y, out, in float64) {
+	if len(v.vertices) == 0 {
+		v.MoveTo(v.x, v.y)
+	}
+	last := &v.vertices[len(v.vertices)-1]
+	last.out = out
+	v.vertices = append(v.vertices, vertex{x: x, y: y, in: in, out: math.NaN()})
+	v.x, v.y = x, y
+}
+
+func (v *vertexSink) LineTo(x, y float64) {
+	a := math.Atan2(y-v.y, x-v.x)
+	v.segment(x, y, a, a)
+}
+
+func (v *vertexSink) QuadraticTo(x1, y1, x, y float64) {
+	v.CubicTo(x1, y1, x1, y1, x, y)
+}
+
+func (v *vertexSink) CubicTo(x1, y1, x2, y2, x, y float64) {
+	// a control point on an end point leaves the direction to the next
+	out := direction(v.x, v.y, x1, y1, x2, y2, x, y)
+	in := direction(x, y, x2, y2, x1, y1, v.x, v.y)
+	v.segment(x, y, out, in+math.Pi)
+}
+
+func (v *vertexSink) ArcTo(a Arc, x, y float64) {
+	v.segment(x, y, a.tangent(a.Theta1), a.tangent(a.Theta1+a.DTheta))
+}
+
+func (v *vertexSink) ClosePath() {
+	if len(v.vertices) == 0 {
+		return
+	}
+	first := &v.vertices[v.start]
+	if v.x != first.x || v.y != first.y {
+		v.LineTo(first.x, first.y)
+		first = &v.vertices[v.start]
+	}
+	// the closing vertex joins the last segment to the first
+	last := &v.vertices[len(v.vertices)-1]
+	last.out = first.out
+	first.in = last.in
+	v.x, v.y = first.x, first.y
+}
+
+// direction returns the direction from x0, y0 to the first of the points
+// pts that differs from it.
+func direction(x0, y0 float64, pts ...float64) float64 {
+	for i := 0; i+1 < len(pts); i += 2 {
+		if pts[i] != x0 || pts[i+1] != y0 {
+			return math.Atan2(pts[i+1]-y0, pts[i]-x0)
+		}
+	}
+	return 0
+}
+
+// tangent returns the direction in which the arc runs at angle theta.
+func (a Arc) tangent(theta float64) float64 {
+	sinPhi, cosPhi := math.Sincos(a.Phi)
+	s, c := math.Sincos(theta)
+	dx := -a.Rx*cosPhi*s - a.Ry*sinPhi*c
+	dy := -a.Rx*sinPhi*s + a.Ry*cosPhi*c
+	if a.DTheta < 0 {
+		dx, dy = -dx, -dy
+	}
+	return math.Atan2(dy, dx)
+}
+
+// bisector returns the direction halfway between the directions in and
+// out, either of which may be NaN.
+func bisector(in, out float64) float64 {
+	switch {
+	case math.IsNaN(in) && math.IsNaN(out):
+		return 0
+	case math.IsNaN(in):
+		return out
+	case math.IsNaN(out):
+		return in
+	}
+	x, y := math.Cos(in)+math.Cos(out), math.Sin(in)+math.Sin(out)
+	if math.Abs(x) < 1e-9 && math.Abs(y) < 1e-9 {
+		return in
+	}
+	return math.Atan2(y, x)
+}
+
+// drawMarkers draws the markers referenced by style s at the vertices of
+// the shape e.
+func (r *renderer) drawMarkers(e *Element, s style) error {
+	if !markerElements[e.Name] || (s.markerStart == "" && s.markerMid == "" && s.markerEnd == "") {
+		return nil
+	}
+	v := &vertexSink{}
+	if err := buildShape(NewSinkParser(v), e); err != nil {
+		return err
+	}
+	n := len(v.vertices)
+	for i, vx := range v.vertices {
+		ref, start := s.markerMid, false
+		switch i {
+		case 0:
+			ref, start = s.markerStart, true
+		case n - 1:
+			ref = s.markerEnd
+		}
+		if ref == "" {
+			continue
+		}
+		if r.debugging(DebugMarkers) {
+			r.debug.anchor(vx, currentMatrix(r.dc))
+		}
+		if err := r.drawMarker(ref, vx, start, s.strokeWidth); err != nil {
+			if err := r.fail(e, fmt.Errorf("marker %q: %w", ref, err)); err != nil {
+				return err
+			}
+			// one bad marker would fail at every vertex
+			return nil
+		}
+	}
+	return nil
+}
+
+// drawMarker draws the marker element referenced by ref at the vertex v,
+// the first of its path if start is set, on a shape stroked with width sw.
+func (r *renderer) drawMarker(ref string, v vertex, start bool, sw float64) error {
+	m, err := r.doc.lookupRef(ref)
+	if err != nil {
+		return err
+	}
+	if m.Name != "marker" {
+		return fmt.Errorf("%w: <%s> used as a marker", ErrNotImplemented, m.Name)
+	}
+	f, err := floatAttrs(m, "refX", "refY")
+	if err != nil {
+		return err
+	}
+	size := [2]float64{3, 3}
+	for i, name := range []string{"markerWidth", "markerHeight"} {
+		if size[i], err = parseLength(m.Attr(name), 3); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if size[0] <= 0 || size[1] <= 0 {
+		return nil
+	}
+
+	// the viewBox is fitted to the marker's size, and its refX, refY is
+	// placed on the vertex
+	vbt := gg.Identity()
+	if vb := m.Attr("viewBox"); vb != "" {
+		b, err := parseFloats(vb)
+		if err != nil || len(b) != 4 {
+			return fmt.Errorf("%w: viewBox %q", ErrSyntax, vb)
+		}
+		if b[2] <= 0 || b[3] <= 0 {
+			return nil
+		}
+		vbt = viewBoxTransform(ViewBox{b[0], b[1], b[2], b[3]}, size[0], size[1], m.Attr("preserveAspectRatio"))
+	}
+	rx, ry := vbt.TransformPoint(f[0], f[1])
+	t := gg.Translate(-rx, -ry)
+	if strings.TrimSpace(m.Attr("markerUnits")) != "userSpaceOnUse" {
+		t = t.Multiply(gg.Scale(sw, sw))
+	}
+	var angle float64
+	switch orient := strings.TrimSpace(m.Attr("orient")); orient {
+	case "auto", "auto-start-reverse":
+		angle = bisector(v.in, v.out)
+		if start && orient == "auto-start-reverse" {
+			angle += math.Pi
+		}
+	case "":
+	default:
+		deg, err := parseCSSNumber(orient)
+		if err != nil {
+			return fmt.Errorf("orient: %w", err)
+		}
+		angle = deg * math.Pi / 180
+	}
+	t = t.Multiply(gg.Rotate(angle)).Multiply(gg.Translate(v.x, v.y))
+
+	for _, d := range r.defs {
+		if d == m {
+			return fmt.Errorf("circular reference to <marker id=%q>", m.Attr("id"))
+		}
+	}
+	r.defs = append(r.defs, m)
+	defer func() { r.defs = r.defs[:len(r.defs)-1] }()
+	s, err := r.doc.rootStyle().resolve(m)
+	if err != nil {
+		return err
+	}
+	dc := r.dc
+	dc.Push()
+	defer dc.Pop()
+	applyMatrix(dc, t)
+	if clipsOverflow(m) {
+		defer func(clip *image.Alpha) {
+			r.clip = clip
+			setClip(dc, clip)
+		}(r.clip)
+		r.clipRect(0, 0, size[0], size[1])
+	}
+	applyMatrix(dc, vbt)
+	return r.drawChildren(m, s)
+}
@@ -0,0 +1,260 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// lengthTolerance is the error allowed when measuring a curve, relative to
+// the length of its control polygon.
+const lengthTolerance = 1e-9
+
+// PathLength returns the length of the path data d.
+func PathLength(d string) (float64, error) {
+	c, err := Compile(d)
+	if err != nil {
+		return 0, err
+	}
+	return c.Length(), nil
+}
+
+// Length returns the total length of the path. Curves and arcs are
+// measured by adaptive numerical integration.
+func (c *CompiledPath) Length() float64 {
+	var l float64
+	c.forEach(func(from gg.Point, s Segment) {
+		l += segmentLength(from, s, 1)
+	})
+	return l
+}
+
+// segmentLength returns the length of the part of segment s, starting at
+// from, up to parameter t in [0, 1].
+func segmentLength(from gg.Point, s Segment, t float64) float64 {
+	switch s.Op {
+	case LineOp, CloseOp:
+		return t * from.Distance(s.P[0])
+	case QuadOp, CubicOp:
+		tol := lengthTolerance * controlLength(from, s)
+		return integrate(func(u float64) float64 { return speed(from, s, u) }, 0, t, tol, 24)
+	case ArcOp:
+		a := s.Arc
+		if a.Rx == a.Ry {
+			return t * math.Abs(a.DTheta) * a.Rx
+		}
+		tol := lengthTolerance * math.Max(a.Rx, a.Ry) * math.Abs(a.DTheta)
+		return integrate(func(u float64) float64 { return speed(from, s, u) }, 0, t, tol, 24)
+	}
+	return 0
+}
+
+// controlLength returns the length of the control polygon of s.
+func controlLength(from gg.Point, s Segment) float64 {
+	l := 0.0
+	n := 1
+	switch s.Op {
+	case QuadOp:
+		n = 2
+	case CubicOp:
+		n = 3
+	}
+	for _, p := range s.P[:n] {
+		l += from.Distance(p)
+		from = p
+	}
+	return l
+}
+
+// derivative returns the derivative of segment s, starting at from, with
+// respect to its parameter t in [0, 1].
+func derivative(from gg.Point, s Segment, t float64) gg.Point {
+	switch s.Op {
+	case LineOp, CloseOp:
+		return gg.Point{X: s.P[0].X - from.X, Y: s.P[0].Y - from.Y}
+	case QuadOp:
+		mt := 1 - t
+		return gg.Point{
+			X: 2*mt*(s.P[0].X-from.X) + 2*t*(s.P[1].X-s.P[0].X),
+			Y: 2*mt*(s.P[0].Y-from.Y) + 2*t*(s.P[1].Y-s.P[0].Y),
+		}
+	case CubicOp:
+		mt := 1 - t
+		a, b, c := 3*mt*mt, 6*mt*t, 3*t*t
+		return gg.Point{
+			X: a*(s.P[0].X-from.X) + b*(s.P[1].X-s.P[0].X) + c*(s.P[2].X-s.P[1].X),
+			Y: a*(s.P[0].Y-from.Y) + b*(s.P[1].Y-s.P[0].Y) + c*(s.P[2].Y-s.P[1].Y),
+		}
+	case ArcOp:
+		a := s.Arc
+		sinPhi, cosPhi := math.Sincos(a.Phi)
+		sin, cos := math.Sincos(a.Theta1 + t*a.DTheta)
+		dx := -a.Rx*cosPhi*sin - a.Ry*sinPhi*cos
+		dy := -a.Rx*sinPhi*sin + a.Ry*cosPhi*cos
+		return gg.Point{X: dx * a.DTheta, Y: dy * a.DTheta}
+	}
+	return gg.Point{}
+}
+
+// speed returns the magnitude of the derivative of s at t.
+func speed(from gg.Point, s Segment, t float64) float64 {
+	d := derivative(from, s, t)
+	return math.Hypot(d.X, d.Y)
+}
+
+// integrate returns the integral of f over [a, b], bisecting the interval
+// until the estimate changes by less than tol or depth is exhausted.
+func integrate(f func(float64) float64, a, b, tol float64, depth int) float64 {
+	whole := gaussLegendre(f, a, b)
+	m := (a + b) / 2
+	left, right := gaussLegendre(f, a, m), gaussLegendre(f, m, b)
+	if depth == 0 || math.Abs(left+right-whole) <= tol {
+		return left + right
+	}
+	return integrate(f, a, m, tol/2, depth-1) + integrate(f, m, b, tol/2, depth-1)
+}
+
+// gaussLegendre5 holds the nodes and weights of 5-point Gauss-Legendre
+// quadrature on [-1, 1].
+var gaussLegendre5 = [5][2]float64{
+	{0, 0.5688888888888889},
+	{-0.5384693101056831, 0.47862867049936647},
+	{0.5384693101056831, 0.47862867049936647},
+	{-0.906179845938664, 0.23692688505618908},
+	{0.906179845938664, 0.23692688505618908},
+}
+
+// gaussLegendre integrates f over [a, b] with 5-point Gauss-Legendre
+// quadrature.
+func gaussLegendre(f func(float64) float64, a, b float64) float64 {
+	h, m := (b-a)/2, (a+b)/2
+	sum := 0.0
+	for _, nw := range gaussLegendre5 {
+		sum += nw[1] * f(m+h*nw[0])
+	}
+	return sum * h
+}
+
+// PointAt returns the point at distance length along the path. Lengths
+// outside the path are clamped to its ends.
+func (c *CompiledPath) PointAt(length float64) gg.Point {
+	from, s, t := c.locate(length)
+	return pointOn(from, s, t)
+}
+
+// TangentAt returns the unit direction of the path at distance length
+// along it, for orienting markers or sprites. It is the zero vector for a
+// path with no length.
+func (c *CompiledPath) TangentAt(length float64) gg.Point {
+	from, s, t := c.locate(length)
+	d := derivative(from, s, t)
+	if d.X == 0 && d.Y == 0 {
+		// curves whose control points coincide with an end point have no
+		// derivative there, but a well defined direction just inside
+		if t < 0.5 {
+			t += 1e-6
+		} else {
+			t -= 1e-6
+		}
+		d = derivative(from, s, t)
+	}
+	n := math.Hypot(d.X, d.Y)
+	if n == 0 {
+		return gg.Point{}
+	}
+	return gg.Point{X: d.X / n, Y: d.Y / n}
+}
+
+// locate finds the segment at distance length along the path, returning
+// its start point and the parameter within it.
+func (c *CompiledPath) locate(length float64) (from gg.Point, seg Segment, t float64) {
+	var cur gg.Point
+	var last Segment
+	var lastFrom gg.Point
+	found := false
+	for _, s := range c.Segments {
+		if s.Op == MoveOp {
+			cur = s.P[0]
+			if !found {
+				// before any drawing segment, the path is at its first point
+				lastFrom, last = cur, Segment{Op: LineOp, P: [3]gg.Point{cur}}
+			}
+			continue
+		}
+		l := segmentLength(cur, s, 1)
+		if l > 0 {
+			if length <= l {
+				return cur, s, segmentParam(cur, s, length, l)
+			}
+			length -= l
+			lastFrom, last, found = cur, s, true
+		}
+		cur = s.End()
+	}
+	if found {
+		return lastFrom, last, 1
+	}
+	return lastFrom, last, 0
+}
+
+// segmentParam returns the parameter at which the part of s, starting at
+// from, reaches the given length. total is the length of the whole segment.
+func segmentParam(from gg.Point, s Segment, length, total float64) float64 {
+	if length <= 0 {
+		return 0
+	}
+	if s.Op == LineOp || s.Op == CloseOp || (s.Op == ArcOp && s.Arc.Rx == s.Arc.Ry) {
+		return length / total
+	}
+	// Newton's method, kept inside a shrinking bracket
+	lo, hi := 0.0, 1.0
+	t := length / total
+	for i := 0; i < 32; i++ {
+		diff := segmentLength(from, s, t) - length
+		if math.Abs(diff) < lengthTolerance*total {
+			break
+		}
+		if diff > 0 {
+			hi = t
+		} else {
+			lo = t
+		}
+		next := t
+		if v := speed(from, s, t); v > 0 {
+			next = t - diff/v
+		}
+		if next <= lo || next >= hi {
+			next = (lo + hi) / 2
+		}
+		t = next
+	}
+	return t
+}
+
+// pointOn returns the point of segment s, starting at from, at parameter t.
+func pointOn(from gg.Point, s Segment, t float64) gg.Point {
+	mt := 1 - t
+	switch s.Op {
+	case LineOp, CloseOp:
+		return from.Interpolate(s.P[0], t)
+	case QuadOp:
+		a, b, c := mt*mt, 2*mt*t, t*t
+		return gg.Point{
+			X: a*from.X + b*s.P[0].X + c*s.P[1].X,
+			Y: a*from.Y + b*s.P[0].Y + c*s.P[1].Y,
+		}
+	case CubicOp:
+		a, b, c, d := mt*mt*mt, 3*mt*mt*t, 3*mt*t*t, t*t*t
+		return gg.Point{
+			X: a*from.X + b*s.P[0].X + c*s.P[1].X + d*s.P[2].X,
+			Y: a*from.Y + b*s.P[0].Y + c*s.P[1].Y + d*s.P[2].Y,
+		}
+	case ArcOp:
+		if t == 1 {
+			return s.P[0]
+		}
+		x, y := s.Arc.Point(s.Arc.Theta1 + t*s.Arc.DTheta)
+		return gg.Point{X: x, Y: y}
+	}
+	return s.P[0]
+}
@@ -0,0 +1,180 @@
+package svgg
+
+import "github.com/fogleman/gg"
+
+// InterpolatePaths returns path data blended between the path data a, at t
+// of 0, and b, at t of 1, for tweening one shape into another. Both paths
+// are converted to cubic Béziers, and curves are split until their
+// subpaths have matching numbers of curves. Closed subpaths are matched
+// up by direction and starting point, so that shapes do not turn inside
+// out on the way. A subpath missing from one path grows out of the start
+// of its counterpart.
+func InterpolatePaths(a, b string, t float64) (string, error) {
+	ca, err := Compile(a)
+	if err != nil {
+		return "", err
+	}
+	cb, err := Compile(b)
+	if err != nil {
+		return "", err
+	}
+	sa, sb := ca.cubicSubpaths(), cb.cubicSubpaths()
+	for len(sa) < len(sb) {
+		sa = append(sa, pointSubpath(sb[len(sa)].start))
+	}
+	for len(sb) < len(sa) {
+		sb = append(sb, pointSubpath(sa[len(sb)].start))
+	}
+	out := &CompiledPath{}
+	for i := range sa {
+		pa, pb := sa[i], sb[i]
+		pa.split(len(pb.curves))
+		pb.split(len(pa.curves))
+		if pa.closed && pb.closed {
+			pb.align(pa)
+		}
+		p := pa.start.Interpolate(pb.start, t)
+		out.MoveTo(p.X, p.Y)
+		for k := range pa.curves {
+			var c [3]gg.Point
+			for j := range c {
+				c[j] = pa.curves[k][j].Interpolate(pb.curves[k][j], t)
+			}
+			out.CubicTo(c[0].X, c[0].Y, c[1].X, c[1].Y, c[2].X, c[2].Y)
+		}
+		closed := pa.closed
+		if t >= 0.5 {
+			closed = pb.closed
+		}
+		if closed {
+			out.ClosePath()
+		}
+	}
+	return out.String(), nil
+}
+
+// cubicSubpath is a subpath made only of cubic Béziers, each given by its
+// two control points and end point.
+type cubicSubpath struct {
+	start  gg.Point
+	curves [][3]gg.Point
+	closed bool
+}
+
+// pointSubpath returns a subpath that stays at p.
+func pointSubpath(p gg.Point) *cubicSubpath {
+	return &cubicSubpath{start: p, curves: [][3]gg.Point{{p, p, p}}}
+}
+
+// cubicSubpaths converts the path to cubic Béziers. Closed subpaths get an
+// explicit closing curve, and subpaths that draw nothing are left out.
+func (c *CompiledPath) cubicSubpaths() []*cubicSubpath {
+	var subs []*cubicSubpath
+	var cur *cubicSubpath
+	end := func() {
+		if cur != nil && len(cur.curves) > 0 {
+			subs = append(subs, cur)
+		}
+		cur = nil
+	}
+	line := func(from, to gg.Point) {
+		cur.curves = append(cur.curves, [3]gg.Point{from.Interpolate(to, 1.0/3), from.Interpolate(to, 2.0/3), to})
+	}
+	c.forEach(func(from gg.Point, s Segment) {
+		if s.Op == MoveOp {
+			end()
+			cur = &cubicSubpath{start: s.P[0]}
+			return
+		}
+		if cur == nil {
+			cur = &cubicSubpath{start: from}
+		}
+		switch s.Op {
+		case LineOp:
+			line(from, s.P[0])
+		case QuadOp:
+			cur.curves = append(cur.curves, quadToCubic(from, s.P[0], s.P[1]))
+		case CubicOp:
+			cur.curves = append(cur.curves, s.P)
+		case ArcOp:
+			s.Arc.cubics(s.P[0].X, s.P[0].Y, 0, func(x1, y1, x2, y2, x, y float64) {
+				cur.curves = append(cur.curves, [3]gg.Point{{X: x1, Y: y1}, {X: x2, Y: y2}, {X: x, Y: y}})
+			})
+		case CloseOp:
+			if from != cur.start {
+				line(from, cur.start)
+			}
+			cur.closed = true
+			end()
+		}
+	})
+	end()
+	return subs
+}
+
+// split splits the longest curves of the subpath in half until it has n
+// curves.
+func (s *cubicSubpath) split(n int) {
+	for len(s.curves) < n {
+		longest, max := 0, -1.0
+		from := s.start
+		for i, c := range s.curves {
+			if l := from.Distance(c[0]) + c[0].Distance(c[1]) + c[1].Distance(c[2]); l > max {
+				longest, max = i, l
+			}
+			from = c[2]
+		}
+		if longest > 0 {
+			from = s.curves[longest-1][2]
+		} else {
+			from = s.start
+		}
+		c := s.curves[longest]
+		// de Casteljau at one half
+		ab, bc, cd := from.Interpolate(c[0], 0.5), c[0].Interpolate(c[1], 0.5), c[1].Interpolate(c[2], 0.5)
+		abc, bcd := ab.Interpolate(bc, 0.5), bc.Interpolate(cd, 0.5)
+		mid := abc.Interpolate(bcd, 0.5)
+		s.curves = append(s.curves, [3]gg.Point{})
+		copy(s.curves[longest+2:], s.curves[longest+1:])
+		s.curves[longest] = [3]gg.Point{ab, abc, mid}
+		s.curves[longest+1] = [3]gg.Point{bcd, cd, c[2]}
+	}
+}
+
+// points returns the start and end points of the curves of the subpath.
+func (s *cubicSubpath) points() []gg.Point {
+	pts := []gg.Point{s.start}
+	for _, c := range s.curves {
+		pts = append(pts, c[2])
+	}
+	return pts
+}
+
+// align reverses and rotates the curves of a closed subpath so that it runs
+// the same way as to, starting from the point that best matches its start.
+func (s *cubicSubpath) align(to *cubicSubpath) {
+	if (signedArea(s.points()) < 0) != (signedArea(to.points()) < 0) {
+		pts := s.points()
+		n := len(s.curves)
+		r := make([][3]gg.Point, n)
+		for i, c := range s.curves {
+			r[n-1-i] = [3]gg.Point{c[1], c[0], pts[i]}
+		}
+		s.start, s.curves = pts[n], r
+	}
+	pts, toPts := s.points(), to.points()
+	n := len(s.curves)
+	best, min := 0, -1.0
+	for k := 0; k < n; k++ {
+		d := 0.0
+		for i := 0; i < n; i++ {
+			p, q := pts[(i+k)%n], toPts[i]
+			d += (p.X-q.X)*(p.X-q.X) + (p.Y-q.Y)*(p.Y-q.Y)
+		}
+		if min < 0 || d < min {
+			best, min = k, d
+		}
+	}
+	s.start = pts[best]
+	s.curves = append(s.curves[best:], s.curves[:best]...)
+}
@@ -0,0 +1,92 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// NineSlice divides a document into nine cells by four guides, for
+// drawing vector UI chrome such as panels and buttons at any size. The
+// corner cells keep their size, the edge cells stretch along their edge
+// and the center cell stretches both ways.
+type NineSlice struct {
+	// Left, Top, Right and Bottom are the distances of the guides from
+	// the document's edges, in the units of its width and height.
+	Left, Top, Right, Bottom float64
+
+	// Scale scales the corners and the thickness of the edges, for
+	// high-density displays. Zero means 1.
+	Scale float64
+}
+
+// DrawNineSlice draws doc at width by height pixels with the nine-slice
+// semantics of s. Each cell is drawn from the vector document on its own,
+// so edges stay sharp and cells do not bleed into their neighbors. If the
+// image is too small for the corners, they are shrunk to fit.
+func DrawNineSlice(doc *Document, width, height int, s NineSlice, opts *RenderOptions) (*image.RGBA, error) {
+	dw, dh := doc.Width, doc.Height
+	if dw <= 0 || dh <= 0 {
+		return nil, fmt.Errorf("svgg: empty document size %gx%g", dw, dh)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("svgg: invalid nine-slice size %dx%d", width, height)
+	}
+	if s.Left < 0 || s.Top < 0 || s.Right < 0 || s.Bottom < 0 ||
+		s.Left+s.Right > dw || s.Top+s.Bottom > dh {
+		return nil, fmt.Errorf("svgg: nine-slice guides %g %g %g %g outside %gx%g document",
+			s.Left, s.Top, s.Right, s.Bottom, dw, dh)
+	}
+	k := s.Scale
+	if k <= 0 {
+		k = 1
+	}
+	src := [2][4]float64{
+		{0, s.Left, dw - s.Right, dw},
+		{0, s.Top, dh - s.Bottom, dh},
+	}
+	dst := [2][4]int{
+		sliceEdges(s.Left*k, s.Right*k, width),
+		sliceEdges(s.Top*k, s.Bottom*k, height),
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 3; i++ {
+			r := image.Rect(dst[0][i], dst[1][j], dst[0][i+1], dst[1][j+1])
+			sw, sh := src[0][i+1]-src[0][i], src[1][j+1]-src[1][j]
+			if r.Empty() || sw <= 0 || sh <= 0 {
+				continue
+			}
+			// the source cell is mapped onto the destination cell, and the
+			// cell's own context clips away the rest of the document
+			dc := gg.NewContext(r.Dx(), r.Dy())
+			dc.Scale(float64(r.Dx())/sw, float64(r.Dy())/sh)
+			dc.Translate(-src[0][i], -src[1][j])
+			if err := doc.DrawWithOptions(dc, opts); err != nil {
+				return out, err
+			}
+			draw.Draw(out, r, dc.Image(), image.Point{}, draw.Src)
+		}
+	}
+	return out, nil
+}
+
+// sliceEdges returns the pixel positions of the four edges of the cells
+// along a side of n pixels with end cells of a and b pixels, shrinking the
+// end cells in proportion if they do not fit.
+func sliceEdges(a, b float64, n int) [4]int {
+	if a+b > float64(n) {
+		k := float64(n) / (a + b)
+		a, b = a*k, b*k
+	}
+	i := int(math.Round(a))
+	j := n - int(math.Round(b))
+	if j < i {
+		j = i
+	}
+	return [4]int{0, i, j, n}
+}
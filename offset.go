@@ -0,0 +1,79 @@
+package svgg
+
+import "github.com/fogleman/gg"
+
+// Offset returns the path moved a distance d away from itself, for buffers
+// around map features or halos around shapes. Closed subpaths grow by d,
+// whichever way they wind, and shrink for negative d; open subpaths move d
+// to their left. Curves are flattened within DefaultTolerance, and outer
+// corners are rounded so that the result stays d away from the path.
+//
+// Offset does not remove the loops that appear where d is larger than
+// features of the path, such as when a narrow shape is shrunk.
+func (c *CompiledPath) Offset(d float64) *CompiledPath {
+	if d == 0 {
+		return &CompiledPath{Segments: append([]Segment(nil), c.Segments...)}
+	}
+	out := &CompiledPath{}
+	s := stroker{tol: DefaultTolerance, out: out, trim: true}
+	for _, sub := range c.subpaths(DefaultTolerance) {
+		pts := sub.pts
+		if len(pts) < 2 {
+			continue
+		}
+		// the stroker offsets to the left, which is outward for a closed
+		// subpath with a positive area
+		left := d
+		if sub.closed && signedArea(pts) < 0 {
+			left = -d
+		}
+		flip := left < 0
+		if flip {
+			pts = reverse(pts)
+			left = -left
+		}
+		s.hw = left
+		closed := sub.closed && len(pts) > 2
+		ring := s.side(nil, pts, closed)
+		if flip {
+			// keep the direction of the subpath
+			ring = reverse(ring)
+		}
+		out.MoveTo(ring[0].X, ring[0].Y)
+		for _, p := range ring[1:] {
+			out.LineTo(p.X, p.Y)
+		}
+		if closed {
+			out.ClosePath()
+		}
+	}
+	return out
+}
+
+// signedArea returns the area enclosed by the polygon pts, positive when
+// it runs clockwise in the y-down coordinates of the path.
+func signedArea(pts []gg.Point) float64 {
+	a := 0.0
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		a += p.X*q.Y - q.X*p.Y
+	}
+	return a / 2
+}
+
+// crossing returns the point where the segments from a to b and from c to
+// d cross, if they do.
+func crossing(a, b, c, d gg.Point) (gg.Point, bool) {
+	rx, ry := b.X-a.X, b.Y-a.Y
+	sx, sy := d.X-c.X, d.Y-c.Y
+	den := rx*sy - ry*sx
+	if den == 0 {
+		return gg.Point{}, false
+	}
+	t := ((c.X-a.X)*sy - (c.Y-a.Y)*sx) / den
+	u := ((c.X-a.X)*ry - (c.Y-a.Y)*rx) / den
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return gg.Point{}, false
+	}
+	return gg.Point{X: a.X + t*rx, Y: a.Y + t*ry}, true
+}
@@ -0,0 +1,143 @@
+package svgg
+
+import (
+	"errors"
+	"image/color"
+	"time"
+)
+
+// ErrDeadline is returned when drawing runs past the time allowed by
+// RenderOptions. Whatever was drawn before the deadline is left on the
+// context.
+var ErrDeadline = errors.New("render deadline exceeded")
+
+// RenderOptions configures how a Document is drawn. The zero value draws
+// like Document.Draw.
+type RenderOptions struct {
+	// Timeout bounds the total wall time spent drawing.
+	Timeout time.Duration
+
+	// ElementTimeout bounds the time spent on any single element. Drawing
+	// stops after the first element that overruns it.
+	ElementTimeout time.Duration
+
+	// Stats, if non-nil, accumulates counts of the work done. Concurrent
+	// draws must each use their own Stats.
+	Stats *Stats
+
+	// ChunkSize, if positive, paints paths in chunks of about this many
+	// segments rather than building each path whole, bounding peak memory
+	// for geographic linework with hundreds of thousands of points. Filled
+	// paths are only split between subpaths, so holes cut by one subpath
+	// into another may be lost; stroke-only paths are also split within
+	// subpaths. See Parser.ChunkSize.
+	ChunkSize int
+
+	// Epsilon, if positive, drops path points closer than this many device
+	// pixels to the previous point drawn. A value around 0.25 speeds up
+	// high-resolution linework with no visible change. See Parser.Epsilon.
+	Epsilon float64
+
+	// Supersample, if greater than 1, draws at Supersample times the
+	// resolution of the context and filters the result down, for crisper
+	// small icons than gg's antialiasing gives. 2 or 4 are typical; memory
+	// use grows with its square.
+	Supersample int
+
+	// ShapeRendering, if set, overrides the shape-rendering property of
+	// every element. "crispEdges" and "optimizeSpeed" snap shapes to pixel
+	// boundaries, or to the supersampling grid, so their axis-aligned
+	// edges are drawn sharp; any other value draws them antialiased.
+	ShapeRendering string
+
+	// Background, if non-nil, is painted over the whole context before the
+	// document is drawn, for output formats without transparency such as
+	// JPEG. A nil Background leaves the context as it is.
+	Background color.Color
+
+	// LinearRGB composites every shape in linear light rather than sRGB,
+	// as color-interpolation="linearRGB" does for a single element, and
+	// interpolates gradient stops in linear light. This gives more even
+	// translucent overlaps, antialiased edges and gradients. Each shape is
+	// drawn on a scratch layer the size of the context, so it is slower.
+	LinearRGB bool
+
+	// Animate draws the document as it stands Time into its SMIL
+	// animations, rather than as authored. See Document.DrawAt.
+	Animate bool
+	Time    time.Duration
+
+	// Tolerance, if positive, flattens curves and arcs into lines within
+	// this many device pixels of the true curve, rather than leaving the
+	// subdivision to gg. See Parser.Tolerance.
+	Tolerance float64
+
+	// Quantize, if positive, rounds the points of every shape to a grid
+	// of this many output pixels, such as 1.0/16, before drawing. Map
+	// polygons sharing an edge then meet at exactly the same coordinates,
+	// reducing the antialiasing shimmer along their seams, and so do the
+	// edges of tiles drawn at whole-pixel offsets. Shapes drawn with
+	// crispEdges are snapped to whole pixels instead. See Parser.PixelSnap.
+	Quantize float64
+
+	// ClipGeometry clips paths and shapes to the bounds of the context as
+	// they are built, rather than leaving it to the rasterizer, so that
+	// drawing a small tile of a huge map does not rasterize the geometry
+	// around it. Fills are clipped as polygons and strokes as lines, to a
+	// rectangle larger than the context by the stroke width; dashed
+	// strokes are left whole. See Parser.ClipRect.
+	ClipGeometry bool
+
+	// Placeholders draws elements the renderer does not support, such as
+	// <foreignObject>, as hatched gray boxes over their bounds rather than
+	// leaving them out, so that what did not translate is plain to see.
+	// PlaceholderLabels also labels each box with the element's name and
+	// id, in the context's font.
+	Placeholders      bool
+	PlaceholderLabels bool
+
+	// Filters are applied in turn to the colors of the drawn document
+	// before it is composited onto the context, for states such as a
+	// grayed out or tinted icon. The document is drawn on a layer the size
+	// of the context, which ignores the context's clip.
+	Filters []PostFilter
+
+	// Wireframe ignores fills, strokes, clips and masks and outlines every
+	// shape, glyph and image with a line one output pixel wide of
+	// WireframeColor, black if nil, to show the geometry of a document,
+	// including shapes that overlap or are hidden.
+	Wireframe      bool
+	WireframeColor color.Color
+
+	// MapColor, if non-nil, is called with every color a fill or stroke
+	// of the element e resolves to, including gradient stops and the
+	// fallback colors of paint servers, and paints the color it returns
+	// instead, or nothing if it returns nil. It recolors icons at render
+	// time, such as to force a brand palette. The fill, stroke and group
+	// opacities are applied to the returned color; a stop's stop-opacity
+	// is already in its color.
+	MapColor func(c color.Color, e *Element) color.Color
+
+	// DarkMode inverts the lightness of every paint, after MapColor,
+	// keeping its hue and saturation, so that icons drawn dark on light
+	// are drawn light on dark. Raster images and the Background are left
+	// as they are.
+	DarkMode bool
+
+	// Debug draws the diagnostics it selects over the document, such as
+	// path control points and bounding boxes.
+	Debug DebugOverlay
+
+	// Deterministic draws bit-identical output for the same document and
+	// options on every run and platform, so that golden image tests do not
+	// flake: Timeout and ElementTimeout are ignored, since they cut drawing
+	// short depending on the speed of the machine, and path geometry is
+	// rounded to gg's fixed-point grid as described for
+	// Parser.Deterministic, which also flattens curves within Tolerance.
+	Deterministic bool
+
+	// WorldFile, if non-nil, is set to the georeferencing of the context's
+	// pixels in the document's user units, for documents whose viewBox is
+	// in map coordinates. See WorldFile.
+	WorldFile *WorldFile
+}
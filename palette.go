@@ -0,0 +1,99 @@
+package svgg
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/fogleman/gg"
+)
+
+// PaletteColor is a color a document paints with.
+type PaletteColor struct {
+	// Color is the color as given, without the fill, stroke or group
+	// opacity it is painted with. The alpha of a gradient stop includes
+	// its stop-opacity.
+	Color color.NRGBA
+
+	// Count is the number of fills, strokes and gradient stops painted
+	// with the color, counting shapes drawn through <use> once per use.
+	Count int
+
+	// Elements are the shapes painted with the color, in drawing order.
+	Elements []*Element
+}
+
+// Palette returns the distinct colors the document's shapes and text are
+// filled and stroked with, including the stops of their gradients, most
+// used first. Theming tools and design checks can use it to find colors
+// outside a palette. Elements whose style cannot be read are left out.
+func (doc *Document) Palette() []PaletteColor {
+	index := make(map[color.NRGBA]int)
+	var palette []PaletteColor
+	type key struct {
+		color int
+		e     *Element
+	}
+	seen := make(map[key]bool)
+	add := func(c color.Color, e *Element) {
+		if c == nil {
+			return
+		}
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		i, ok := index[n]
+		if !ok {
+			i = len(palette)
+			index[n] = i
+			palette = append(palette, PaletteColor{Color: n})
+		}
+		p := &palette[i]
+		p.Count++
+		if !seen[key{i, e}] {
+			seen[key{i, e}] = true
+			p.Elements = append(p.Elements, e)
+		}
+	}
+	paint := func(server string, c color.Color, e *Element) {
+		if server == "" {
+			add(c, e)
+			return
+		}
+		if g, err := doc.gradient(server); err == nil {
+			for _, stop := range g.stops {
+				add(stop.color, e)
+			}
+		} else {
+			add(c, e)
+		}
+	}
+	var shape func(e *Element, s style)
+	shape = func(e *Element, s style) {
+		if !s.display {
+			return
+		}
+		paint(s.fillServer, s.fill, e)
+		if s.strokeWidth > 0 {
+			paint(s.strokeServer, s.stroke, e)
+		}
+		if e.Name != "text" && e.Name != "tspan" {
+			return
+		}
+		for _, c := range e.Children {
+			if c.Name == "tspan" {
+				if cs, err := s.resolve(c); err == nil {
+					shape(c, cs)
+				}
+			}
+		}
+	}
+	doc.walkGeometry(false, func(e *Element, m gg.Matrix, s style, use *Element) error {
+		if e.Name != "image" {
+			shape(e, s)
+		}
+		return nil
+	})
+
+	sort.SliceStable(palette, func(i, j int) bool {
+		return palette[i].Count > palette[j].Count
+	})
+	return palette
+}
@@ -0,0 +1,250 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// SegmentOp is the kind of a Segment of a CompiledPath.
+type SegmentOp uint8
+
+const (
+	// MoveOp starts a new subpath at P[0].
+	MoveOp SegmentOp = iota
+	// LineOp draws a straight line to P[0].
+	LineOp
+	// QuadOp draws a quadratic Bézier with control point P[0] to P[1].
+	QuadOp
+	// CubicOp draws a cubic Bézier with control points P[0] and P[1] to P[2].
+	CubicOp
+	// ArcOp draws the elliptical arc Arc to P[0].
+	ArcOp
+	// CloseOp closes the subpath with a line back to its start, P[0].
+	CloseOp
+)
+
+// Segment is a single drawing operation of a CompiledPath, in absolute
+// coordinates.
+type Segment struct {
+	Op  SegmentOp
+	P   [3]gg.Point
+	Arc Arc // only set for ArcOp
+}
+
+// End returns the point at which the segment ends.
+func (s Segment) End() gg.Point {
+	switch s.Op {
+	case QuadOp:
+		return s.P[1]
+	case CubicOp:
+		return s.P[2]
+	}
+	return s.P[0]
+}
+
+// CompiledPath is path data parsed once into absolute segments, for
+// measuring and querying a path, or drawing it repeatedly without parsing
+// it again. Arcs are kept exact rather than approximated by curves.
+//
+// CompiledPath is a PathSink, so a Parser can record into one.
+type CompiledPath struct {
+	Segments []Segment
+
+	start gg.Point // start of the current subpath
+}
+
+// Compile parses the path data d into a CompiledPath.
+func Compile(d string) (*CompiledPath, error) {
+	c := &CompiledPath{}
+	if err := NewSinkParser(c).CompilePath(d); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// compileClean compiles the path data d, returning nil if it has errors,
+// which are left to be reported when it is drawn.
+func compileClean(d string) *CompiledPath {
+	c := &CompiledPath{}
+	p := NewSinkParser(c)
+	p.ErrorMode = CollectErrorMode
+	if err := p.CompilePath(d); err != nil {
+		return nil
+	}
+	return c
+}
+
+// CompilePaths compiles the path data of every <path> element of the
+// document into its Compiled field, so that drawing it does not parse the
+// path data again. Path data with errors is left to be parsed when drawn,
+// so that its errors are reported as before.
+func (doc *Document) CompilePaths() {
+	var walk func(e *Element)
+	walk = func(e *Element) {
+		if e.Name == "path" && e.Compiled == nil {
+			e.Compiled = compileClean(e.Attr("d"))
+		}
+		for _, c := range e.Children {
+			walk(c)
+		}
+	}
+	if doc.Root != nil {
+		walk(doc.Root)
+	}
+}
+
+// drawCompiled draws c to the context as CompilePath draws the path data
+// c was compiled from, sending each segment through the Parser as an
+// absolute command so that snapping, flattening and clipping apply alike.
+func (p *Parser) drawCompiled(c *CompiledPath) error {
+	p.init()
+	for i, s := range c.Segments {
+		if (i+1)%deadlineCheckInterval == 0 && p.pastDeadline() {
+			return ErrDeadline
+		}
+		p.cont = false
+		p.points = p.points[:0]
+		var k byte
+		switch s.Op {
+		case MoveOp:
+			k = 'M'
+			p.points = append(p.points, s.P[0].X, s.P[0].Y)
+		case LineOp:
+			k = 'L'
+			p.points = append(p.points, s.P[0].X, s.P[0].Y)
+		case QuadOp:
+			k = 'Q'
+			p.points = append(p.points, s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y)
+		case CubicOp:
+			k = 'C'
+			p.points = append(p.points, s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y, s.P[2].X, s.P[2].Y)
+		case ArcOp:
+			k = 'A'
+			large, sweep := 0.0, 0.0
+			if math.Abs(s.Arc.DTheta) > math.Pi {
+				large = 1
+			}
+			if s.Arc.DTheta > 0 {
+				sweep = 1
+			}
+			p.points = append(p.points, s.Arc.Rx, s.Arc.Ry, s.Arc.Phi*180/math.Pi, large, sweep, s.P[0].X, s.P[0].Y)
+		case CloseOp:
+			k = 'Z'
+		}
+		if err := p.addSeg(k); err != nil {
+			return err
+		}
+	}
+	p.flushPending()
+	p.endClip()
+	return nil
+}
+
+// Draw sends the path to sink. Arcs are sent exactly to an ArcSink and
+// converted to cubic Béziers of at most 90 degrees otherwise, which stray
+// from the arc by less than 0.03% of its radius.
+func (c *CompiledPath) Draw(sink PathSink) {
+	as, _ := sink.(ArcSink)
+	for _, s := range c.Segments {
+		switch s.Op {
+		case MoveOp:
+			sink.MoveTo(s.P[0].X, s.P[0].Y)
+		case LineOp:
+			sink.LineTo(s.P[0].X, s.P[0].Y)
+		case QuadOp:
+			sink.QuadraticTo(s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y)
+		case CubicOp:
+			sink.CubicTo(s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y, s.P[2].X, s.P[2].Y)
+		case ArcOp:
+			if as != nil {
+				as.ArcTo(s.Arc, s.P[0].X, s.P[0].Y)
+			} else {
+				s.Arc.cubics(s.P[0].X, s.P[0].Y, 0, sink.CubicTo)
+			}
+		case CloseOp:
+			sink.ClosePath()
+		}
+	}
+}
+
+// ArcsToCubics returns a copy of the path with its arcs replaced by cubic
+// Béziers that stay within tol of them, for serializing to consumers
+// without arc support. A tol of zero or less gives curves of at most 90
+// degrees, as Draw uses.
+func (c *CompiledPath) ArcsToCubics(tol float64) *CompiledPath {
+	out := &CompiledPath{Segments: make([]Segment, 0, len(c.Segments)), start: c.start}
+	for _, s := range c.Segments {
+		if s.Op == ArcOp {
+			s.Arc.cubics(s.P[0].X, s.P[0].Y, tol, out.CubicTo)
+			continue
+		}
+		out.Segments = append(out.Segments, s)
+	}
+	return out
+}
+
+// NormalizePath rewrites the path data d in absolute M, L, C, A and Z
+// commands only. Relative commands become absolute, H and V become L,
+// smooth and quadratic curves become explicit cubic Béziers, which
+// represent them exactly, and arcs are kept.
+func NormalizePath(d string) (string, error) {
+	c, err := Compile(d)
+	if err != nil {
+		return "", err
+	}
+	n := &CompiledPath{Segments: make([]Segment, 0, len(c.Segments))}
+	c.forEach(func(from gg.Point, s Segment) {
+		if s.Op == QuadOp {
+			s = Segment{Op: CubicOp, P: quadToCubic(from, s.P[0], s.P[1])}
+		}
+		n.Segments = append(n.Segments, s)
+	})
+	return n.String(), nil
+}
+
+// quadToCubic returns the control points and end point of the cubic Bézier
+// equal to the quadratic from from with control point q to p.
+func quadToCubic(from, q, p gg.Point) [3]gg.Point {
+	return [3]gg.Point{from.Interpolate(q, 2.0/3), p.Interpolate(q, 2.0/3), p}
+}
+
+// String returns the path as path data in absolute commands, with every
+// number written exactly. Use a PathEncoder for shorter output.
+func (c *CompiledPath) String() string {
+	return NewPathEncoder().Encode(c)
+}
+
+// forEach calls fn with every segment and the point at which it starts.
+func (c *CompiledPath) forEach(fn func(from gg.Point, s Segment)) {
+	var cur gg.Point
+	for _, s := range c.Segments {
+		fn(cur, s)
+		cur = s.End()
+	}
+}
+
+func (c *CompiledPath) MoveTo(x, y float64) {
+	c.start = gg.Point{X: x, Y: y}
+	c.Segments = append(c.Segments, Segment{Op: MoveOp, P: [3]gg.Point{c.start}})
+}
+
+func (c *CompiledPath) LineTo(x, y float64) {
+	c.Segments = append(c.Segments, Segment{Op: LineOp, P: [3]gg.Point{{X: x, Y: y}}})
+}
+
+func (c *CompiledPath) QuadraticTo(x1, y1, x, y float64) {
+	c.Segments = append(c.Segments, Segment{Op: QuadOp, P: [3]gg.Point{{X: x1, Y: y1}, {X: x, Y: y}}})
+}
+
+func (c *CompiledPath) CubicTo(x1, y1, x2, y2, x, y float64) {
+	c.Segments = append(c.Segments, Segment{Op: CubicOp, P: [3]gg.Point{{X: x1, Y: y1}, {X: x2, Y: y2}, {X: x, Y: y}}})
+}
+
+func (c *CompiledPath) ClosePath() {
+	c.Segments = append(c.Segments, Segment{Op: CloseOp, P: [3]gg.Point{c.start}})
+}
+
+func (c *CompiledPath) ArcTo(a Arc, x, y float64) {
+	c.Segments = append(c.Segments, Segment{Op: ArcOp, P: [3]gg.Point{{X: x, Y: y}}, Arc: a})
+}
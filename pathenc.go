@@ -0,0 +1,281 @@
+package svgg
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// PathEncoder writes CompiledPaths back to path data.
+type PathEncoder struct {
+	// Precision is the number of digits kept after the decimal point.
+	// Negative values keep as many as are needed to represent each number
+	// exactly.
+	Precision int
+
+	// Compact shrinks the output for asset pipelines: each segment is
+	// written in absolute or relative coordinates, whichever is shorter,
+	// the H, V, S and T shorthands are used where they apply, and command
+	// letters, separators and leading zeros that the grammar does not need
+	// are left out.
+	Compact bool
+}
+
+// NewPathEncoder returns a PathEncoder that writes every number exactly,
+// in absolute commands separated by spaces.
+func NewPathEncoder() *PathEncoder {
+	return &PathEncoder{Precision: -1}
+}
+
+// Encode returns the path data for c.
+func (e *PathEncoder) Encode(c *CompiledPath) string {
+	w := pathWriter{enc: e}
+	for _, s := range c.Segments {
+		w.segment(s)
+	}
+	return w.b.String()
+}
+
+// pathWriter holds the state of a single Encode call. Positions are those
+// a parser reading the output would arrive at, so that rounding relative
+// coordinates does not accumulate error.
+type pathWriter struct {
+	enc   *PathEncoder
+	b     strings.Builder
+	cmd   byte     // the last command letter, written or implied
+	last  string   // the last number written, if it was the last token
+	flag  bool     // the last token was an arc flag
+	cur   gg.Point // current point
+	start gg.Point // start of the current subpath
+	ctrl  gg.Point // last control point, for S and T
+	op    SegmentOp
+}
+
+func (w *pathWriter) segment(s Segment) {
+	e := w.enc
+	if !e.Compact {
+		w.absolute(s)
+		return
+	}
+	// round the points once, so that every form agrees on them
+	var p [3]gg.Point
+	for i := range p {
+		p[i] = gg.Point{X: e.round(s.P[i].X), Y: e.round(s.P[i].Y)}
+	}
+	switch s.Op {
+	case MoveOp:
+		if w.op == CloseOp && p[0] == w.cur {
+			// a drawing command after Z starts a subpath there anyway
+			w.op = MoveOp
+			return
+		}
+		w.shortest(p[0], 'M', p[0])
+		w.start = w.cur
+	case LineOp:
+		switch {
+		case e.same(p[0].Y, w.cur.Y):
+			w.shortest(gg.Point{X: p[0].X, Y: w.cur.Y}, 'H', gg.Point{X: p[0].X})
+		case e.same(p[0].X, w.cur.X):
+			w.shortest(gg.Point{X: w.cur.X, Y: p[0].Y}, 'V', gg.Point{Y: p[0].Y})
+		default:
+			w.shortest(p[0], 'L', p[0])
+		}
+	case QuadOp:
+		r := w.reflection(QuadOp)
+		if r != nil && e.same(p[0].X, r.X) && e.same(p[0].Y, r.Y) {
+			w.shortest(p[1], 'T', p[1])
+			w.ctrl = *r
+		} else {
+			from := w.cur
+			w.shortest(p[1], 'Q', p[0], p[1])
+			w.ctrl = w.decoded(from, p[0])
+		}
+	case CubicOp:
+		r := w.reflection(CubicOp)
+		from := w.cur
+		if r != nil && e.same(p[0].X, r.X) && e.same(p[0].Y, r.Y) {
+			w.shortest(p[2], 'S', p[1], p[2])
+		} else {
+			w.shortest(p[2], 'C', p[0], p[1], p[2])
+		}
+		w.ctrl = w.decoded(from, p[1])
+	case ArcOp:
+		w.arc(s, p[0])
+	case CloseOp:
+		w.command('z')
+		w.cur = w.start
+	}
+	w.op = s.Op
+}
+
+// absolute writes s in the plain form: an absolute command with every
+// number separated by a space.
+func (w *pathWriter) absolute(s Segment) {
+	if w.b.Len() > 0 {
+		w.b.WriteByte(' ')
+	}
+	num := func(vs ...float64) {
+		for _, v := range vs {
+			w.b.WriteByte(' ')
+			w.b.WriteString(w.enc.format(v))
+		}
+	}
+	switch s.Op {
+	case MoveOp:
+		w.b.WriteByte('M')
+		num(s.P[0].X, s.P[0].Y)
+	case LineOp:
+		w.b.WriteByte('L')
+		num(s.P[0].X, s.P[0].Y)
+	case QuadOp:
+		w.b.WriteByte('Q')
+		num(s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y)
+	case CubicOp:
+		w.b.WriteByte('C')
+		num(s.P[0].X, s.P[0].Y, s.P[1].X, s.P[1].Y, s.P[2].X, s.P[2].Y)
+	case ArcOp:
+		w.b.WriteByte('A')
+		rx, ry, rot, large, sweep := s.Arc.endpointParams()
+		num(rx, ry, rot, large, sweep, s.P[0].X, s.P[0].Y)
+	case CloseOp:
+		w.b.WriteByte('Z')
+	}
+}
+
+// shortest writes the command cmd with the points pts, in absolute or
+// relative coordinates, whichever is shorter, and moves the current point
+// to end. For H and V only the X or Y of pts is written.
+func (w *pathWriter) shortest(end gg.Point, cmd byte, pts ...gg.Point) {
+	abs := w.numbers(cmd, gg.Point{}, pts)
+	rel := w.numbers(cmd, w.cur, pts)
+	if len(strings.Join(rel, " ")) < len(strings.Join(abs, " ")) {
+		w.command(cmd + 'a' - 'A')
+		w.write(rel...)
+		w.cur = w.decoded(w.cur, end)
+		return
+	}
+	w.command(cmd)
+	w.write(abs...)
+	w.cur = end
+}
+
+// numbers formats pts relative to origin for the command cmd.
+func (w *pathWriter) numbers(cmd byte, origin gg.Point, pts []gg.Point) []string {
+	var out []string
+	for _, p := range pts {
+		switch cmd {
+		case 'H':
+			out = append(out, w.enc.format(p.X-origin.X))
+		case 'V':
+			out = append(out, w.enc.format(p.Y-origin.Y))
+		default:
+			out = append(out, w.enc.format(p.X-origin.X), w.enc.format(p.Y-origin.Y))
+		}
+	}
+	return out
+}
+
+// decoded returns where a parser ends up reading p, written relative to
+// from.
+func (w *pathWriter) decoded(from, p gg.Point) gg.Point {
+	if w.cmd >= 'a' {
+		return gg.Point{X: from.X + w.enc.round(p.X-from.X), Y: from.Y + w.enc.round(p.Y-from.Y)}
+	}
+	return p
+}
+
+// reflection returns the control point implied by S or T, if the previous
+// segment makes the shorthand for a segment of kind op possible.
+func (w *pathWriter) reflection(op SegmentOp) *gg.Point {
+	if w.op != op {
+		return nil
+	}
+	return &gg.Point{X: 2*w.cur.X - w.ctrl.X, Y: 2*w.cur.Y - w.ctrl.Y}
+}
+
+// arc writes the arc segment s ending at end.
+func (w *pathWriter) arc(s Segment, end gg.Point) {
+	e := w.enc
+	rx, ry, rot, large, sweep := s.Arc.endpointParams()
+	params := []string{e.format(rx), e.format(ry), e.format(rot)}
+	abs := w.numbers('A', gg.Point{}, []gg.Point{end})
+	rel := w.numbers('A', w.cur, []gg.Point{end})
+	cmd, xy := byte('A'), abs
+	if len(strings.Join(rel, " ")) < len(strings.Join(abs, " ")) {
+		cmd, xy = 'a', rel
+	}
+	w.command(cmd)
+	w.write(params...)
+	w.writeFlag(large)
+	w.writeFlag(sweep)
+	w.write(xy...)
+	if cmd == 'a' {
+		w.cur = w.decoded(w.cur, end)
+	} else {
+		w.cur = end
+	}
+}
+
+// command writes the command letter cmd, unless it is implied by the
+// previous one.
+func (w *pathWriter) command(cmd byte) {
+	implied := cmd == w.cmd && cmd != 'M' && cmd != 'm' && cmd != 'z'
+	implied = implied || (w.cmd == 'M' && cmd == 'L') || (w.cmd == 'm' && cmd == 'l')
+	w.cmd = cmd
+	if implied {
+		return
+	}
+	w.b.WriteByte(cmd)
+	w.last, w.flag = "", false
+}
+
+// write writes numbers, with only the separators the grammar needs.
+func (w *pathWriter) write(nums ...string) {
+	for _, n := range nums {
+		if w.last != "" || w.flag {
+			sep := !w.flag && n[0] != '-' && !(n[0] == '.' && strings.Contains(w.last, "."))
+			if sep {
+				w.b.WriteByte(' ')
+			}
+		}
+		w.b.WriteString(n)
+		w.last, w.flag = n, false
+	}
+}
+
+// writeFlag writes an arc flag, which needs no separator after it.
+func (w *pathWriter) writeFlag(f float64) {
+	w.write(strconv.Itoa(int(f)))
+	w.flag = true
+}
+
+// format formats v with the encoder's precision.
+func (e *PathEncoder) format(v float64) string {
+	s := strconv.FormatFloat(v, 'f', e.Precision, 64)
+	if e.Precision > 0 {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	if e.Compact {
+		if strings.HasPrefix(s, "0.") {
+			s = s[1:]
+		} else if strings.HasPrefix(s, "-0.") {
+			s = "-" + s[2:]
+		}
+	}
+	return s
+}
+
+// round rounds v to the encoder's precision.
+func (e *PathEncoder) round(v float64) float64 {
+	r, _ := strconv.ParseFloat(e.format(v), 64)
+	return r
+}
+
+// same reports whether a and b are written the same.
+func (e *PathEncoder) same(a, b float64) bool {
+	return e.format(a) == e.format(b)
+}
@@ -0,0 +1,149 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// maxTileSize bounds the width and height in pixels of a rendered pattern
+// tile. Larger tiles are drawn at a lower resolution.
+const maxTileSize = 4096
+
+// tilePattern returns a pattern painting the <pattern> element e for a
+// shape with the bounding box b, with its alpha multiplied by opacity. The
+// tile is drawn once at the resolution of the device pixels. It returns
+// nil if the pattern paints nothing.
+func (r *renderer) tilePattern(e *Element, b *boundsSink, opacity float64) (gg.Pattern, error) {
+	chain := []*Element{e}
+	for ref, _ := href(e); ref != ""; {
+		next, err := r.doc.lookupRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		if next.Name != "pattern" {
+			break
+		}
+		for _, c := range chain {
+			if c == next {
+				return nil, fmt.Errorf("circular reference to %q", ref)
+			}
+		}
+		chain = append(chain, next)
+		ref, _ = href(next)
+	}
+	attr := func(name, def string) string {
+		for _, e := range chain {
+			if v, ok := e.LookupAttr(name); ok {
+				return strings.TrimSpace(v)
+			}
+		}
+		return def
+	}
+	content := chain[0]
+	for _, e := range chain {
+		if len(e.Children) > 0 {
+			content = e
+			break
+		}
+	}
+
+	units := attr("patternUnits", "objectBoundingBox")
+	w, h := 1.0, 1.0
+	if units != "objectBoundingBox" {
+		w, h = r.doc.ViewBox.W, r.doc.ViewBox.H
+	}
+	var f [4]float64
+	for i, a := range [4]struct {
+		name string
+		size float64
+	}{{"x", w}, {"y", h}, {"width", w}, {"height", h}} {
+		var err error
+		if f[i], err = parseLength(attr(a.name, "0"), a.size); err != nil {
+			return nil, fmt.Errorf("%s: %w", a.name, err)
+		}
+	}
+	um := unitsMatrix(units, b)
+	x, y := um.TransformPoint(f[0], f[1])
+	tw, th := f[2]*um.XX, f[3]*um.YY
+	if tw <= 0 || th <= 0 {
+		return nil, nil
+	}
+
+	// the tile's contents are drawn with their origin at its corner
+	contents := gg.Identity()
+	if v := attr("viewBox", ""); v != "" {
+		vb, err := parseFloats(v)
+		if err != nil || len(vb) != 4 {
+			return nil, fmt.Errorf("%w: viewBox %q", ErrSyntax, v)
+		}
+		if vb[2] <= 0 || vb[3] <= 0 {
+			return nil, nil
+		}
+		contents = viewBoxTransform(ViewBox{vb[0], vb[1], vb[2], vb[3]}, tw, th, attr("preserveAspectRatio", ""))
+	} else {
+		contents = unitsMatrix(attr("patternContentUnits", "userSpaceOnUse"), b)
+		contents.X0, contents.Y0 = 0, 0
+	}
+	pt := gg.Identity()
+	if t := attr("patternTransform", ""); t != "" {
+		var err error
+		if pt, err = parseTransform(t); err != nil {
+			return nil, err
+		}
+	}
+	m := gg.Translate(x, y).Multiply(pt).Multiply(currentMatrix(r.dc))
+
+	// size the tile image by the length of its sides on the device
+	pw := int(clamp(math.Ceil(tw*math.Hypot(m.XX, m.YX)-1e-9), 1, maxTileSize))
+	ph := int(clamp(math.Ceil(th*math.Hypot(m.XY, m.YY)-1e-9), 1, maxTileSize))
+	sx, sy := float64(pw)/tw, float64(ph)/th
+
+	s, err := r.doc.rootStyle().resolve(content)
+	if err != nil {
+		return nil, err
+	}
+	dc := gg.NewContext(pw, ph)
+	applyMatrix(dc, contents.Multiply(gg.Scale(sx, sy)))
+	if err := r.drawDef(e, dc, func() error { return r.drawChildren(content, s) }); err != nil {
+		return nil, err
+	}
+	return &tiledPattern{
+		tile:  dc.Image().(*image.RGBA),
+		inv:   invertMatrix(gg.Scale(1/sx, 1/sy).Multiply(m)),
+		alpha: opacity,
+	}, nil
+}
+
+// tiledPattern is a gg.Pattern that repeats a tile image over the device
+// pixels of a context.
+type tiledPattern struct {
+	tile  *image.RGBA
+	inv   gg.Matrix // from device pixels to tile pixels
+	alpha float64
+}
+
+func (p *tiledPattern) ColorAt(x, y int) color.Color {
+	u, v := p.inv.TransformPoint(float64(x)+0.5, float64(y)+0.5)
+	w, h := p.tile.Rect.Dx(), p.tile.Rect.Dy()
+	i := int(math.Floor(u)) % w
+	if i < 0 {
+		i += w
+	}
+	j := int(math.Floor(v)) % h
+	if j < 0 {
+		j += h
+	}
+	c := p.tile.RGBAAt(i, j)
+	if p.alpha < 1 {
+		c.R = uint8(float64(c.R)*p.alpha + 0.5)
+		c.G = uint8(float64(c.G)*p.alpha + 0.5)
+		c.B = uint8(float64(c.B)*p.alpha + 0.5)
+		c.A = uint8(float64(c.A)*p.alpha + 0.5)
+	}
+	return c
+}
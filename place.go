@@ -0,0 +1,105 @@
+package svgg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/fogleman/gg"
+)
+
+// DrawDocumentAt draws doc to dc with its top-left corner at x, y in dc's
+// current coordinate system, scaled by scale, rotated by rotation radians
+// about that corner, and with its alpha multiplied by alpha. It restores
+// dc's matrix afterwards, so several documents, such as a map, its markers
+// and a legend, can be layered into one scene.
+func DrawDocumentAt(dc *gg.Context, doc *Document, x, y, scale, rotation, alpha float64) error {
+	return drawPlaced(dc, doc, x, y, 0, 0, scale, rotation, alpha)
+}
+
+// Anchor is a point of a document's box, given as fractions of its width
+// and height from the top-left corner.
+type Anchor struct {
+	X, Y float64
+
+	// Content measures the box from the bounds of the document's drawn
+	// geometry, as given by ViewBounds, rather than its width and height,
+	// so that the tip of a map pin lands on its point even when the
+	// document has padding around it.
+	Content bool
+}
+
+// Anchors at the corners, edge midpoints and center of a document. A
+// document placed by AnchorBottom sits on its point, as a map pin or a
+// label on a baseline does.
+var (
+	AnchorTopLeft     = Anchor{X: 0, Y: 0}
+	AnchorTop         = Anchor{X: 0.5, Y: 0}
+	AnchorTopRight    = Anchor{X: 1, Y: 0}
+	AnchorLeft        = Anchor{X: 0, Y: 0.5}
+	AnchorCenter      = Anchor{X: 0.5, Y: 0.5}
+	AnchorRight       = Anchor{X: 1, Y: 0.5}
+	AnchorBottomLeft  = Anchor{X: 0, Y: 1}
+	AnchorBottom      = Anchor{X: 0.5, Y: 1}
+	AnchorBottomRight = Anchor{X: 1, Y: 1}
+)
+
+// AnchorPoint returns the point a of doc in the coordinate system Draw
+// draws into, where the document spans 0, 0 to its width and height.
+func (doc *Document) AnchorPoint(a Anchor) (x, y float64, err error) {
+	if !a.Content {
+		return a.X * doc.Width, a.Y * doc.Height, nil
+	}
+	x0, y0, x1, y1, err := doc.ViewBounds()
+	if err != nil {
+		return 0, 0, err
+	}
+	vb := viewBoxTransform(doc.ViewBox, doc.Width, doc.Height, doc.Root.Attr("preserveAspectRatio"))
+	x, y = vb.TransformPoint(x0+a.X*(x1-x0), y0+a.Y*(y1-y0))
+	return x, y, nil
+}
+
+// DrawDocumentAnchored draws doc to dc like DrawDocumentAt, but with its
+// anchor point, rather than its top-left corner, at x, y, and rotated
+// about it.
+func DrawDocumentAnchored(dc *gg.Context, doc *Document, x, y float64, anchor Anchor, scale, rotation, alpha float64) error {
+	ax, ay, err := doc.AnchorPoint(anchor)
+	if err != nil {
+		return err
+	}
+	return drawPlaced(dc, doc, x, y, ax, ay, scale, rotation, alpha)
+}
+
+// drawPlaced draws doc with its point ax, ay at x, y, scaled and rotated
+// about it.
+func drawPlaced(dc *gg.Context, doc *Document, x, y, ax, ay, scale, rotation, alpha float64) error {
+	return drawWithAlpha(dc, alpha, func(dc *gg.Context) error {
+		dc.Push()
+		defer dc.Pop()
+		dc.Translate(x, y)
+		dc.Rotate(rotation)
+		dc.Scale(scale, scale)
+		dc.Translate(-ax, -ay)
+		return doc.Draw(dc)
+	})
+}
+
+// drawWithAlpha calls fn to draw onto dc with alpha multiplied by alpha.
+// Translucent drawing is done on a layer the size of dc, with dc's matrix,
+// which is then composited onto dc ignoring its clip.
+func drawWithAlpha(dc *gg.Context, alpha float64, fn func(dc *gg.Context) error) error {
+	if alpha <= 0 {
+		return nil
+	}
+	if alpha >= 1 {
+		return fn(dc)
+	}
+	layer := gg.NewContext(dc.Width(), dc.Height())
+	applyMatrix(layer, currentMatrix(dc))
+	err := fn(layer)
+	if dst, ok := dc.Image().(*image.RGBA); ok {
+		mask := image.NewUniform(color.Alpha{uint8(alpha*255 + 0.5)})
+		draw.DrawMask(dst, dst.Bounds(), layer.Image(), image.Point{}, mask, image.Point{}, draw.Over)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package svgg
+
+import (
+	"github.com/fogleman/gg"
+)
+
+// placeholderSpacing is the distance in output pixels between the hatch
+// lines of a placeholder.
+const placeholderSpacing = 6
+
+// drawPlaceholder draws the unsupported element e as a hatched gray box
+// over its bounds, labelled with its name if the options ask for it. The
+// bounds are those of its x, y, width and height attributes, or else of
+// the shapes inside it; an element with neither is not drawn.
+func (r *renderer) drawPlaceholder(e *Element, parent style) {
+	s, err := parent.resolve(e)
+	if err != nil || !s.display {
+		return
+	}
+	m := gg.Identity()
+	if tr := e.Attr("transform"); tr != "" {
+		if m, err = parseTransform(tr); err != nil {
+			return
+		}
+	}
+	b := &boundsSink{}
+	placeholderBounds(e, &transformSink{sink: b, m: m})
+	w, h := b.x1-b.x0, b.y1-b.y0
+	if !b.started || w <= 0 || h <= 0 {
+		return
+	}
+
+	dc := r.dc
+	dc.Push()
+	defer dc.Pop()
+	defer setClip(dc, r.clip)
+	px := r.pixel / matrixScale(currentMatrix(dc))
+	dc.DrawRectangle(b.x0, b.y0, w, h)
+	dc.SetRGBA(0.5, 0.5, 0.5, 0.25)
+	dc.FillPreserve()
+	dc.SetRGBA(0.4, 0.4, 0.4, 0.8)
+	dc.SetLineWidth(px)
+	dc.StrokePreserve()
+	dc.Clip()
+	gap := placeholderSpacing * px
+	for x := b.x0 - h; x < b.x1; x += gap {
+		dc.MoveTo(x, b.y1)
+		dc.LineTo(x+h, b.y0)
+	}
+	dc.Stroke()
+
+	if r.opts.PlaceholderLabels {
+		// the label is drawn upright at the output resolution with the
+		// context's font, whatever the transform
+		x, y := dc.TransformPoint(b.x0, b.y0)
+		dc.Identity()
+		dc.Translate(x, y)
+		dc.Scale(r.pixel, r.pixel)
+		label := "<" + e.Name + ">"
+		if id := e.Attr("id"); id != "" {
+			label += " #" + id
+		}
+		dc.SetRGB(0.2, 0.2, 0.2)
+		dc.DrawStringAnchored(label, 3, 3, 0, 1)
+	}
+}
+
+// placeholderBounds sends the outline of the unsupported element e to t.
+// An element with a width and height, such as <foreignObject>, is the
+// rectangle they give; any other is the union of its children.
+func placeholderBounds(e *Element, t *transformSink) {
+	_, hasW := e.LookupAttr("width")
+	_, hasH := e.LookupAttr("height")
+	if hasW && hasH {
+		if f, err := floatAttrs(e, "x", "y", "width", "height"); err == nil {
+			x, y, w, h := f[0], f[1], f[2], f[3]
+			t.MoveTo(x, y)
+			t.LineTo(x+w, y)
+			t.LineTo(x+w, y+h)
+			t.LineTo(x, y+h)
+			t.ClosePath()
+			return
+		}
+	}
+	m := t.m
+	for _, c := range e.Children {
+		t.m = m
+		if tr, err := parseTransform(c.Attr("transform")); err == nil {
+			t.m = tr.Multiply(m)
+		}
+		if _, ok := elementAttrs[c.Name]; ok {
+			elementBounds(c, t)
+		} else {
+			placeholderBounds(c, t)
+		}
+	}
+	t.m = m
+}
@@ -0,0 +1,140 @@
+package svgg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	// image formats that may be embedded in <image> elements
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// ErrExternalRef is returned when a document references an external
+// resource that the configured policy does not allow.
+var ErrExternalRef = errors.New("external reference")
+
+// A Resolver fetches resources referenced by URL from a document, such as
+// the targets of <image> elements. svgg never fetches anything itself: only
+// data: URLs are read unless a Resolver is installed on the Document.
+type Resolver interface {
+	Resolve(href string) (io.ReadCloser, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(href string) (io.ReadCloser, error)
+
+// Resolve calls f(href).
+func (f ResolverFunc) Resolve(href string) (io.ReadCloser, error) {
+	return f(href)
+}
+
+// RefPolicy sets how the Decoder treats references to external resources.
+type RefPolicy uint8
+
+const (
+	// KeepRefs leaves external references in the document. They are only
+	// followed if a Resolver is installed when drawing.
+	KeepRefs RefPolicy = iota
+	// StripRefs removes external references from the document and records
+	// a warning for each one in Document.Warnings.
+	StripRefs
+	// DenyRefs makes Decode fail with ErrExternalRef.
+	DenyRefs
+)
+
+// isExternalRef reports whether href points outside the document.
+func isExternalRef(href string) bool {
+	href = strings.TrimSpace(href)
+	return href != "" && !strings.HasPrefix(href, "#") && !strings.HasPrefix(href, "data:")
+}
+
+// checkRefs applies the decoder's RefPolicy to the href attributes of e.
+func (d *Decoder) checkRefs(e *Element) error {
+	if d.ExternalRefs == KeepRefs {
+		return nil
+	}
+	attrs := e.Attrs[:0]
+	for _, a := range e.Attrs {
+		if a.Name.Local == "href" && isExternalRef(a.Value) {
+			if d.ExternalRefs == DenyRefs {
+				return fmt.Errorf("%w %q", ErrExternalRef, a.Value)
+			}
+			d.warnings = append(d.warnings, fmt.Errorf("svgg: <%s>: stripped %w %q", e.Name, ErrExternalRef, a.Value))
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	e.Attrs = attrs
+	return nil
+}
+
+// openRef opens the resource at href, decoding data: URLs directly and
+// passing anything else to resolver.
+func openRef(href string, resolver Resolver) (io.ReadCloser, error) {
+	href = strings.TrimSpace(href)
+	if strings.HasPrefix(href, "data:") {
+		data, err := decodeDataURL(href)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	if resolver == nil {
+		return nil, fmt.Errorf("%w %q: no Resolver installed", ErrExternalRef, href)
+	}
+	return resolver.Resolve(href)
+}
+
+// decodeDataURL returns the payload of a data: URL.
+func decodeDataURL(href string) ([]byte, error) {
+	comma := strings.IndexByte(href, ',')
+	if comma < 0 {
+		return nil, fmt.Errorf("svgg: malformed data URL")
+	}
+	meta, payload := href[len("data:"):comma], href[comma+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		// base64 payloads in SVG files are often wrapped across lines
+		payload = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+				return -1
+			}
+			return r
+		}, payload)
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	s, err := url.PathUnescape(payload)
+	return []byte(s), err
+}
+
+// loadImage decodes the raster image referenced by href, failing with
+// ErrLimitExceeded before decoding it if it has more than maxPixels pixels.
+// A maxPixels of zero is unlimited.
+func loadImage(href string, resolver Resolver, maxPixels int) (image.Image, error) {
+	rc, err := openRef(href, resolver)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var r io.Reader = rc
+	if maxPixels > 0 {
+		// the header read for the size is replayed to the decoder
+		var head bytes.Buffer
+		cfg, _, err := image.DecodeConfig(io.TeeReader(rc, &head))
+		if err != nil {
+			return nil, err
+		}
+		if int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+			return nil, fmt.Errorf("%w: image %dx%d has more than %d pixels", ErrLimitExceeded, cfg.Width, cfg.Height, maxPixels)
+		}
+		r = io.MultiReader(&head, rc)
+	}
+	im, _, err := image.Decode(r)
+	return im, err
+}
@@ -0,0 +1,150 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Ring is a closed polygon flattened from a subpath, for handing geometry
+// to mapping and geometry libraries.
+type Ring struct {
+	// Points are the vertices of the ring, with the first repeated at the
+	// end.
+	Points []gg.Point
+
+	// Hole reports whether the area just inside the ring is left unfilled
+	// by the fill rule, making the ring the edge of a hole.
+	Hole bool
+
+	// Parent is the index of the smallest ring enclosing this one, or -1.
+	// Holes and the rings around them can be grouped into polygons by it.
+	Parent int
+}
+
+// PathRings compiles the path data d and returns its rings, flattened
+// within tol, with holes classified by the fill rule.
+func PathRings(d string, rule gg.FillRule, tol float64) ([]Ring, error) {
+	c, err := Compile(d)
+	if err != nil {
+		return nil, err
+	}
+	return c.Rings(rule, tol), nil
+}
+
+// Rings returns the subpaths of the path as closed rings, flattened within
+// tol, with holes classified by the fill rule. Open subpaths are closed as
+// they are when filling, and subpaths enclosing no area are left out. A
+// tol of zero or less uses DefaultTolerance.
+func (c *CompiledPath) Rings(rule gg.FillRule, tol float64) []Ring {
+	if tol <= 0 {
+		tol = DefaultTolerance
+	}
+	var polys [][]gg.Point
+	var areas []float64
+	for _, sub := range c.subpaths(tol) {
+		if a := signedArea(sub.pts); len(sub.pts) > 2 && a != 0 {
+			polys = append(polys, sub.pts)
+			areas = append(areas, a)
+		}
+	}
+	rings := make([]Ring, len(polys))
+	for i, pts := range polys {
+		p := insidePoint(pts, areas[i])
+		winding, parent := 0, -1
+		for j, other := range polys {
+			w := polygonWinding(other, p)
+			if w == 0 {
+				continue
+			}
+			winding += w
+			if j != i && (parent < 0 || math.Abs(areas[j]) < math.Abs(areas[parent])) {
+				parent = j
+			}
+		}
+		filled := winding != 0
+		if rule == gg.FillRuleEvenOdd {
+			filled = winding%2 != 0
+		}
+		rings[i] = Ring{
+			Points: append(pts[:len(pts):len(pts)], pts[0]),
+			Hole:   !filled,
+			Parent: parent,
+		}
+	}
+	return rings
+}
+
+// insidePoint returns a point just inside the polygon pts, whose signed
+// area is area, next to the middle of its longest edge.
+func insidePoint(pts []gg.Point, area float64) gg.Point {
+	var a, b gg.Point
+	longest := -1.0
+	for i, p := range pts {
+		q := pts[(i+1)%len(pts)]
+		if l := p.Distance(q); l > longest {
+			a, b, longest = p, q, l
+		}
+	}
+	// step off the edge by a small fraction of the polygon's size, to the
+	// right of it for clockwise polygons and to the left otherwise
+	d := math.Sqrt(math.Abs(area)) * 1e-6
+	if area < 0 {
+		d = -d
+	}
+	nx, ny := -(b.Y-a.Y)/longest, (b.X-a.X)/longest
+	return gg.Point{X: (a.X+b.X)/2 + nx*d, Y: (a.Y+b.Y)/2 + ny*d}
+}
+
+// CompileRings draws rings of coordinates, such as the rings of GeoJSON
+// polygons or the coordinates of line strings, as CompilePath draws the
+// equivalent path data, so that features can be mixed with SVG paths on
+// one context without formatting them as strings. Each ring is a subpath
+// in the context's current coordinate system. A ring whose last point
+// repeats its first is closed, and any other is left open. Epsilon,
+// PixelSnap, Tolerance, ChunkSize, Deadline and Stats apply as they do to
+// CompilePath.
+func (p *Parser) CompileRings(rings [][][2]float64) error {
+	p.init()
+	segs := 0
+	for _, ring := range rings {
+		if len(ring) == 0 {
+			continue
+		}
+		n := len(ring)
+		closed := n > 2 && ring[0] == ring[n-1]
+		if closed {
+			n--
+		}
+		p.flushSubpath()
+		p.Stats.addCommand('M', 2)
+		p.pathStartX, p.pathStartY = ring[0][0], ring[0][1]
+		p.inPath = true
+		p.moveTo(ring[0][0], ring[0][1])
+		if n > 1 {
+			p.Stats.addCommand('L', 2*(n-1))
+		}
+		for _, pt := range ring[1:n] {
+			if segs++; segs%deadlineCheckInterval == 0 && p.pastDeadline() {
+				return ErrDeadline
+			}
+			p.lineTo(pt[0], pt[1])
+		}
+		p.flushPending()
+		p.placeX, p.placeY = ring[n-1][0], ring[n-1][1]
+		if closed {
+			p.Stats.addCommand('Z', 0)
+			if p.split {
+				// the subpath start was flushed, so close it by hand
+				p.drawLine(p.pathStartX, p.pathStartY)
+			} else {
+				p.sink.ClosePath()
+				p.lastX, p.lastY = p.pathStartX, p.pathStartY
+			}
+			p.placeX, p.placeY = p.pathStartX, p.pathStartY
+			p.inPath = false
+		}
+	}
+	p.endClip()
+	return nil
+}
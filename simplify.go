@@ -0,0 +1,102 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Simplify returns the path with vertices removed by the Douglas-Peucker
+// algorithm, so that no removed vertex was more than epsilon from the
+// simplified path. It is meant for detailed geographic paths drawn at
+// small scales. Runs of straight lines are simplified; curves and arcs are
+// kept as they are, and so are the ends of every run.
+func (c *CompiledPath) Simplify(epsilon float64) *CompiledPath {
+	out := &CompiledPath{}
+	// the current run of straight lines, from where the path stands
+	run := []gg.Point{{}}
+	flush := func() {
+		for _, p := range simplifyRun(run, epsilon)[1:] {
+			out.LineTo(p.X, p.Y)
+		}
+		run = run[:0]
+	}
+	for _, s := range c.Segments {
+		switch s.Op {
+		case LineOp:
+			run = append(run, s.P[0])
+			continue
+		case CloseOp:
+			// the closing line takes part, but ClosePath draws it
+			run = append(run, s.P[0])
+			kept := simplifyRun(run, epsilon)
+			for _, p := range kept[1 : len(kept)-1] {
+				out.LineTo(p.X, p.Y)
+			}
+			run = run[:0]
+		default:
+			if len(run) > 1 {
+				flush()
+			}
+			run = run[:0]
+		}
+		switch s.Op {
+		case MoveOp:
+			out.MoveTo(s.P[0].X, s.P[0].Y)
+		case CloseOp:
+			out.ClosePath()
+		default:
+			out.Segments = append(out.Segments, s)
+		}
+		run = append(run, s.End())
+	}
+	if len(run) > 1 {
+		flush()
+	}
+	return out
+}
+
+// simplifyRun returns the points of the polyline pts that Douglas-Peucker
+// keeps, always including both ends.
+func simplifyRun(pts []gg.Point, epsilon float64) []gg.Point {
+	if len(pts) < 3 {
+		return pts
+	}
+	keep := make([]bool, len(pts))
+	keep[0], keep[len(pts)-1] = true, true
+	// an explicit stack, as long runs are common in map data
+	stack := [][2]int{{0, len(pts) - 1}}
+	for len(stack) > 0 {
+		i, j := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+		far, dmax := -1, epsilon
+		for k := i + 1; k < j; k++ {
+			if d := segmentDistance(pts[k], pts[i], pts[j]); d > dmax {
+				far, dmax = k, d
+			}
+		}
+		if far < 0 {
+			continue
+		}
+		keep[far] = true
+		stack = append(stack, [2]int{i, far}, [2]int{far, j})
+	}
+	var kept []gg.Point
+	for k, p := range pts {
+		if keep[k] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// segmentDistance returns the distance from p to the segment from a to b.
+func segmentDistance(p, a, b gg.Point) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return p.Distance(a)
+	}
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l))
+	return math.Hypot(p.X-a.X-t*dx, p.Y-a.Y-t*dy)
+}
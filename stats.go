@@ -0,0 +1,51 @@
+package svgg
+
+// Stats counts the work done while compiling paths or drawing a document.
+// Attach a Stats to a Parser to collect counts from CompilePath, or use
+// Document.DrawWithStats.
+type Stats struct {
+	Elements  map[string]int // drawn elements by tag name
+	Commands  map[byte]int   // path commands by letter
+	Points    int            // numeric path parameters read
+	Gradients int            // gradient paint servers resolved
+	Warnings  int            // problems skipped rather than returned
+}
+
+func (s *Stats) addElement(name string) {
+	if s == nil {
+		return
+	}
+	if s.Elements == nil {
+		s.Elements = make(map[string]int)
+	}
+	s.Elements[name]++
+}
+
+func (s *Stats) addCommand(k byte, points int) {
+	if s == nil {
+		return
+	}
+	if s.Commands == nil {
+		s.Commands = make(map[byte]int)
+	}
+	s.Commands[k]++
+	s.Points += points
+}
+
+func (s *Stats) addPoints(points int) {
+	if s != nil {
+		s.Points += points
+	}
+}
+
+func (s *Stats) addGradient() {
+	if s != nil {
+		s.Gradients++
+	}
+}
+
+func (s *Stats) addWarning() {
+	if s != nil {
+		s.Warnings++
+	}
+}
@@ -0,0 +1,348 @@
+package svgg
+
+import (
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// StrokeStyle describes how a path is stroked. The zero value strokes with
+// round caps and joins, like gg.
+type StrokeStyle struct {
+	Width float64
+	Cap   gg.LineCap
+	Join  gg.LineJoin
+
+	// MiterLimit, if positive, asks for mitered joins, which gg lacks.
+	// Joins whose miter would be longer than MiterLimit times the width
+	// fall back to Join.
+	MiterLimit float64
+
+	Dashes     []float64
+	DashOffset float64
+}
+
+// StrokePath returns path data for the outline of the path data d stroked
+// with st. Filling the outline with the nonzero rule covers exactly what
+// stroking d would.
+func StrokePath(d string, st StrokeStyle) (string, error) {
+	c, err := Compile(d)
+	if err != nil {
+		return "", err
+	}
+	return c.Stroke(st, 0).String(), nil
+}
+
+// Stroke returns the outline of the path stroked with st, as closed
+// polygons to be filled with the nonzero rule. Curves are flattened within
+// tol first; a tol of zero or less uses DefaultTolerance.
+func (c *CompiledPath) Stroke(st StrokeStyle, tol float64) *CompiledPath {
+	if tol <= 0 {
+		tol = DefaultTolerance
+	}
+	out := &CompiledPath{}
+	if st.Width <= 0 {
+		return out
+	}
+	s := stroker{StrokeStyle: st, hw: st.Width / 2, tol: tol, out: out}
+	for _, sub := range c.subpaths(tol) {
+		for _, pts := range dash(sub.pts, sub.closed, st.Dashes, st.DashOffset) {
+			s.polyline(pts.pts, pts.closed)
+		}
+	}
+	return out
+}
+
+// polyline is a flattened subpath.
+type polyline struct {
+	pts    []gg.Point
+	closed bool
+}
+
+// subpaths flattens the path within tol, keeping track of which subpaths
+// are closed. Consecutive duplicate points are dropped, and a closed
+// subpath does not repeat its first point at the end.
+func (c *CompiledPath) subpaths(tol float64) []polyline {
+	var subs []polyline
+	var cur polyline
+	end := func() {
+		if len(cur.pts) > 0 {
+			subs = append(subs, cur)
+		}
+		cur = polyline{}
+	}
+	c.forEach(func(from gg.Point, s Segment) {
+		if s.Op == MoveOp {
+			end()
+			cur.pts = append(cur.pts, s.P[0])
+			return
+		}
+		if len(cur.pts) == 0 {
+			cur.pts = append(cur.pts, from)
+		}
+		flattenSegment(from, s, tol, func(p gg.Point) {
+			if p != cur.pts[len(cur.pts)-1] {
+				cur.pts = append(cur.pts, p)
+			}
+		})
+		if s.Op == CloseOp {
+			if n := len(cur.pts); n > 1 && cur.pts[n-1] == cur.pts[0] {
+				cur.pts = cur.pts[:n-1]
+			}
+			cur.closed = true
+			end()
+		}
+	})
+	end()
+	return subs
+}
+
+// dash splits a polyline into the pieces drawn by the dash pattern.
+// Without a usable pattern the polyline is returned whole.
+func dash(pts []gg.Point, closed bool, dashes []float64, offset float64) []polyline {
+	total := 0.0
+	for _, d := range dashes {
+		if d < 0 {
+			return []polyline{{pts, closed}}
+		}
+		total += d
+	}
+	if total == 0 {
+		return []polyline{{pts, closed}}
+	}
+	if len(dashes)%2 == 1 {
+		dashes = append(dashes[:len(dashes):len(dashes)], dashes...)
+		total *= 2
+	}
+	if closed && len(pts) > 0 {
+		pts = append(pts[:len(pts):len(pts)], pts[0])
+	}
+	// find where in the pattern the path starts
+	offset = math.Mod(offset, total)
+	if offset < 0 {
+		offset += total
+	}
+	i := 0
+	for offset >= dashes[i] {
+		offset -= dashes[i]
+		i = (i + 1) % len(dashes)
+	}
+	left := dashes[i] - offset
+
+	var out []polyline
+	var cur []gg.Point
+	on := i%2 == 0
+	if on && len(pts) > 0 {
+		cur = []gg.Point{pts[0]}
+	}
+	for k := 1; k < len(pts); k++ {
+		a, b := pts[k-1], pts[k]
+		l := a.Distance(b)
+		pos := 0.0
+		for l-pos > left {
+			pos += left
+			p := a.Interpolate(b, pos/l)
+			if on {
+				out = append(out, polyline{pts: appendPoint(cur, p)})
+				cur = nil
+			} else {
+				cur = []gg.Point{p}
+			}
+			on = !on
+			i = (i + 1) % len(dashes)
+			left = dashes[i]
+		}
+		left -= l - pos
+		if on {
+			cur = appendPoint(cur, b)
+		}
+	}
+	if on && len(cur) > 1 {
+		out = append(out, polyline{pts: cur})
+	}
+	return out
+}
+
+// stroker builds outline polygons for polylines.
+type stroker struct {
+	StrokeStyle
+	hw  float64 // half the stroke width
+	tol float64
+	out *CompiledPath
+
+	// trim cuts inner joins off where the offset segments cross, instead
+	// of doubling back through the vertex
+	trim bool
+}
+
+// polyline adds the outline of pts. An open polyline becomes a single
+// polygon running up its left side and back down its right; a closed one
+// becomes two rings of opposite orientation.
+func (s *stroker) polyline(pts []gg.Point, closed bool) {
+	if len(pts) == 1 || (len(pts) == 2 && closed) {
+		closed = false
+	}
+	if len(pts) == 1 {
+		s.dot(pts[0])
+		return
+	}
+	var ring []gg.Point
+	if closed {
+		ring = s.side(ring, pts, true)
+		s.polygon(ring)
+		ring = s.side(ring[:0], reverse(pts), true)
+		s.polygon(ring)
+		return
+	}
+	ring = s.side(ring, pts, false)
+	ring = s.cap(ring, pts[len(pts)-2], pts[len(pts)-1])
+	ring = s.side(ring, reverse(pts), false)
+	ring = s.cap(ring, pts[1], pts[0])
+	s.polygon(ring)
+}
+
+// side appends the offset of pts on their left, with joins at the inner
+// vertices, and at the first vertex too if the polyline is closed.
+func (s *stroker) side(ring []gg.Point, pts []gg.Point, closed bool) []gg.Point {
+	n := len(pts)
+	for k := 0; k < n; k++ {
+		if k == n-1 && !closed {
+			ring = append(ring, add(pts[k], s.normal(pts[k-1], pts[k])))
+			break
+		}
+		next := pts[(k+1)%n]
+		if k == 0 && !closed {
+			ring = append(ring, add(pts[k], s.normal(pts[k], next)))
+			continue
+		}
+		prev := pts[(k+n-1)%n]
+		ring = s.join(ring, prev, pts[k], next)
+	}
+	return ring
+}
+
+// normal returns the left normal of the segment from a to b, scaled to
+// half the stroke width.
+func (s *stroker) normal(a, b gg.Point) gg.Point {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	l := math.Hypot(dx, dy)
+	return gg.Point{X: dy / l * s.hw, Y: -dx / l * s.hw}
+}
+
+// join appends the left side of the join at v between the segments from
+// prev and to next.
+func (s *stroker) join(ring []gg.Point, prev, v, next gg.Point) []gg.Point {
+	a, b := s.normal(prev, v), s.normal(v, next)
+	cross := (v.X-prev.X)*(next.Y-v.Y) - (v.Y-prev.Y)*(next.X-v.X)
+	if cross < 0 {
+		if s.trim {
+			if p, ok := crossing(add(prev, a), add(v, a), add(v, b), add(next, b)); ok {
+				return append(ring, p)
+			}
+		}
+		// the left side is on the inside of the turn; passing through the
+		// vertex keeps the overlap covered under the nonzero rule
+		return append(ring, add(v, a), v, add(v, b))
+	}
+	if cross == 0 && a.X*b.X+a.Y*b.Y > 0 {
+		// straight on
+		return append(ring, add(v, a))
+	}
+	if s.MiterLimit > 0 {
+		cos := (a.X*b.X + a.Y*b.Y) / (s.hw * s.hw)
+		if ratio := 1 / math.Sqrt((1+cos)/2); 1+cos > 0 && ratio <= s.MiterLimit {
+			m := gg.Point{X: a.X + b.X, Y: a.Y + b.Y}
+			ml := math.Hypot(m.X, m.Y)
+			return append(ring, add(v, a), add(v, gg.Point{X: m.X / ml * s.hw * ratio, Y: m.Y / ml * s.hw * ratio}), add(v, b))
+		}
+	}
+	if s.Join == gg.LineJoinRound {
+		return s.roundArc(ring, v, a, b)
+	}
+	return append(ring, add(v, a), add(v, b))
+}
+
+// cap appends the cap at b of the segment from a to b, going from its left
+// side to its right.
+func (s *stroker) cap(ring []gg.Point, a, b gg.Point) []gg.Point {
+	n := s.normal(a, b)
+	switch s.Cap {
+	case gg.LineCapRound:
+		return s.roundArc(ring, b, n, gg.Point{X: -n.X, Y: -n.Y})
+	case gg.LineCapSquare:
+		// the direction of the segment, rotated from the left normal
+		d := gg.Point{X: -n.Y, Y: n.X}
+		return append(ring, add(add(b, n), d), add(add(b, d), gg.Point{X: -n.X, Y: -n.Y}))
+	}
+	return ring
+}
+
+// roundArc appends the points of a circular arc around c from c+a to c+b,
+// turning clockwise in the y-down coordinates of the path.
+func (s *stroker) roundArc(ring []gg.Point, c, a, b gg.Point) []gg.Point {
+	a0 := math.Atan2(a.Y, a.X)
+	a1 := math.Atan2(b.Y, b.X)
+	for a1 < a0 {
+		a1 += 2 * math.Pi
+	}
+	if a1-a0 > 2*math.Pi {
+		a1 -= 2 * math.Pi
+	}
+	arc := Segment{Op: ArcOp, P: [3]gg.Point{add(c, b)}, Arc: Arc{Cx: c.X, Cy: c.Y, Rx: s.hw, Ry: s.hw, Theta1: a0, DTheta: a1 - a0}}
+	ring = append(ring, add(c, a))
+	flattenSegment(add(c, a), arc, s.tol, func(p gg.Point) {
+		ring = append(ring, p)
+	})
+	return ring
+}
+
+// dot adds the outline of a zero-length stroke, which only has caps.
+func (s *stroker) dot(p gg.Point) {
+	var ring []gg.Point
+	switch s.Cap {
+	case gg.LineCapRound:
+		n := gg.Point{X: s.hw}
+		ring = s.roundArc(ring, p, n, gg.Point{X: -s.hw})
+		ring = s.roundArc(ring, p, gg.Point{X: -s.hw}, n)
+	case gg.LineCapSquare:
+		h := s.hw
+		ring = []gg.Point{{X: p.X - h, Y: p.Y - h}, {X: p.X + h, Y: p.Y - h}, {X: p.X + h, Y: p.Y + h}, {X: p.X - h, Y: p.Y + h}}
+	}
+	s.polygon(ring)
+}
+
+// polygon adds ring to the output as a closed subpath.
+func (s *stroker) polygon(ring []gg.Point) {
+	if len(ring) < 3 {
+		return
+	}
+	s.out.MoveTo(ring[0].X, ring[0].Y)
+	for _, p := range ring[1:] {
+		if p != s.out.Segments[len(s.out.Segments)-1].End() {
+			s.out.LineTo(p.X, p.Y)
+		}
+	}
+	s.out.ClosePath()
+}
+
+// appendPoint appends p to pts unless it repeats the last point, as a dash
+// can begin or end right on a vertex.
+func appendPoint(pts []gg.Point, p gg.Point) []gg.Point {
+	if len(pts) > 0 && pts[len(pts)-1] == p {
+		return pts
+	}
+	return append(pts, p)
+}
+
+func add(a, b gg.Point) gg.Point {
+	return gg.Point{X: a.X + b.X, Y: a.Y + b.Y}
+}
+
+// reverse returns a reversed copy of pts.
+func reverse(pts []gg.Point) []gg.Point {
+	r := make([]gg.Point, len(pts))
+	for i, p := range pts {
+		r[len(pts)-1-i] = p
+	}
+	return r
+}
@@ -0,0 +1,513 @@
+package svgg
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// style holds the resolved presentation properties of an element.
+type style struct {
+	fill          color.Color // nil means none
+	stroke        color.Color // nil means none
+	fillServer    string      // paint server IRI, with fill as its fallback
+	strokeServer  string      // paint server IRI, with stroke as its fallback
+	fillOpacity   float64
+	strokeOpacity float64
+	opacity       float64
+	strokeWidth   float64
+	fillRule      gg.FillRule
+	clipRule      gg.FillRule // fill rule of shapes in a clip path
+	lineCap       gg.LineCap
+	lineJoin      gg.LineJoin
+	dashes        []float64
+	markerStart   string // marker IRIs, or "" for none
+	markerMid     string
+	markerEnd     string
+	fontFamily    []string
+	fontSize      float64
+	fontWeight    int
+	italic        bool
+	synthBold     bool // font-synthesis allows synthetic bold
+	synthOblique  bool // and synthetic oblique
+	kerning       bool // font-kerning is not none
+	textAnchor    string
+	baseline      string // dominant-baseline
+	alignBaseline string // alignment-baseline, which is not inherited
+	letterSpacing float64
+	wordSpacing   float64
+	preserveSpace bool // xml:space="preserve"
+	display       bool
+	rendering     string // shape-rendering
+	interpolation string // color-interpolation
+
+	classes map[string]map[string]string // properties set by SetClassStyle
+}
+
+// defaultStyle is the initial style of the root element.
+var defaultStyle = style{
+	fill:          color.Black,
+	fillOpacity:   1,
+	strokeOpacity: 1,
+	opacity:       1,
+	strokeWidth:   1,
+	fillRule:      gg.FillRuleWinding,
+	clipRule:      gg.FillRuleWinding,
+	lineCap:       gg.LineCapButt,
+	lineJoin:      gg.LineJoinRound,
+	display:       true,
+	fontFamily:    []string{"serif"},
+	fontSize:      16,
+	fontWeight:    400,
+	synthBold:     true,
+	synthOblique:  true,
+	kerning:       true,
+	textAnchor:    "start",
+	baseline:      "auto",
+	rendering:     "auto",
+	interpolation: "sRGB",
+}
+
+// styleProperties are the presentation properties understood by the renderer.
+var styleProperties = map[string]bool{
+	"fill":                true,
+	"fill-opacity":        true,
+	"fill-rule":           true,
+	"stroke":              true,
+	"stroke-width":        true,
+	"stroke-opacity":      true,
+	"stroke-linecap":      true,
+	"stroke-linejoin":     true,
+	"stroke-dasharray":    true,
+	"opacity":             true,
+	"display":             true,
+	"stroke-miterlimit":   true, // read, but audited as approximated
+	"shape-rendering":     true,
+	"color-interpolation": true,
+	"stop-color":          true,
+	"stop-opacity":        true,
+	"clip-path":           true,
+	"clip-rule":           true,
+	"marker":              true,
+	"overflow":            true,
+	"font-family":         true,
+	"font-size":           true,
+	"font-weight":         true,
+	"font-style":          true,
+	"font-synthesis":      true,
+	"font-kerning":        true,
+	"text-anchor":         true,
+	"dominant-baseline":   true,
+	"alignment-baseline":  true,
+	"letter-spacing":      true,
+	"word-spacing":        true,
+	"marker-start":        true,
+	"marker-mid":          true,
+	"marker-end":          true,
+	"mask":                true,
+}
+
+// properties returns the presentation properties set on e, with declarations
+// in the style attribute taking precedence over presentation attributes.
+func properties(e *Element) map[string]string {
+	props := make(map[string]string)
+	for _, a := range e.Attrs {
+		if styleProperties[a.Name.Local] {
+			props[a.Name.Local] = strings.TrimSpace(a.Value)
+		}
+	}
+	for _, decl := range strings.Split(e.Attr("style"), ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		props[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return props
+}
+
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resolve returns the style of e given the style s of its parent.
+func (s style) resolve(e *Element) (style, error) {
+	// display and alignment-baseline are not inherited, and group opacity is approximated by
+	// multiplying it into the opacity of every descendant
+	groupOpacity := s.opacity
+	s.opacity = 1
+	s.display = true
+	s.alignBaseline = ""
+	if v, ok := e.LookupAttr("space"); ok {
+		s.preserveSpace = strings.TrimSpace(v) == "preserve"
+	}
+	props := properties(e)
+	if len(s.classes) > 0 {
+		for _, class := range strings.Fields(e.Attr("class")) {
+			for k, v := range s.classes[class] {
+				props[k] = v
+			}
+		}
+	}
+	// lengths in em are relative to the element's own font size
+	if v, ok := props["font-size"]; ok && v != "inherit" {
+		var err error
+		if s.fontSize, err = parseFontSize(v, s.fontSize); err != nil {
+			return s, fmt.Errorf("font-size=%q: %w", v, err)
+		}
+	}
+	// properties are resolved in a fixed order, so that the error reported
+	// for an element with several bad values does not change from run to run
+	for _, k := range sortedKeys(props) {
+		v := props[k]
+		if v == "inherit" {
+			continue
+		}
+		var err error
+		switch k {
+		case "fill":
+			s.fillServer, s.fill, err = parsePaint(v)
+		case "stroke":
+			s.strokeServer, s.stroke, err = parsePaint(v)
+		case "fill-opacity":
+			s.fillOpacity, err = parseOpacity(v)
+		case "stroke-opacity":
+			s.strokeOpacity, err = parseOpacity(v)
+		case "opacity":
+			s.opacity, err = parseOpacity(v)
+		case "stroke-width":
+			s.strokeWidth, err = parseLength(v, 0)
+		case "fill-rule":
+			if v == "evenodd" {
+				s.fillRule = gg.FillRuleEvenOdd
+			} else {
+				s.fillRule = gg.FillRuleWinding
+			}
+		case "clip-rule":
+			if v == "evenodd" {
+				s.clipRule = gg.FillRuleEvenOdd
+			} else {
+				s.clipRule = gg.FillRuleWinding
+			}
+		case "marker", "marker-start", "marker-mid", "marker-end":
+			var ref string
+			if v != "none" {
+				if ref, err = parseURL(v); err != nil {
+					break
+				}
+			}
+			// the shorthand sets the longhands that are not set themselves
+			set := func(name string) bool {
+				_, ok := props[name]
+				return k == name || (k == "marker" && !ok)
+			}
+			if set("marker-start") {
+				s.markerStart = ref
+			}
+			if set("marker-mid") {
+				s.markerMid = ref
+			}
+			if set("marker-end") {
+				s.markerEnd = ref
+			}
+		case "font-family":
+			s.fontFamily = parseFontFamily(v)
+		case "font-weight":
+			s.fontWeight, err = parseFontWeight(v, s.fontWeight)
+		case "font-style":
+			s.italic = v == "italic" || strings.HasPrefix(v, "oblique")
+		case "font-synthesis":
+			s.synthBold, s.synthOblique = false, false
+			for _, f := range strings.Fields(v) {
+				switch f {
+				case "weight":
+					s.synthBold = true
+				case "style":
+					s.synthOblique = true
+				}
+			}
+		case "font-kerning":
+			s.kerning = v != "none"
+		case "text-anchor":
+			s.textAnchor = v
+		case "dominant-baseline":
+			s.baseline = v
+		case "alignment-baseline":
+			s.alignBaseline = v
+		case "letter-spacing", "word-spacing":
+			var f float64
+			if v != "normal" {
+				f, err = parseFontLength(v, s.fontSize)
+			}
+			if k == "letter-spacing" {
+				s.letterSpacing = f
+			} else {
+				s.wordSpacing = f
+			}
+		case "stroke-linecap":
+			switch v {
+			case "round":
+				s.lineCap = gg.LineCapRound
+			case "square":
+				s.lineCap = gg.LineCapSquare
+			default:
+				s.lineCap = gg.LineCapButt
+			}
+		case "stroke-linejoin":
+			if v == "bevel" {
+				s.lineJoin = gg.LineJoinBevel
+			} else {
+				s.lineJoin = gg.LineJoinRound
+			}
+		case "stroke-dasharray":
+			if v == "none" {
+				s.dashes = nil
+			} else {
+				s.dashes, err = parseFloats(v)
+			}
+		case "display":
+			s.display = v != "none"
+		case "shape-rendering":
+			s.rendering = v
+		case "color-interpolation":
+			s.interpolation = v
+		}
+		if err != nil {
+			return s, fmt.Errorf("%s=%q: %w", k, v, err)
+		}
+	}
+	s.opacity *= groupOpacity
+	return s, nil
+}
+
+// namedColors are the basic CSS color keywords.
+var namedColors = map[string]color.NRGBA{
+	"black":   {0, 0, 0, 255},
+	"silver":  {192, 192, 192, 255},
+	"gray":    {128, 128, 128, 255},
+	"grey":    {128, 128, 128, 255},
+	"white":   {255, 255, 255, 255},
+	"maroon":  {128, 0, 0, 255},
+	"red":     {255, 0, 0, 255},
+	"purple":  {128, 0, 128, 255},
+	"fuchsia": {255, 0, 255, 255},
+	"magenta": {255, 0, 255, 255},
+	"green":   {0, 128, 0, 255},
+	"lime":    {0, 255, 0, 255},
+	"olive":   {128, 128, 0, 255},
+	"yellow":  {255, 255, 0, 255},
+	"navy":    {0, 0, 128, 255},
+	"blue":    {0, 0, 255, 255},
+	"teal":    {0, 128, 128, 255},
+	"aqua":    {0, 255, 255, 255},
+	"cyan":    {0, 255, 255, 255},
+	"orange":  {255, 165, 0, 255},
+}
+
+// parsePaint parses an SVG paint value, which is a color or a reference to
+// a paint server such as "url(#fade)", optionally followed by a fallback
+// color for when the server cannot be used.
+func parsePaint(s string) (server string, c color.Color, err error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "url(") {
+		c, err = parseColor(s)
+		return "", c, err
+	}
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return "", nil, fmt.Errorf("%w: invalid paint %q", ErrSyntax, s)
+	}
+	server = strings.Trim(strings.TrimSpace(s[4:end]), `'"`)
+	if fallback := strings.TrimSpace(s[end+1:]); fallback != "" {
+		c, err = parseColor(fallback)
+	}
+	return server, c, err
+}
+
+// parseColor parses an SVG color value. It returns nil for "none".
+func parseColor(s string) (color.Color, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "none" || s == "transparent":
+		return nil, nil
+	case strings.HasPrefix(s, "#"):
+		return parseHexColor(s[1:])
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		return parseRGBColor(s[4 : len(s)-1])
+	case strings.HasPrefix(s, "rgba(") && strings.HasSuffix(s, ")"):
+		return parseRGBColor(s[5 : len(s)-1])
+	}
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: unknown color %q", ErrNotImplemented, s)
+}
+
+// parseHexColor parses the digits of a #rgb, #rgba, #rrggbb or #rrggbbaa
+// color.
+func parseHexColor(h string) (color.Color, error) {
+	if len(h) == 3 || len(h) == 4 {
+		b := make([]byte, 0, 8)
+		for i := range h {
+			b = append(b, h[i], h[i])
+		}
+		h = string(b)
+	}
+	if len(h) == 6 {
+		h += "ff"
+	}
+	if len(h) != 8 {
+		return nil, fmt.Errorf("%w: invalid color #%s", ErrInvalidNumber, h)
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid color #%s", ErrInvalidNumber, h)
+	}
+	return color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// parseRGBColor parses the arguments of an rgb() or rgba() color, with an
+// optional alpha after the red, green and blue.
+func parseRGBColor(s string) (color.Color, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, fmt.Errorf("%w: invalid color rgb(%s)", ErrInvalidNumber, s)
+	}
+	alpha := 1.0
+	if len(parts) == 4 {
+		var err error
+		if alpha, err = parseOpacity(strings.TrimSpace(parts[3])); err != nil {
+			return nil, fmt.Errorf("%w: invalid color rgb(%s)", ErrInvalidNumber, s)
+		}
+		parts = parts[:3]
+	}
+	var c [3]uint8
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		scale := 1.0
+		if strings.HasSuffix(p, "%") {
+			p = strings.TrimSuffix(p, "%")
+			scale = 255.0 / 100
+		}
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid color rgb(%s)", ErrInvalidNumber, s)
+		}
+		c[i] = uint8(clamp(f*scale, 0, 255) + 0.5)
+	}
+	return color.NRGBA{c[0], c[1], c[2], uint8(alpha*255 + 0.5)}, nil
+}
+
+func parseOpacity(s string) (float64, error) {
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSuffix(s, "%")
+		scale = 0.01
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+	}
+	return clamp(f*scale, 0, 1), nil
+}
+
+// withAlpha returns c with its alpha multiplied by a.
+func withAlpha(c color.Color, a float64) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(float64(n.A)*a + 0.5)
+	return n
+}
+
+func clamp(f, lo, hi float64) float64 {
+	if f < lo {
+		return lo
+	}
+	if f > hi {
+		return hi
+	}
+	return f
+}
+
+// fontSizes are the sizes of the absolute font-size keywords.
+var fontSizes = map[string]float64{
+	"xx-small": 9,
+	"x-small":  10,
+	"small":    13,
+	"medium":   16,
+	"large":    18,
+	"x-large":  24,
+	"xx-large": 32,
+}
+
+// parseFontSize parses a font-size value, relative to the font size of the
+// parent, parent.
+func parseFontSize(s string, parent float64) (float64, error) {
+	switch s {
+	case "smaller":
+		return parent / 1.2, nil
+	case "larger":
+		return parent * 1.2, nil
+	}
+	if f, ok := fontSizes[s]; ok {
+		return f, nil
+	}
+	if strings.HasSuffix(s, "%") {
+		return parseLength(s, parent)
+	}
+	return parseFontLength(s, parent)
+}
+
+// parseFontLength parses a length that may be given in em, relative to the
+// font size em, or in pt.
+func parseFontLength(s string, em float64) (float64, error) {
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "em"):
+		s, scale = strings.TrimSuffix(s, "em"), em
+	case strings.HasSuffix(s, "pt"):
+		s, scale = strings.TrimSuffix(s, "pt"), 4.0/3
+	}
+	f, err := parseLength(s, 0)
+	return f * scale, err
+}
+
+// parseFontWeight parses a font-weight value, relative to the weight of
+// the parent, parent.
+func parseFontWeight(s string, parent int) (int, error) {
+	switch s {
+	case "bolder":
+		switch {
+		case parent < 350:
+			return 400, nil
+		case parent < 550:
+			return 700, nil
+		}
+		return 900, nil
+	case "lighter":
+		switch {
+		case parent < 550:
+			return 100, nil
+		case parent < 750:
+			return 400, nil
+		}
+		return 700, nil
+	}
+	if w, ok := fontWeights[s]; ok {
+		return w, nil
+	}
+	w, err := strconv.Atoi(s)
+	if err != nil || w < 1 || w > 1000 {
+		return parent, fmt.Errorf("%w %q", ErrInvalidNumber, s)
+	}
+	return w, nil
+}
@@ -7,8 +7,13 @@ package svgg
 
 import (
 	"errors"
+	"fmt"
+	"image"
+	"io"
 	"log"
-	"unicode"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/fogleman/gg"
 )
@@ -23,12 +28,16 @@ import (
 //ErrorMode sets how the parser reacts to unparsed elements
 type ErrorMode uint8
 
+// Errors returned by the Parser. They are wrapped with additional context,
+// so use errors.Is to test for a particular category.
 var (
-	errParamMismatch  = errors.New("Param mismatch")
-	errCommandUnknown = errors.New("Unknown command")
-	errZeroLengthID   = errors.New("zero length id")
-	errMissingID      = errors.New("cannot find id")
-	errNotImplemented = errors.New("not implemented")
+	ErrParamMismatch  = errors.New("param mismatch")
+	ErrCommandUnknown = errors.New("unknown command")
+	ErrZeroLengthID   = errors.New("zero length id")
+	ErrMissingID      = errors.New("cannot find id")
+	ErrNotImplemented = errors.New("not implemented")
+	ErrInvalidNumber  = errors.New("invalid number")
+	ErrSyntax         = errors.New("syntax error")
 )
 
 const (
@@ -38,12 +47,42 @@ const (
 	WarnErrorMode
 	//StrictErrorMode causes a error when an unparsed SVG element is found
 	StrictErrorMode
+	//CallbackErrorMode passes every error to the Parser's ErrorHandler,
+	// which decides whether to skip the segment or abort
+	CallbackErrorMode
+	//CollectErrorMode skips failing segments, keeps drawing, and returns
+	// every error encountered as an ErrorList once the path is compiled
+	CollectErrorMode
 )
 
+// ErrorHandler is called in CallbackErrorMode with the command letter of the
+// failing segment and the wrapped error. Returning nil skips the segment and
+// continues compiling; the handler may draw a substitute to the context before
+// doing so. Returning a non-nil error aborts CompilePath with that error.
+type ErrorHandler func(cmd byte, err error) error
+
 func reflect(px, py, rx, ry float64) (x, y float64) {
 	return px*2 - rx, py*2 - ry
 }
 
+// PathSink receives the segments of a path as it is compiled. *gg.Context
+// is a PathSink, so paths are normally drawn straight to a context.
+type PathSink interface {
+	MoveTo(x, y float64)
+	LineTo(x, y float64)
+	QuadraticTo(x1, y1, x, y float64)
+	CubicTo(x1, y1, x2, y2, x, y float64)
+	ClosePath()
+}
+
+// ArcSink is implemented by PathSinks that take elliptical arcs exactly.
+// Arcs sent to other sinks are approximated by cubic Béziers. x, y is the
+// end point of the arc.
+type ArcSink interface {
+	PathSink
+	ArcTo(a Arc, x, y float64)
+}
+
 //Parser is used to parse SVG strings into drawing commands
 type Parser struct {
 	placeX, placeY         float64
@@ -53,13 +92,138 @@ type Parser struct {
 	points                 []float64
 	lastKey                uint8
 	ErrorMode              ErrorMode
-	inPath                 bool
-	dc                     *gg.Context
+	ErrorHandler           ErrorHandler
+	Stats                  *Stats
+	Deadline               time.Time // if set, CompilePath stops with ErrDeadline once passed
+
+	// ChunkSize, if positive, bounds the number of segments accumulated in
+	// the context's path. Once it is reached, Flush is called at the start
+	// of the next subpath to paint and clear what has been built so far.
+	// This keeps peak memory flat for paths with hundreds of thousands of
+	// points, at the cost of subpaths no longer interacting: a subpath
+	// flushed on its own cannot cut a hole into another.
+	ChunkSize int
+	Flush     func()
+
+	// SplitSubpaths lets the Parser also flush in the middle of a subpath,
+	// continuing it from the current point afterwards. This bounds memory
+	// for paths made of a single huge subpath, but is only invisible for
+	// unfilled paths; the stroke is joined and dashed per chunk.
+	SplitSubpaths bool
+
+	// Epsilon, if positive, drops the end points of line segments that lie
+	// closer than Epsilon device pixels to the last point drawn. Geographic
+	// linework often has many points per pixel, and skipping them speeds up
+	// rendering without any visible change. The final point of each run of
+	// lines is always drawn.
+	Epsilon float64
+
+	// PixelSnap, if positive, rounds every point drawn to the nearest
+	// multiple of PixelSnap device pixels, so that axis-aligned edges fall
+	// on pixel boundaries and render without antialiasing blur. It is used
+	// for shape-rendering="crispEdges". A fraction such as 1/16 instead
+	// quantizes coordinates to a fine grid, so that edges shared by
+	// adjacent polygons, or by tiles drawn at whole-pixel offsets, round
+	// to exactly the same points.
+	PixelSnap float64
+
+	// Tolerance, if positive, makes the Parser flatten curves and arcs into
+	// lines itself, staying within Tolerance device pixels of the true
+	// curve, instead of leaving curves to gg. Larger values draw faster
+	// with coarser curves, for thumbnails; smaller values give smoother
+	// curves at poster resolutions.
+	Tolerance float64
+
+	// Deterministic, if set, moves every point drawn, after any PixelSnap,
+	// to the middle of the 1/64 pixel cell of gg's fixed-point rasterizer
+	// that it falls in, and flattens curves within Tolerance, or
+	// DefaultTolerance if it is zero, rather than leaving them to gg's
+	// floating-point subdivision. Each point lands in the cell it would
+	// have anyway, but the rounding differences of floating-point math
+	// between platforms, such as fused multiply-adds, no longer reach the
+	// rasterizer, so the same path draws the same pixels everywhere.
+	Deterministic bool
+
+	// ClipRect, if not empty, clips paths to this rectangle in device
+	// pixels, such as the bounds of a map tile, as they are compiled, so
+	// that geometry far outside it never reaches the rasterizer. Curves
+	// crossing its edges are flattened within Tolerance, or within
+	// DefaultTolerance if Tolerance is zero. Subpaths are clipped as
+	// polygons, whose cut edges run along the rectangle, which is right
+	// for filling; ClipLines instead splits them where they leave the
+	// rectangle, for stroking. Either way, a rectangle larger than the
+	// area shown by the stroke width keeps cut edges and line ends out of
+	// sight. Without a context, the rectangle is in the sink's coordinates.
+	ClipRect  image.Rectangle
+	ClipLines bool
+
+	errs    ErrorList
+	inPath  bool
+	emitted int  // segments added to the context since the last flush
+	split   bool // the current subpath was flushed part way through
+	cont    bool // the segment continues one drawn in part, by CompilePathReader
+	pending bool // a line end point was dropped by Epsilon
+	pendX   float64
+	pendY   float64
+	inv     gg.Matrix // device to user space, for PixelSnap
+	tol     float64   // Tolerance in user space
+	lastX   float64   // last point sent to the sink, in user space
+	lastY   float64
+	sink    PathSink
+	dc      *gg.Context // the sink if it is a context, for Epsilon and PixelSnap
+	clip    rectClipper // the sink wrapped in, for ClipRect
 }
 
 func NewParser(dc *gg.Context) *Parser {
-	return &Parser{
-		dc: dc,
+	p := &Parser{}
+	p.setContext(dc)
+	return p
+}
+
+// NewSinkParser returns a Parser that sends compiled paths to sink rather
+// than drawing them. Epsilon and PixelSnap work in device space, so they
+// only apply when sink is a *gg.Context.
+func NewSinkParser(sink PathSink) *Parser {
+	p := &Parser{sink: sink}
+	p.dc, _ = sink.(*gg.Context)
+	return p
+}
+
+// setContext makes dc the Parser's sink.
+func (p *Parser) setContext(dc *gg.Context) {
+	p.dc = dc
+	p.sink = nil
+	if dc != nil {
+		p.sink = dc
+	}
+}
+
+// Reset prepares the Parser to draw to dc as if it had just been returned
+// by NewParser, but keeps its points buffer. This makes Parsers cheap to
+// reuse through a sync.Pool:
+//
+//	var pool = sync.Pool{New: func() interface{} { return svgg.NewParser(nil) }}
+//
+//	p := pool.Get().(*svgg.Parser)
+//	p.Reset(dc)
+//	err := p.CompilePath(d)
+//	pool.Put(p)
+func (p *Parser) Reset(dc *gg.Context) {
+	*p = Parser{
+		points: p.points[0:0],
+	}
+	p.setContext(dc)
+}
+
+// Grow ensures the points buffer can hold the parameters of a segment with
+// n numbers without reallocating. Use it before compiling paths with very
+// long segments, such as a country boundary drawn with a single M command
+// followed by thousands of coordinate pairs.
+func (p *Parser) Grow(n int) {
+	if n > cap(p.points) {
+		points := make([]float64, len(p.points), n)
+		copy(points, p.points)
+		p.points = points
 	}
 }
 
@@ -93,63 +257,50 @@ func (p *Parser) hasSetsOrMore(sz int, rel bool) bool {
 	return true
 }
 
-// ReadFloat reads a floating point value and adds it to the cursor's points slice.
+// ReadFloat reads the floating point values in numStr and adds them to the
+// cursor's points slice. Like in path data, a second decimal point starts
+// a new number, so "1.5.5" reads as 1.5 and 0.5.
 func (p *Parser) ReadFloat(numStr string) error {
-	last := 0
-	isFirst := true
-	for i, n := range numStr {
-		if n == '.' {
-			if isFirst == true {
-				isFirst = false
-				continue
-			}
-			f, err := parseFloat(numStr[last:i], 64)
-			if err != nil {
-				return err
-			}
-			p.points = append(p.points, f)
-			last = i
+	for i := 0; i < len(numStr); {
+		j, err := p.readNumber(numStr, i)
+		if err != nil {
+			return err
 		}
+		i = j
 	}
-	f, err := parseFloat(numStr[last:], 64)
-	if err != nil {
-		return err
-	}
-	p.points = append(p.points, f)
 	return nil
 }
 
 // GetPoints reads a set of floating point values from the SVG format number string,
 // and add them to the cursor's points slice.
 func (p *Parser) GetPoints(dataPoints string) error {
-	lastIndex := -1
 	p.points = p.points[0:0]
-	lr := ' '
-	for i, r := range dataPoints {
-		if unicode.IsNumber(r) == false && r != '.' && !(r == '-' && lr == 'e') && r != 'e' {
-			if lastIndex != -1 {
-				if err := p.ReadFloat(dataPoints[lastIndex:i]); err != nil {
-					return err
-				}
-			}
-			if r == '-' {
-				lastIndex = i
-			} else {
-				lastIndex = -1
-			}
-		} else if lastIndex == -1 {
-			lastIndex = i
+	for i := 0; i < len(dataPoints); {
+		if !isNumberStart(dataPoints[i]) {
+			i++
+			continue
 		}
-		lr = r
-	}
-	if lastIndex != -1 && lastIndex != len(dataPoints) {
-		if err := p.ReadFloat(dataPoints[lastIndex:len(dataPoints)]); err != nil {
+		j, err := p.readNumber(dataPoints, i)
+		if err != nil {
 			return err
 		}
+		i = j
 	}
 	return nil
 }
 
+// readNumber parses the number starting at s[i], appends it to the points
+// slice and returns the index just past it.
+func (p *Parser) readNumber(s string, i int) (int, error) {
+	j := scanNumber(s, i)
+	f, err := strconv.ParseFloat(s[i:j], 64)
+	if err != nil {
+		return j, fmt.Errorf("%w %q", ErrInvalidNumber, s[i:j])
+	}
+	p.points = append(p.points, f)
+	return j, nil
+}
+
 func (p *Parser) reflectControlQuad() {
 	switch p.lastKey {
 	case 'q', 'Q', 'T', 't':
@@ -168,25 +319,41 @@ func (p *Parser) reflectControlCube() {
 	}
 }
 
-// addSeg decodes an SVG seqment string and draws to the context.
-func (p *Parser) addSeg(segString string) error {
-
-	// Parse the string describing the numeric points in SVG format
-	if err := p.GetPoints(segString[1:]); err != nil {
-		return err
-	}
+// addSeg draws the segment with command k and the parameters already
+// read into the points slice to the context.
+func (p *Parser) addSeg(k byte) error {
 	l := len(p.points)
-	k := segString[0]
 	rel := false
+	if p.cont {
+		p.Stats.addPoints(l)
+	} else {
+		p.Stats.addCommand(k, l)
+	}
+	if p.ErrorMode == StrictErrorMode {
+		if err := p.validateParams(k); err != nil {
+			return err
+		}
+	}
+	switch k {
+	case 'l', 'L', 'h', 'H', 'v', 'V':
+	default:
+		p.flushPending()
+	}
 	switch k {
 	case 'z':
 		fallthrough
 	case 'Z':
 		if len(p.points) != 0 {
-			return errParamMismatch
+			return ErrParamMismatch
 		}
 		if p.inPath {
-			// p.Path.Stop(true)
+			if p.split {
+				// the subpath start was flushed, so close it by hand
+				p.drawLine(p.pathStartX, p.pathStartY)
+			} else {
+				p.sink.ClosePath()
+				p.lastX, p.lastY = p.pathStartX, p.pathStartY
+			}
 			p.placeX = p.pathStartX
 			p.placeY = p.pathStartY
 			p.inPath = false
@@ -196,18 +363,16 @@ func (p *Parser) addSeg(segString string) error {
 		fallthrough
 	case 'M':
 		if !p.hasSetsOrMore(2, rel) {
-			return errParamMismatch
+			return ErrParamMismatch
 		}
+		p.flushSubpath()
 		p.pathStartX, p.pathStartY = p.points[0], p.points[1]
 		p.inPath = true
-		// p.Path.Start(fixed.Point26_6{X: fixed.Int26_6((p.pathStartX + p.curX) * 64), Y: fixed.Int26_6((p.pathStartY + p.curY) * 64)})
-		p.dc.MoveTo(p.points[0], p.points[1])
+		p.moveTo(p.points[0], p.points[1])
 
+		// additional pairs are implicit lineto commands
 		for i := 2; i < l-1; i += 2 {
-			p.dc.LineTo(p.points[i], p.points[i+1])
-			// p.Path.Line(fixed.Point26_6{
-			// 	X: fixed.Int26_6((p.points[i] + p.curX) * 64),
-			// 	Y: fixed.Int26_6((p.points[i+1] + p.curY) * 64)})
+			p.lineTo(p.points[i], p.points[i+1])
 		}
 		p.placeX = p.points[l-2]
 		p.placeY = p.points[l-1]
@@ -215,174 +380,391 @@ func (p *Parser) addSeg(segString string) error {
 		rel = true
 		fallthrough
 	case 'L':
-		return errNotImplemented
-
-		// if !p.hasSetsOrMore(2, rel) {
-		// 	return errParamMismatch
-		// }
-		// for i := 0; i < l-1; i += 2 {
-		// 	// p.Path.Line(fixed.Point26_6{
-		// 	// 	X: fixed.Int26_6((p.points[i] + p.curX) * 64),
-		// 	// 	Y: fixed.Int26_6((p.points[i+1] + p.curY) * 64)})
-		// }
-		// p.placeX = p.points[l-2]
-		// p.placeY = p.points[l-1]
+		if !p.hasSetsOrMore(2, rel) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for i := 0; i < l-1; i += 2 {
+			p.lineTo(p.points[i], p.points[i+1])
+		}
+		p.placeX = p.points[l-2]
+		p.placeY = p.points[l-1]
 	case 'v':
 		p.valsToAbs(p.placeY)
 		fallthrough
 	case 'V':
-		return errNotImplemented
-
-		// if !p.hasSetsOrMore(1, false) {
-		// 	return errParamMismatch
-		// }
-		// for _, p := range p.points {
-		// 	_ = p
-		// 	// p.Path.Line(fixed.Point26_6{
-		// 	// 	X: fixed.Int26_6((p.placeX + p.curX) * 64),
-		// 	// 	Y: fixed.Int26_6((p + p.curY) * 64)})
-		// }
-		// p.placeY = p.points[l-1]
+		if !p.hasSetsOrMore(1, false) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for _, y := range p.points {
+			p.lineTo(p.placeX, y)
+		}
+		p.placeY = p.points[l-1]
 	case 'h':
 		p.valsToAbs(p.placeX)
 		fallthrough
 	case 'H':
-		return errNotImplemented
-
-		// if !p.hasSetsOrMore(1, false) {
-		// 	return errParamMismatch
-		// }
-		// for _, p := range p.points {
-		// 	_ = p
-		// 	// p.Path.Line(fixed.Point26_6{
-		// 	// 	X: fixed.Int26_6((p + p.curX) * 64),
-		// 	// 	Y: fixed.Int26_6((p.placeY + p.curY) * 64)})
-		// }
-		// p.placeX = p.points[l-1]
+		if !p.hasSetsOrMore(1, false) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for _, x := range p.points {
+			p.lineTo(x, p.placeY)
+		}
+		p.placeX = p.points[l-1]
 	case 'q':
 		rel = true
 		fallthrough
 	case 'Q':
-		return errNotImplemented
-
-		// if !p.hasSetsOrMore(4, rel) {
-		// 	return errParamMismatch
-		// }
-		// for i := 0; i < l-3; i += 4 {
-		// 	// p.Path.QuadBezier(
-		// 	// 	fixed.Point26_6{
-		// 	// 		X: fixed.Int26_6((p.points[i] + p.curX) * 64),
-		// 	// 		Y: fixed.Int26_6((p.points[i+1] + p.curY) * 64)},
-		// 	// 	fixed.Point26_6{
-		// 	// 		X: fixed.Int26_6((p.points[i+2] + p.curX) * 64),
-		// 	// 		Y: fixed.Int26_6((p.points[i+3] + p.curY) * 64)})
-		// }
-		// p.cntlPtX, p.cntlPtY = p.points[l-4], p.points[l-3]
-		// p.placeX = p.points[l-2]
-		// p.placeY = p.points[l-1]
+		if !p.hasSetsOrMore(4, rel) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for i := 0; i < l-3; i += 4 {
+			p.quadTo(p.points[i], p.points[i+1], p.points[i+2], p.points[i+3])
+		}
+		p.cntlPtX, p.cntlPtY = p.points[l-4], p.points[l-3]
+		p.placeX = p.points[l-2]
+		p.placeY = p.points[l-1]
 	case 't':
 		rel = true
 		fallthrough
 	case 'T':
-		return errNotImplemented
-
-		// // if !p.hasSetsOrMore(2, rel) {
-		// // 	return errParamMismatch
-		// // }
-		// // for i := 0; i < l-1; i += 2 {
-		// // 	p.reflectControlQuad()
-		// // 	// p.Path.QuadBezier(
-		// // 	// 	fixed.Point26_6{
-		// // 	// 		X: fixed.Int26_6((p.cntlPtX + p.curX) * 64),
-		// // 	// 		Y: fixed.Int26_6((p.cntlPtY + p.curY) * 64)},
-		// // 	// 	fixed.Point26_6{
-		// // 	// 		X: fixed.Int26_6((p.points[i] + p.curX) * 64),
-		// // 	// 		Y: fixed.Int26_6((p.points[i+1] + p.curY) * 64)})
-		// // 	p.lastKey = k
-		// // 	p.placeX = p.points[i]
-		// // 	p.placeY = p.points[i+1]
-		// }
+		if !p.hasSetsOrMore(2, rel) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for i := 0; i < l-1; i += 2 {
+			p.reflectControlQuad()
+			p.quadTo(p.cntlPtX, p.cntlPtY, p.points[i], p.points[i+1])
+			p.lastKey = k
+			p.placeX = p.points[i]
+			p.placeY = p.points[i+1]
+		}
 	case 'c':
 		rel = true
 		fallthrough
 	case 'C':
-		return errNotImplemented
-
-		// if !p.hasSetsOrMore(6, rel) {
-		// 	return errParamMismatch
-		// }
-		// for i := 0; i < l-5; i += 6 {
-		// 	// p.Path.CubeBezier(
-		// 	// 	fixed.Point26_6{
-		// 	// 		X: fixed.Int26_6((p.points[i] + p.curX) * 64),
-		// 	// 		Y: fixed.Int26_6((p.points[i+1] + p.curY) * 64)},
-		// 	// 	fixed.Point26_6{
-		// 	// 		X: fixed.Int26_6((p.points[i+2] + p.curX) * 64),
-		// 	// 		Y: fixed.Int26_6((p.points[i+3] + p.curY) * 64)},
-		// 	// 	fixed.Point26_6{
-		// 	// 		X: fixed.Int26_6((p.points[i+4] + p.curX) * 64),
-		// 	// 		Y: fixed.Int26_6((p.points[i+5] + p.curY) * 64)})
-		// }
-		// p.cntlPtX, p.cntlPtY = p.points[l-4], p.points[l-3]
-		// p.placeX = p.points[l-2]
-		// p.placeY = p.points[l-1]
+		if !p.hasSetsOrMore(6, rel) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for i := 0; i < l-5; i += 6 {
+			p.cubicTo(p.points[i], p.points[i+1], p.points[i+2], p.points[i+3], p.points[i+4], p.points[i+5])
+		}
+		p.cntlPtX, p.cntlPtY = p.points[l-4], p.points[l-3]
+		p.placeX = p.points[l-2]
+		p.placeY = p.points[l-1]
 	case 's':
 		rel = true
 		fallthrough
 	case 'S':
-		return errNotImplemented
-
-		// if !p.hasSetsOrMore(4, rel) {
-		// 	return errParamMismatch
-		// }
-		// for i := 0; i < l-3; i += 4 {
-		// 	p.reflectControlCube()
-		// 	// p.Path.CubeBezier(fixed.Point26_6{
-		// 	// 	X: fixed.Int26_6((p.cntlPtX + p.curX) * 64), Y: fixed.Int26_6((p.cntlPtY + p.curY) * 64)},
-		// 	// 	fixed.Point26_6{
-		// 	// 		X: fixed.Int26_6((p.points[i] + p.curX) * 64), Y: fixed.Int26_6((p.points[i+1] + p.curY) * 64)},
-		// 	// 	fixed.Point26_6{
-		// 	// 		X: fixed.Int26_6((p.points[i+2] + p.curX) * 64), Y: fixed.Int26_6((p.points[i+3] + p.curY) * 64)})
-		// 	p.lastKey = k
-		// 	p.cntlPtX, p.cntlPtY = p.points[i], p.points[i+1]
-		// 	p.placeX = p.points[i+2]
-		// 	p.placeY = p.points[i+3]
-		// }
+		if !p.hasSetsOrMore(4, rel) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for i := 0; i < l-3; i += 4 {
+			p.reflectControlCube()
+			p.cubicTo(p.cntlPtX, p.cntlPtY, p.points[i], p.points[i+1], p.points[i+2], p.points[i+3])
+			p.lastKey = k
+			p.cntlPtX, p.cntlPtY = p.points[i], p.points[i+1]
+			p.placeX = p.points[i+2]
+			p.placeY = p.points[i+3]
+		}
 	case 'a', 'A':
-		return errNotImplemented
-
-		// if !p.hasSetsOrMore(7, false) {
-		// 	return errParamMismatch
-		// }
-		// for i := 0; i < l-6; i += 7 {
-		// 	if k == 'a' {
-		// 		p.points[i+5] += p.placeX
-		// 		p.points[i+6] += p.placeY
-		// 	}
-		// 	p.AddArcFromA(p.points[i:])
-		// }
+		if !p.hasSetsOrMore(7, false) {
+			return ErrParamMismatch
+		}
+		p.startSubpath()
+		for i := 0; i < l-6; i += 7 {
+			if k == 'a' {
+				p.points[i+5] += p.placeX
+				p.points[i+6] += p.placeY
+			}
+			p.addArc(p.points[i : i+7])
+		}
 	default:
-		if p.ErrorMode == StrictErrorMode {
-			return errCommandUnknown
+		if p.ErrorMode >= StrictErrorMode {
+			return ErrCommandUnknown
 		}
 		if p.ErrorMode == WarnErrorMode {
 			log.Println("Ignoring svg command " + string(k))
 		}
+		p.Stats.addWarning()
 	}
 	// So we know how to extend some segment types
 	p.lastKey = k
 	return nil
 }
 
+// paramSetSize returns the number of parameters consumed by one repetition
+// of the command k, or -1 if k is not a path command.
+func paramSetSize(k byte) int {
+	switch k {
+	case 'z', 'Z':
+		return 0
+	case 'h', 'H', 'v', 'V':
+		return 1
+	case 'm', 'M', 'l', 'L', 't', 'T':
+		return 2
+	case 'q', 'Q', 's', 'S':
+		return 4
+	case 'c', 'C':
+		return 6
+	case 'a', 'A':
+		return 7
+	}
+	return -1
+}
+
+// validateParams checks the parameters of command k against the SVG path
+// grammar: the parameter count must be a positive multiple of the command's
+// set size (zero for Z) and arc flags must be exactly 0 or 1.
+func (p *Parser) validateParams(k byte) error {
+	sz := paramSetSize(k)
+	if sz < 0 {
+		return nil
+	}
+	n := len(p.points)
+	if sz == 0 {
+		if n != 0 {
+			return fmt.Errorf("%w: %c takes no parameters, got %d", ErrParamMismatch, k, n)
+		}
+		return nil
+	}
+	if n == 0 || n%sz != 0 {
+		return fmt.Errorf("%w: %c takes sets of %d parameters, got %d", ErrParamMismatch, k, sz, n)
+	}
+	if k == 'a' || k == 'A' {
+		for i := 0; i < n; i += sz {
+			for _, f := range p.points[i+3 : i+5] {
+				if f != 0 && f != 1 {
+					return fmt.Errorf("%w: arc flag %v must be 0 or 1", ErrParamMismatch, f)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SegmentError records the path segment in which a parse error occurred.
+type SegmentError struct {
+	Cmd    byte  // command letter of the segment
+	Offset int   // byte offset of the command letter in the path string
+	Err    error // underlying error, usually one of the Err* sentinels
+}
+
+func (e *SegmentError) Error() string {
+	return fmt.Sprintf("svgg: command %q at offset %d: %v", e.Cmd, e.Offset, e.Err)
+}
+
+func (e *SegmentError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is returned by CompilePath in CollectErrorMode and holds every
+// error encountered, in path order.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Is reports whether any error in the list matches target.
+func (l ErrorList) Is(target error) bool {
+	for _, err := range l {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleError wraps an error from the segment with command cmd at offset
+// and decides, based on the ErrorMode, whether compiling should abort.
+func (p *Parser) handleError(cmd byte, offset int, err error) error {
+	err = &SegmentError{Cmd: cmd, Offset: offset, Err: err}
+	switch p.ErrorMode {
+	case CallbackErrorMode:
+		if p.ErrorHandler != nil {
+			if err = p.ErrorHandler(cmd, err); err == nil {
+				p.Stats.addWarning()
+			}
+			return err
+		}
+	case CollectErrorMode:
+		p.errs = append(p.errs, err)
+		p.Stats.addWarning()
+		return nil
+	}
+	return err
+}
+
 //EllipseAt adds a path of an elipse centered at cx, cy of radius rx and ry
 // to the Parser
 func (p *Parser) EllipseAt(cx, cy, rx, ry float64) {
-	log.Printf("warning: %s : %s\n", "EllipseAt", errNotImplemented.Error())
+	log.Printf("warning: %s : %s\n", "EllipseAt", ErrNotImplemented.Error())
 }
 
-//AddArcFromA adds a path of an arc element to the Parser
+//AddArcFromA adds a path of an arc element to the Parser. The points are
+// the seven absolute parameters of an SVG arc command: rx, ry, x-axis
+// rotation, large-arc flag, sweep flag, x and y.
 func (p *Parser) AddArcFromA(points []float64) {
-	log.Printf("warning: %s : %s\n", "AddArcFromA", errNotImplemented.Error())
+	p.flushPending()
+	p.addArc(points)
+}
+
+// startSubpath begins a new subpath at the current point when a drawing
+// command follows a closepath, as the path grammar allows.
+func (p *Parser) startSubpath() {
+	if !p.inPath {
+		p.flushSubpath()
+		p.pathStartX, p.pathStartY = p.placeX, p.placeY
+		p.inPath = true
+		p.moveTo(p.placeX, p.placeY)
+	}
+}
+
+// arcTo draws a clockwise elliptical arc with radii rx and ry from
+// (x0, y0), which must be the last point drawn, to (x, y).
+func (p *Parser) arcTo(x0, y0, rx, ry, x, y float64) {
+	p.placeX, p.placeY = x0, y0
+	p.addArc([]float64{rx, ry, 0, 0, 1, x, y})
+}
+
+// lineTo draws a line to (x, y), unless Epsilon allows dropping it.
+func (p *Parser) lineTo(x, y float64) {
+	if p.Epsilon > 0 && p.dc != nil {
+		if cur, ok := p.dc.GetCurrentPoint(); ok {
+			dx, dy := p.dc.TransformPoint(x, y)
+			if math.Hypot(dx-cur.X, dy-cur.Y) < p.Epsilon {
+				p.pendX, p.pendY, p.pending = x, y, true
+				return
+			}
+		}
+	}
+	p.pending = false
+	p.drawLine(x, y)
+}
+
+// drawLine adds a line to (x, y) to the context.
+func (p *Parser) drawLine(x, y float64) {
+	p.lastX, p.lastY = x, y
+	x, y = p.snap(x, y)
+	p.sink.LineTo(x, y)
+	p.chunk(x, y)
+}
+
+// moveTo starts a new subpath at (x, y) in the context.
+func (p *Parser) moveTo(x, y float64) {
+	p.lastX, p.lastY = x, y
+	x, y = p.snap(x, y)
+	p.sink.MoveTo(x, y)
+}
+
+// quadTo adds a quadratic Bézier to the context.
+func (p *Parser) quadTo(x1, y1, x, y float64) {
+	if p.tol > 0 {
+		p.flatten(Segment{Op: QuadOp, P: [3]gg.Point{{X: x1, Y: y1}, {X: x, Y: y}}})
+		return
+	}
+	p.lastX, p.lastY = x, y
+	x1, y1 = p.snap(x1, y1)
+	x, y = p.snap(x, y)
+	p.sink.QuadraticTo(x1, y1, x, y)
+	p.chunk(x, y)
+}
+
+// cubicTo adds a cubic Bézier to the context.
+func (p *Parser) cubicTo(x1, y1, x2, y2, x, y float64) {
+	if p.tol > 0 {
+		p.flatten(Segment{Op: CubicOp, P: [3]gg.Point{{X: x1, Y: y1}, {X: x2, Y: y2}, {X: x, Y: y}}})
+		return
+	}
+	p.lastX, p.lastY = x, y
+	x1, y1 = p.snap(x1, y1)
+	x2, y2 = p.snap(x2, y2)
+	x, y = p.snap(x, y)
+	p.sink.CubicTo(x1, y1, x2, y2, x, y)
+	p.chunk(x, y)
+}
+
+// flatten draws segment s, starting at the last point drawn, as lines
+// within the Parser's tolerance.
+func (p *Parser) flatten(s Segment) {
+	flattenSegment(gg.Point{X: p.lastX, Y: p.lastY}, s, p.tol, func(pt gg.Point) {
+		p.drawLine(pt.X, pt.Y)
+	})
+}
+
+// snap rounds the point (x, y) to the nearest multiple of PixelSnap in
+// device space, and then to the middle of its fixed-point cell if
+// Deterministic is set.
+func (p *Parser) snap(x, y float64) (float64, float64) {
+	if (p.PixelSnap <= 0 && !p.Deterministic) || p.dc == nil {
+		return x, y
+	}
+	x, y = p.dc.TransformPoint(x, y)
+	if g := p.PixelSnap; g > 0 {
+		x, y = math.Round(x/g)*g, math.Round(y/g)*g
+	}
+	if p.Deterministic {
+		x, y = fixedCenter(x), fixedCenter(y)
+	}
+	return p.inv.TransformPoint(x, y)
+}
+
+// fixedCenter returns the middle of the 1/64 pixel cell of v. gg truncates
+// coordinates to 26.6 fixed point, so anything within the cell rasterizes
+// alike, and the middle is the farthest from its neighbors.
+func fixedCenter(v float64) float64 {
+	return (math.Floor(v*64) + 0.5) / 64
+}
+
+// flushPending draws the last line end point dropped by lineTo, so runs of
+// lines end exactly where the path data says.
+func (p *Parser) flushPending() {
+	if p.pending {
+		p.pending = false
+		p.drawLine(p.pendX, p.pendY)
+	}
+}
+
+// chunk counts a segment ending at (x, y) that was added to the context,
+// flushing the path part way through the subpath if SplitSubpaths allows.
+func (p *Parser) chunk(x, y float64) {
+	p.emitted++
+	if p.SplitSubpaths && p.ChunkSize > 0 && p.emitted >= p.ChunkSize && p.Flush != nil {
+		p.Flush()
+		p.emitted = 0
+		p.split = true
+		p.moveTo(x, y)
+	}
+}
+
+// flushSubpath flushes the path before a new subpath begins if ChunkSize
+// segments have accumulated.
+func (p *Parser) flushSubpath() {
+	p.split = false
+	if p.ChunkSize > 0 && p.emitted >= p.ChunkSize && p.Flush != nil {
+		p.endClip()
+		p.Flush()
+		p.emitted = 0
+	}
+}
+
+// endClip sends what ClipRect left of the current subpath on to the sink.
+func (p *Parser) endClip() {
+	if p.sink == &p.clip {
+		p.clip.finish()
+	}
 }
 
 func (p *Parser) init() {
@@ -392,31 +774,254 @@ func (p *Parser) init() {
 	p.lastKey = ' '
 	// p.Path.Clear()
 	p.inPath = false
+	p.errs = nil
+	p.emitted = 0
+	p.split = false
+	p.cont = false
+	p.pending = false
+	if (p.PixelSnap > 0 || p.Deterministic) && p.dc != nil {
+		p.inv = invertMatrix(currentMatrix(p.dc))
+	}
+	p.tol = p.Tolerance
+	if p.tol <= 0 && p.Deterministic {
+		p.tol = DefaultTolerance
+	}
+	if p.tol > 0 && p.dc != nil {
+		if scale := matrixScale(currentMatrix(p.dc)); scale > 0 {
+			p.tol /= scale
+		}
+	}
+	if p.sink == &p.clip {
+		p.sink = p.clip.sink
+	}
+	if !p.ClipRect.Empty() && p.sink != nil {
+		m := gg.Identity()
+		if p.dc != nil {
+			m = currentMatrix(p.dc)
+		}
+		tol := p.Tolerance
+		if tol <= 0 {
+			tol = DefaultTolerance
+		}
+		r := p.ClipRect
+		p.clip.reset(p.sink, m, gg.Point{X: float64(r.Min.X), Y: float64(r.Min.Y)}, gg.Point{X: float64(r.Max.X), Y: float64(r.Max.Y)}, tol, p.ClipLines)
+		p.sink = &p.clip
+	}
+}
+
+// deadlineCheckInterval is the number of segments compiled between checks
+// of the Parser's Deadline.
+const deadlineCheckInterval = 1024
+
+func (p *Parser) pastDeadline() bool {
+	return !p.Deadline.IsZero() && time.Now().After(p.Deadline)
 }
 
 // CompilePath translates the svgPath description string and draws to the context.
 // All valid SVG path elements are interpreted to fogleman/gg drawing commands.
+// The path is tokenized in a single pass; numbers are parsed in place into
+// the Parser's reusable points buffer, so compiling does not allocate.
 func (p *Parser) CompilePath(svgPath string) error {
+	return p.compile(&pathSource{s: svgPath})
+}
+
+// CompilePathReader is like CompilePath but reads the path data from r a
+// small buffer at a time, so that path data of hundreds of megabytes, such
+// as a detailed coastline, is compiled in bounded memory. Segments with
+// very many parameters are drawn in parts as they are read, which changes
+// nothing in the drawing. Offsets in SegmentErrors count bytes from the
+// start of r. An error reading r, other than io.EOF, stops compiling and
+// is returned as is.
+func (p *Parser) CompilePathReader(r io.Reader) error {
+	return p.compile(&pathSource{r: r, buf: make([]byte, pathReadSize)})
+}
+
+// pathReadSize is the number of bytes CompilePathReader reads at a time,
+// and streamedPoints the number of parameters it collects before drawing
+// part of a segment.
+const (
+	pathReadSize   = 32 << 10
+	streamedPoints = 4 << 10
+)
+
+// pathSource holds the path data being compiled: a whole string, or a
+// window onto the data of a reader that is refilled as it is consumed.
+type pathSource struct {
+	s    string // the window; s[i] is at offset base+i of the data
+	base int
+	r    io.Reader // the data after the window, or nil once it is all read
+	buf  []byte
+	err  error // the error reading r, other than io.EOF
+}
+
+// fill drops the window before i, appends the next data read from r and
+// returns the index in the new window of the byte that was at i.
+func (src *pathSource) fill(i int) int {
+	n, err := src.r.Read(src.buf)
+	src.base += i
+	src.s = src.s[i:] + string(src.buf[:n])
+	if err != nil {
+		if err != io.EOF {
+			src.err = err
+		}
+		src.r = nil
+	}
+	return 0
+}
+
+// compile draws the path data of src to the context.
+func (p *Parser) compile(src *pathSource) error {
 	p.init()
-	lastIndex := -1
-	for i, v := range svgPath {
-		if unicode.IsLetter(v) && v != 'e' {
-			if lastIndex != -1 {
-				if err := p.addSeg(svgPath[lastIndex:i]); err != nil {
-					return err
-				}
+	strict := p.ErrorMode == StrictErrorMode
+	streaming := src.r != nil
+	var cmd byte // the command letter of the segment being read, or 0
+	cmdOffset := -1
+	var numErr error
+	segs := 0
+	// afterNumber is true when the last token was a number, which is the
+	// only place a comma may appear; afterComma when it was a comma, which
+	// must be followed by a number
+	afterNumber, afterComma := false, false
+	i := 0
+	for src.r != nil && len(src.s) < len("\uFEFF") {
+		i = src.fill(i)
+	}
+	i = skipSpace(src.s, i)
+	for {
+		for i >= len(src.s) && src.r != nil {
+			i = src.fill(i)
+		}
+		if i >= len(src.s) {
+			break
+		}
+		s := src.s
+		c := s[i]
+		switch {
+		case isArcFlag(cmd, len(p.points)) && (c == '0' || c == '1'):
+			// flags are single characters and need no separator
+			p.points = append(p.points, float64(c-'0'))
+			afterNumber, afterComma = true, false
+			i++
+		case isNumberStart(c):
+			if strict && cmd == 0 {
+				return p.handleError(c, src.base+i, fmt.Errorf("%w: path data must start with a command", ErrSyntax))
+			}
+			// a number at the end of the window may go on, as may one
+			// followed by an exponent marker and sign
+			for src.r != nil && len(src.s)-scanNumber(src.s, i) <= len("e+") {
+				i = src.fill(i)
+			}
+			j, err := p.readNumber(src.s, i)
+			if err != nil && numErr == nil {
+				numErr = err
+			}
+			afterNumber, afterComma = true, false
+			i = j
+		case isLetter(c):
+			if segs++; segs%deadlineCheckInterval == 0 && p.pastDeadline() {
+				return ErrDeadline
 			}
-			lastIndex = i
+			if strict && afterComma && numErr == nil {
+				numErr = fmt.Errorf("%w: comma before command", ErrSyntax)
+			}
+			if err := p.endSeg(cmd, cmdOffset, numErr); err != nil {
+				return err
+			}
+			p.cont = false
+			cmd, cmdOffset = c, src.base+i
+			numErr = nil
+			afterNumber, afterComma = false, false
+			p.points = p.points[0:0]
+			i++
+		case isSpace(c):
+			i++
+		case c == ',':
+			if strict && !afterNumber && numErr == nil {
+				numErr = fmt.Errorf("%w: unexpected comma at offset %d", ErrSyntax, src.base+i)
+			}
+			afterNumber, afterComma = false, true
+			i++
+		default:
+			if strict && numErr == nil {
+				numErr = fmt.Errorf("%w: unexpected character %q at offset %d", ErrSyntax, c, src.base+i)
+			}
+			i++
 		}
-	}
-	if lastIndex != -1 {
-		if err := p.addSeg(svgPath[lastIndex:]); err != nil {
-			return err
+		if streaming && len(p.points) >= streamedPoints {
+			if err := p.endPart(&cmd, cmdOffset, numErr); err != nil {
+				return err
+			}
 		}
 	}
+	if src.err != nil {
+		return src.err
+	}
+	if strict && afterComma && numErr == nil {
+		numErr = fmt.Errorf("%w: trailing comma", ErrSyntax)
+	}
+	if err := p.endSeg(cmd, cmdOffset, numErr); err != nil {
+		return err
+	}
+	p.flushPending()
+	p.endClip()
+
+	if len(p.errs) > 0 {
+		return p.errs
+	}
+	return nil
+}
+
+// endPart draws the parameters read so far of the segment with command
+// *cmd if they make whole sets, so that the rest of it is drawn as a
+// continuation. The implicit lineto commands after a moveto continue as
+// lineto commands. Parameters before the first command are dropped.
+func (p *Parser) endPart(cmd *byte, offset int, numErr error) error {
+	if *cmd == 0 {
+		p.points = p.points[0:0]
+		return nil
+	}
+	sz := paramSetSize(*cmd)
+	if numErr != nil || sz <= 0 || len(p.points)%sz != 0 {
+		return nil
+	}
+	if err := p.endSeg(*cmd, offset, nil); err != nil {
+		return err
+	}
+	switch *cmd {
+	case 'M':
+		*cmd = 'L'
+	case 'm':
+		*cmd = 'l'
+	}
+	p.cont = true
+	p.points = p.points[0:0]
+	return nil
+}
 
-	p.dc.ClosePath()
+// isArcFlag reports whether the next number of the segment with command
+// cmd, having n numbers so far, is an arc flag.
+func isArcFlag(cmd byte, n int) bool {
+	if cmd != 'a' && cmd != 'A' {
+		return false
+	}
+	n %= 7
+	return n == 3 || n == 4
+}
 
+// endSeg draws the segment with command cmd at offset, or reports numErr if
+// one of its numbers failed to parse. Numbers before the first command,
+// when cmd is 0, are ignored.
+func (p *Parser) endSeg(cmd byte, offset int, numErr error) error {
+	if cmd == 0 {
+		return nil
+	}
+	err := numErr
+	if err == nil {
+		err = p.addSeg(cmd)
+	}
+	if err != nil {
+		return p.handleError(cmd, offset, err)
+	}
 	return nil
 }
 
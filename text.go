@@ -0,0 +1,435 @@
+package svgg
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// textSpan is an element of a text, whose character data is drawn with its
+// style.
+type textSpan struct {
+	e *Element
+	s style
+}
+
+// textPositions are the attributes positioning the characters of a text,
+// in the order of textChar.pos.
+var textPositions = [...]string{"x", "y", "dx", "dy", "rotate"}
+
+// textChar is a character of a text after white space is handled, with
+// the values of textPositions that apply to it, which are NaN if unset.
+type textChar struct {
+	r    rune
+	span int
+	pos  [len(textPositions)]float64
+}
+
+// textGlyph is a character of a text placed on the baseline.
+type textGlyph struct {
+	r       rune
+	span    int
+	font    *Font
+	index   sfnt.GlyphIndex
+	size    float64
+	x, y    float64 // origin on the baseline
+	rotate  float64 // degrees
+	advance float64
+	bold    bool // synthetic bold and oblique
+	oblique bool
+}
+
+// textLayout is a laid out <text> element.
+type textLayout struct {
+	spans   []textSpan
+	glyphs  []textGlyph
+	advance float64 // of the glyphs and the spacing after them
+}
+
+// layoutText lays out the character data of the text element e, whose
+// style is s, and of the <tspan> elements inside it.
+func (doc *Document) layoutText(e *Element, s style) (*textLayout, error) {
+	l := &textLayout{}
+	chars, err := l.chars(e, s)
+	if err != nil {
+		return nil, err
+	}
+	fonts := doc.fonts()
+	var buf sfnt.Buffer
+	var x, y float64
+	chunk := 0 // first glyph of the current text chunk
+	for _, c := range chars {
+		// an absolute position starts a new chunk, which is anchored as a
+		// whole
+		cx, cy, dx, dy, rotate := c.pos[0], c.pos[1], c.pos[2], c.pos[3], c.pos[4]
+		if !math.IsNaN(cx) || !math.IsNaN(cy) {
+			l.anchor(chunk, x)
+			chunk = len(l.glyphs)
+		}
+		if !math.IsNaN(cx) {
+			x = cx
+		}
+		if !math.IsNaN(cy) {
+			y = cy
+		}
+		if !math.IsNaN(dx) {
+			x += dx
+		}
+		if !math.IsNaN(dy) {
+			y += dy
+		}
+		cs := l.spans[c.span].s
+		g := textGlyph{r: c.r, span: c.span, size: cs.fontSize, x: x, y: y}
+		if !math.IsNaN(rotate) {
+			g.rotate = rotate
+		}
+		m := fonts.match(&buf, c.r, cs.fontFamily, cs.fontWeight, cs.italic)
+		g.font, g.index = m.font, m.index
+		g.bold = m.bold && cs.synthBold
+		g.oblique = m.oblique && cs.synthOblique
+		if g.font != nil {
+			f := g.font.sfnt
+			ppem := fixed.I(int(f.UnitsPerEm()))
+			if adv, err := f.GlyphAdvance(&buf, g.index, ppem, font.HintingNone); err == nil {
+				g.advance = float64(adv) / 64 * g.size / float64(f.UnitsPerEm())
+			}
+			if g.bold {
+				g.advance += g.size * emboldenWidth
+			}
+			g.y += baselineShift(&buf, g.font, g.size, cs.spanBaseline())
+			// pairs are kerned within a chunk, in the same font and size,
+			// from the font's kern table
+			if n := len(l.glyphs); cs.kerning && n > chunk {
+				if prev := l.glyphs[n-1]; prev.font == g.font && prev.size == g.size {
+					if k, err := f.Kern(&buf, prev.index, g.index, ppem, font.HintingNone); err == nil && k != 0 {
+						kern := float64(k) / 64 * g.size / float64(f.UnitsPerEm())
+						g.x += kern
+						x += kern
+						l.advance += kern
+					}
+				}
+			}
+		}
+		l.glyphs = append(l.glyphs, g)
+		adv := g.advance + cs.letterSpacing
+		if c.r == ' ' {
+			adv += cs.wordSpacing
+		}
+		x += adv
+		l.advance += adv
+	}
+	l.anchor(chunk, x)
+	return l, nil
+}
+
+// spanBaseline returns the baseline that the text position of a span with
+// style s is aligned to.
+func (s style) spanBaseline() string {
+	switch s.alignBaseline {
+	case "", "auto", "baseline":
+		return s.baseline
+	}
+	return s.alignBaseline
+}
+
+// baselineShift returns how far below the text position the alphabetic
+// baseline of a glyph in font f at size is moved so that the given
+// baseline lies on the text position. The baselines are derived from the
+// font's metrics, as browsers do for fonts without a baseline table.
+func baselineShift(buf *sfnt.Buffer, f *Font, size float64, baseline string) float64 {
+	upm := float64(f.sfnt.UnitsPerEm())
+	m, err := f.sfnt.Metrics(buf, fixed.I(int(upm)), font.HintingNone)
+	if err != nil {
+		return 0
+	}
+	k := size / upm / 64
+	ascent, descent := float64(m.Ascent)*k, float64(m.Descent)*k
+	switch baseline {
+	case "middle":
+		return float64(m.XHeight) * k / 2
+	case "central":
+		return (ascent - descent) / 2
+	case "hanging":
+		return float64(m.CapHeight) * k
+	case "mathematical":
+		return ascent / 2
+	case "text-top", "text-before-edge":
+		return ascent
+	case "text-bottom", "text-after-edge", "ideographic":
+		return -descent
+	}
+	return 0
+}
+
+// chars returns the characters of the text element e with style s. White
+// space is handled as browsers do: newlines and tabs become spaces, and
+// unless xml:space is "preserve", spaces are collapsed and trimmed from
+// the start and end of the text.
+func (l *textLayout) chars(e *Element, s style) ([]textChar, error) {
+	var chars []textChar
+	add := func(text string, span int) {
+		preserve := l.spans[span].s.preserveSpace
+		for _, r := range text {
+			switch r {
+			case '\n', '\r', '\t':
+				r = ' '
+			}
+			if r == ' ' && !preserve && (len(chars) == 0 || chars[len(chars)-1].r == ' ') {
+				continue
+			}
+			c := textChar{r: r, span: span}
+			for k := range c.pos {
+				c.pos[k] = math.NaN()
+			}
+			chars = append(chars, c)
+		}
+	}
+	var walk func(e *Element, s style) error
+	walk = func(e *Element, s style) error {
+		start := len(chars)
+		span := len(l.spans)
+		l.spans = append(l.spans, textSpan{e: e, s: s})
+		add(e.Text, span)
+		for _, c := range e.Children {
+			if c.Name == "tspan" {
+				cs, err := s.resolve(c)
+				if err != nil {
+					return err
+				}
+				if cs.display {
+					if err := walk(c, cs); err != nil {
+						return err
+					}
+				}
+			}
+			add(c.Tail, span)
+		}
+		// the positions of e apply to the characters inside it that those
+		// of its descendants do not, and its last rotation to all the rest
+		for k, name := range textPositions {
+			list, err := parseFloats(e.Attr(name))
+			if err != nil || len(list) == 0 {
+				if err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				continue
+			}
+			for i := start; i < len(chars); i++ {
+				j := i - start
+				if j >= len(list) {
+					if name != "rotate" {
+						break
+					}
+					j = len(list) - 1
+				}
+				if math.IsNaN(chars[i].pos[k]) {
+					chars[i].pos[k] = list[j]
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(e, s); err != nil {
+		return nil, err
+	}
+	for n := len(chars); n > 0 && chars[n-1].r == ' ' && !l.spans[chars[n-1].span].s.preserveSpace; n-- {
+		chars = chars[:n-1]
+	}
+	return chars, nil
+}
+
+// anchor moves the glyphs of the text chunk from start to the last glyph,
+// which ends at x, according to the text-anchor of its first glyph.
+func (l *textLayout) anchor(start int, x float64) {
+	if start >= len(l.glyphs) {
+		return
+	}
+	var shift float64
+	switch l.spans[l.glyphs[start].span].s.textAnchor {
+	case "middle":
+		shift = (x - l.glyphs[start].x) / 2
+	case "end":
+		shift = x - l.glyphs[start].x
+	default:
+		return
+	}
+	for i := start; i < len(l.glyphs); i++ {
+		l.glyphs[i].x -= shift
+	}
+}
+
+// emboldenWidth is the width, relative to the font size, of the stroke that
+// synthesizes a bold face.
+const emboldenWidth = 1.0 / 24
+
+// obliqueSlant is the slant of a synthesized oblique face, the tangent of
+// 14 degrees.
+const obliqueSlant = 0.25
+
+// outline sends the outlines of the glyphs from i to j to sink.
+func (l *textLayout) outline(buf *sfnt.Buffer, sink PathSink, i, j int) error {
+	for _, g := range l.glyphs[i:j] {
+		if g.font == nil {
+			continue
+		}
+		f := g.font.sfnt
+		upm := float64(f.UnitsPerEm())
+		segs, err := f.LoadGlyph(buf, g.index, fixed.I(int(upm)), nil)
+		if err != nil {
+			return err
+		}
+		// font units are 26.6 fixed point at a size of one em per unit
+		k := g.size / upm / 64
+		m := gg.Scale(k, k)
+		if g.oblique {
+			// glyph outlines are y down, so the slant shifts the top right
+			m = m.Multiply(gg.Shear(-obliqueSlant, 0))
+		}
+		m = m.Multiply(gg.Rotate(g.rotate * math.Pi / 180)).Multiply(gg.Translate(g.x, g.y))
+		t := &transformSink{sink: sink, m: m}
+		open := false
+		for _, s := range segs {
+			a := s.Args
+			switch s.Op {
+			case sfnt.SegmentOpMoveTo:
+				if open {
+					t.ClosePath()
+				}
+				t.MoveTo(float64(a[0].X), float64(a[0].Y))
+				open = true
+			case sfnt.SegmentOpLineTo:
+				t.LineTo(float64(a[0].X), float64(a[0].Y))
+			case sfnt.SegmentOpQuadTo:
+				t.QuadraticTo(float64(a[0].X), float64(a[0].Y), float64(a[1].X), float64(a[1].Y))
+			case sfnt.SegmentOpCubeTo:
+				t.CubicTo(float64(a[0].X), float64(a[0].Y), float64(a[1].X), float64(a[1].Y), float64(a[2].X), float64(a[2].Y))
+			}
+		}
+		if open {
+			t.ClosePath()
+		}
+	}
+	return nil
+}
+
+// drawText draws the <text> element e with style s. Each span of text is
+// painted with its own style, and paint servers are fitted to the bounding
+// box of the whole text. Synthetic bold is drawn by also stroking the
+// glyphs with their fill, which is approximate for translucent fills.
+func (r *renderer) drawText(e *Element, s style) error {
+	l, err := r.doc.layoutText(e, s)
+	if err != nil {
+		return err
+	}
+	var buf sfnt.Buffer
+	bounds := func() (*boundsSink, error) {
+		box := &boundsSink{}
+		return box, l.outline(&buf, box, 0, len(l.glyphs))
+	}
+	for i := 0; i < len(l.glyphs); {
+		j := i + 1
+		for j < len(l.glyphs) && l.glyphs[j].span == l.glyphs[i].span && l.glyphs[j].bold == l.glyphs[i].bold {
+			j++
+		}
+		ss := l.spans[l.glyphs[i].span].s
+		fill, stroke, err := r.paints(e, ss, bounds)
+		if err != nil {
+			return err
+		}
+		if err := l.outline(&buf, r.dc, i, j); err != nil {
+			r.dc.ClearPath()
+			return err
+		}
+		if g := l.glyphs[i]; g.bold && fill != nil {
+			r.dc.SetStrokeStyle(fill)
+			r.dc.SetLineWidth(g.size * emboldenWidth * matrixScale(currentMatrix(r.dc)))
+			r.dc.SetLineJoin(gg.LineJoinRound)
+			r.dc.SetDash()
+			r.dc.StrokePreserve()
+		}
+		r.paint(ss, fill, stroke)
+		i = j
+	}
+	if r.debugging(DebugBounds) {
+		if b, err := bounds(); err == nil && b.started {
+			r.debug.box(b.x0, b.y0, b.x1, b.y1, currentMatrix(r.dc))
+		}
+	}
+	return nil
+}
+
+// TextMetrics are the measurements of a laid out <text> element, in its
+// user space.
+type TextMetrics struct {
+	// Advance is the distance the text position moves over the glyphs,
+	// including letter and word spacing, as if the text were one chunk.
+	Advance float64
+
+	// X0, Y0, X1, Y1 bound the outlines of the glyphs as drawn, including
+	// synthetic bold. They are zero if no glyph has an outline.
+	X0, Y0, X1, Y1 float64
+}
+
+// MeasureText lays out the <text> element e as Draw would, with the same
+// fonts, positions and spacing, and returns its measurements. e must be in
+// the document's element tree, whose ancestors of e give its style.
+func (doc *Document) MeasureText(e *Element) (TextMetrics, error) {
+	if e.Name != "text" {
+		return TextMetrics{}, fmt.Errorf("svgg: %w: measuring <%s>", ErrNotImplemented, e.Name)
+	}
+	path := elementPath(doc.Root, e)
+	if path == nil {
+		return TextMetrics{}, fmt.Errorf("svgg: <text> is not in the document")
+	}
+	s := doc.rootStyle()
+	for _, a := range path {
+		var err error
+		if s, err = s.resolve(a); err != nil {
+			return TextMetrics{}, fmt.Errorf("svgg: <%s>: %w", a.Name, err)
+		}
+	}
+	l, err := doc.layoutText(e, s)
+	if err != nil {
+		return TextMetrics{}, fmt.Errorf("svgg: <text>: %w", err)
+	}
+	tm := TextMetrics{Advance: l.advance}
+	var buf sfnt.Buffer
+	box := &boundsSink{}
+	for i, g := range l.glyphs {
+		b := &boundsSink{}
+		if err := l.outline(&buf, b, i, i+1); err != nil {
+			return TextMetrics{}, fmt.Errorf("svgg: <text>: %w", err)
+		}
+		if !b.started {
+			continue
+		}
+		pad := 0.0
+		if g.bold {
+			pad = g.size * emboldenWidth / 2
+		}
+		box.add(b.x0-pad, b.y0-pad)
+		box.add(b.x1+pad, b.y1+pad)
+	}
+	if box.started {
+		tm.X0, tm.Y0, tm.X1, tm.Y1 = box.x0, box.y0, box.x1, box.y1
+	}
+	return tm, nil
+}
+
+// elementPath returns the elements from root down to e, or nil if e is not
+// inside root.
+func elementPath(root, e *Element) []*Element {
+	if root == e {
+		return []*Element{e}
+	}
+	for _, c := range root.Children {
+		if path := elementPath(c, e); path != nil {
+			return append([]*Element{root}, path...)
+		}
+	}
+	return nil
+}
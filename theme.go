@@ -0,0 +1,67 @@
+package svgg
+
+import (
+	"image/color"
+	"math"
+)
+
+// SetClassStyle overrides the paint of the elements of the document in
+// class, so that one SVG template can be drawn in many themes. The
+// overrides take precedence over the element's presentation attributes
+// and style attribute, and are inherited like them. A nil Fill leaves the
+// fill as it is, and the stroke is only overridden if Stroke is non-nil
+// and its Width positive; SetClassStyle(class, Style{}) removes the
+// overrides of class. Where an element is in several overridden classes,
+// the last in its class attribute wins.
+//
+// SetClassStyle must not be called while the document is being drawn.
+func (doc *Document) SetClassStyle(class string, st Style) {
+	props := make(map[string]string)
+	for _, a := range (&Encoder{Precision: -1}).styleAttrs(st) {
+		props[a.Name.Local] = a.Value
+	}
+	// the alpha of a color is written only if it is not opaque, but must
+	// replace the document's opacity either way
+	if st.Fill == nil {
+		delete(props, "fill")
+	} else if _, ok := props["fill-opacity"]; !ok {
+		props["fill-opacity"] = "1"
+	}
+	if _, ok := props["stroke"]; ok {
+		if _, ok := props["stroke-opacity"]; !ok {
+			props["stroke-opacity"] = "1"
+		}
+	}
+	if len(props) == 0 {
+		delete(doc.classes, class)
+		return
+	}
+	if doc.classes == nil {
+		doc.classes = make(map[string]map[string]string)
+	}
+	doc.classes[class] = props
+}
+
+// rootStyle returns the initial style of the document's root element,
+// carrying the class overrides.
+func (doc *Document) rootStyle() style {
+	s := defaultStyle
+	s.classes = doc.classes
+	return s
+}
+
+// invertLightness returns c with its HSL lightness inverted, keeping its
+// hue, saturation and alpha.
+func invertLightness(c color.Color) color.Color {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	r, g, b := float64(n.R)/255, float64(n.G)/255, float64(n.B)/255
+	max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	// the lightness is (max+min)/2, and the chroma max-min, which with the
+	// hue keeps the saturation of the inverted lightness, so the channels
+	// only shift
+	d := 1 - (max + min)
+	channel := func(v float64) uint8 {
+		return uint8(clamp(v+d, 0, 1)*255 + 0.5)
+	}
+	return color.NRGBA{channel(r), channel(g), channel(b), n.A}
+}
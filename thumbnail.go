@@ -0,0 +1,73 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sync"
+)
+
+// DefaultMaxThumbnails is the number of images a Thumbnailer whose bound
+// is zero keeps.
+const DefaultMaxThumbnails = 16
+
+// Thumbnailer draws a Document at the sizes asked for and keeps the most
+// recently used images, so that GUI apps that redraw the same asset at the
+// same size do not draw it again. It is safe for concurrent use; callers
+// asking for a size that is being drawn wait for it rather than drawing it
+// twice.
+type Thumbnailer struct {
+	Doc *Document
+
+	// Options configures how the document is drawn. It may be nil.
+	Options *RenderOptions
+
+	mu    sync.Mutex
+	cache *lru
+}
+
+// NewThumbnailer returns a Thumbnailer of doc keeping at most max images,
+// or DefaultMaxThumbnails if max is zero, which is also the bound of a
+// Thumbnailer that is not made by NewThumbnailer.
+func NewThumbnailer(doc *Document, max int) *Thumbnailer {
+	if max <= 0 {
+		max = DefaultMaxThumbnails
+	}
+	return &Thumbnailer{Doc: doc, cache: newLRU(int64(max))}
+}
+
+// Thumbnail returns the document drawn to fit within width by height
+// pixels, keeping its aspect ratio. The returned image is shared and must
+// not be modified. If drawing fails, the error is returned with whatever
+// was drawn, and the image stays cached.
+func (t *Thumbnailer) Thumbnail(width, height int) (*image.RGBA, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("svgg: invalid thumbnail size %dx%d", width, height)
+	}
+	key := [2]int{width, height}
+	t.mu.Lock()
+	if t.cache == nil {
+		t.cache = newLRU(DefaultMaxThumbnails)
+	}
+	v, ok := t.cache.get(key)
+	if !ok {
+		w, h := fitSize(t.Doc, width, height)
+		v = NewImage(t.Doc, w, h, t.Options)
+		t.cache.add(key, v, 1)
+	}
+	t.mu.Unlock()
+	im := v.(*Image)
+	return im.Image(), im.Err()
+}
+
+// fitSize returns the size in whole pixels of doc scaled to fit within
+// width by height, at least one pixel each way.
+func fitSize(doc *Document, width, height int) (int, int) {
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return width, height
+	}
+	k := math.Min(float64(width)/doc.Width, float64(height)/doc.Height)
+	w := int(math.Max(1, math.Round(doc.Width*k)))
+	h := int(math.Max(1, math.Round(doc.Height*k)))
+	return w, h
+}
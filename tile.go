@@ -0,0 +1,84 @@
+package svgg
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// DefaultTileSize is the edge length in pixels of the tiles drawn by a
+// TileRenderer whose TileSize is zero.
+const DefaultTileSize = 256
+
+// MaxTileZoom is the deepest zoom level RenderTile draws, the deepest any
+// slippy map uses, beyond which tile numbers overflow.
+const MaxTileZoom = 30
+
+// TileRenderer draws a Document piece by piece, for consumers such as
+// slippy maps that display huge SVG basemaps as a grid of small images.
+// Each tile is drawn on its own context, translated and scaled so that it
+// shows its part of the document; anything outside the tile is clipped by
+// the context bounds, or before rasterizing if Options.ClipGeometry is
+// set. Tiles may be drawn concurrently.
+type TileRenderer struct {
+	Doc *Document
+
+	// TileSize is the edge length of a z/x/y tile in pixels.
+	TileSize int
+
+	// Options configures how each tile is drawn. It may be nil.
+	Options *RenderOptions
+}
+
+// NewTileRenderer returns a TileRenderer drawing doc in tiles of
+// DefaultTileSize pixels.
+func NewTileRenderer(doc *Document) *TileRenderer {
+	return &TileRenderer{Doc: doc, TileSize: DefaultTileSize}
+}
+
+func (t *TileRenderer) tileSize() int {
+	if t.TileSize > 0 {
+		return t.TileSize
+	}
+	return DefaultTileSize
+}
+
+// ZoomScale returns the scale at which the document is drawn at zoom level
+// z. At zoom 0 the larger of the document's width and height fits a single
+// tile, and each zoom level doubles the scale.
+func (t *TileRenderer) ZoomScale(z int) float64 {
+	size := math.Max(t.Doc.Width, t.Doc.Height)
+	if size <= 0 {
+		return 0
+	}
+	return float64(t.tileSize()) * math.Exp2(float64(z)) / size
+}
+
+// RenderTile draws tile x, y of zoom level z, counting from the top left
+// tile at 0, 0. Tiles past the edge of the document are drawn empty.
+func (t *TileRenderer) RenderTile(z, x, y int) (*image.RGBA, error) {
+	if z < 0 || z > MaxTileZoom || x < 0 || y < 0 || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		return nil, fmt.Errorf("svgg: tile %d/%d/%d out of range", z, x, y)
+	}
+	n := t.tileSize()
+	r := image.Rect(x*n, y*n, (x+1)*n, (y+1)*n)
+	return t.RenderRect(r, t.ZoomScale(z))
+}
+
+// RenderRect draws the pixels within r of the document drawn at the given
+// scale, with the origin of the document's viewport at pixel 0, 0. The
+// returned image has bounds r.
+func (t *TileRenderer) RenderRect(r image.Rectangle, scale float64) (*image.RGBA, error) {
+	if r.Empty() {
+		return nil, fmt.Errorf("svgg: empty tile rectangle %v", r)
+	}
+	dc := gg.NewContext(r.Dx(), r.Dy())
+	dc.Translate(float64(-r.Min.X), float64(-r.Min.Y))
+	dc.Scale(scale, scale)
+	err := t.Doc.DrawWithOptions(dc, t.Options)
+	im := dc.Image().(*image.RGBA)
+	im.Rect = r
+	return im, err
+}
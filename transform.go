@@ -0,0 +1,118 @@
+package svgg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// parseTransform parses the value of a transform attribute into a matrix.
+// Transforms in the list are applied right to left, as in the SVG spec.
+func parseTransform(s string) (gg.Matrix, error) {
+	m := gg.Identity()
+	s = strings.TrimSpace(s)
+	for s != "" {
+		open := strings.IndexByte(s, '(')
+		close := strings.IndexByte(s, ')')
+		if open < 0 || close < open {
+			return m, fmt.Errorf("svgg: invalid transform %q", s)
+		}
+		name := strings.TrimSpace(s[:open])
+		args, err := parseFloats(s[open+1 : close])
+		if err != nil {
+			return m, err
+		}
+		t, err := transformFunc(name, args)
+		if err != nil {
+			return m, err
+		}
+		m = t.Multiply(m)
+		s = strings.TrimLeft(s[close+1:], ", \t\r\n")
+	}
+	return m, nil
+}
+
+// transformFunc returns the matrix for a single transform function.
+func transformFunc(name string, a []float64) (gg.Matrix, error) {
+	n := len(a)
+	switch {
+	case name == "matrix" && n == 6:
+		return gg.Matrix{XX: a[0], YX: a[1], XY: a[2], YY: a[3], X0: a[4], Y0: a[5]}, nil
+	case name == "translate" && n == 1:
+		return gg.Translate(a[0], 0), nil
+	case name == "translate" && n == 2:
+		return gg.Translate(a[0], a[1]), nil
+	case name == "scale" && n == 1:
+		return gg.Scale(a[0], a[0]), nil
+	case name == "scale" && n == 2:
+		return gg.Scale(a[0], a[1]), nil
+	case name == "rotate" && n == 1:
+		return gg.Rotate(gg.Radians(a[0])), nil
+	case name == "rotate" && n == 3:
+		return gg.Translate(-a[1], -a[2]).
+			Multiply(gg.Rotate(gg.Radians(a[0]))).
+			Multiply(gg.Translate(a[1], a[2])), nil
+	case name == "skewX" && n == 1:
+		return gg.Shear(math.Tan(gg.Radians(a[0])), 0), nil
+	case name == "skewY" && n == 1:
+		return gg.Shear(0, math.Tan(gg.Radians(a[0]))), nil
+	}
+	return gg.Identity(), fmt.Errorf("%w: transform %s with %d arguments", ErrParamMismatch, name, n)
+}
+
+// applyMatrix multiplies the current matrix of dc by m. gg has no way to set
+// an arbitrary matrix, so m is decomposed into a translation, rotation,
+// shear and scale, which are applied in turn.
+func applyMatrix(dc *gg.Context, m gg.Matrix) {
+	a, b, c, d := m.XX, m.YX, m.XY, m.YY
+	sx := math.Hypot(a, b)
+	dc.Translate(m.X0, m.Y0)
+	if sx == 0 {
+		dc.Scale(0, 0)
+		return
+	}
+	theta := math.Atan2(b, a)
+	sy := (a*d - b*c) / sx
+	dc.Rotate(theta)
+	if sy != 0 {
+		dc.Shear((a*c+b*d)/(sx*sy), 0)
+	}
+	dc.Scale(sx, sy)
+}
+
+// invertMatrix returns the inverse of m, or the identity if m is singular.
+func invertMatrix(m gg.Matrix) gg.Matrix {
+	det := m.XX*m.YY - m.XY*m.YX
+	if det == 0 {
+		return gg.Identity()
+	}
+	return gg.Matrix{
+		XX: m.YY / det,
+		YX: -m.YX / det,
+		XY: -m.XY / det,
+		YY: m.XX / det,
+		X0: (m.XY*m.Y0 - m.YY*m.X0) / det,
+		Y0: (m.YX*m.X0 - m.XX*m.Y0) / det,
+	}
+}
+
+// TransformPath returns the path data d with the affine transform m baked
+// into its coordinates, written in absolute commands. Arcs stay arcs, with
+// their radii, rotation and direction transformed to match.
+func TransformPath(d string, m gg.Matrix) (string, error) {
+	c, err := Compile(d)
+	if err != nil {
+		return "", err
+	}
+	return c.Transform(m).String(), nil
+}
+
+// Transform returns a copy of the path transformed by m. Arcs are replaced
+// by cubic Béziers only if m is singular.
+func (c *CompiledPath) Transform(m gg.Matrix) *CompiledPath {
+	out := &CompiledPath{Segments: make([]Segment, 0, len(c.Segments))}
+	c.Draw(&transformSink{sink: out, m: m})
+	return out
+}
@@ -26,3 +26,69 @@ func parseFloat(s string, bitSize int) (float64, error) {
 	val := trimSuffixes(s)
 	return strconv.ParseFloat(val, bitSize)
 }
+
+// isNumberStart reports whether c can begin a number in path data.
+func isNumberStart(c byte) bool {
+	return isDigit(c) || c == '.' || c == '-' || c == '+'
+}
+
+// isLetter reports whether c is an ASCII letter. Outside of a number,
+// letters start path commands; exponent markers are consumed by scanNumber.
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// scanNumber returns the end of the number starting at s[i], following
+// the SVG number grammar: an optional sign, digits with at most one decimal
+// point, and an optional exponent of 'e' or 'E', an optional sign and
+// digits. An exponent marker not followed by digits is not consumed, so
+// "5e-5-3e-2" scans as 5e-5 and -3e-2. A lone sign or decimal point is
+// returned as is and fails to parse.
+func scanNumber(s string, i int) int {
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '-' || s[j] == '+') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
+}
+
+// isSpace reports whether c is whitespace in the SVG path grammar.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// skipSpace returns the index of the first byte at or after i that is not
+// whitespace, also skipping a UTF-8 byte order mark at the start of s.
+func skipSpace(s string, i int) int {
+	if i == 0 && strings.HasPrefix(s, "\uFEFF") {
+		i = len("\uFEFF")
+	}
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	return i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,62 @@
+package svgg
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+)
+
+// WorldFile holds the affine georeferencing of a raster, in the order of
+// an ESRI world file, so that GIS software such as QGIS can place images
+// drawn from map coordinates. A pixel at column x and row y has its center
+// at world coordinates
+//
+//	A*x + B*y + C, D*x + E*y + F
+//
+// so C, F is the center of the top-left pixel.
+type WorldFile struct {
+	A, D, B, E, C, F float64
+}
+
+// NewWorldFile returns the world file of a raster drawn with m mapping
+// world coordinates to pixels, such as the current matrix of a context
+// after scaling and translating it to draw projected coordinates. A
+// singular m gives the world file of the identity.
+func NewWorldFile(m gg.Matrix) WorldFile {
+	inv := invertMatrix(m)
+	x, y := inv.TransformPoint(0.5, 0.5)
+	return WorldFile{A: inv.XX, D: inv.YX, B: inv.XY, E: inv.YY, C: x, F: y}
+}
+
+// WriteTo writes the world file as its six lines of text. The values are
+// rounded to 15 significant digits, dropping the rounding error of
+// inverting the matrix.
+func (w WorldFile) WriteTo(out io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, v := range []float64{w.A, w.D, w.B, w.E, w.C, w.F} {
+		v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+		if v == 0 {
+			v = 0 // not -0
+		}
+		b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		b.WriteString("\n")
+	}
+	n, err := io.WriteString(out, b.String())
+	return int64(n), err
+}
+
+// WorldFilePath returns the conventional path of the world file of the
+// image at path: the first and last letters of its extension followed by
+// w, as in map.pgw for map.png and map.jgw for map.jpg.
+func WorldFilePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	if len(ext) < 3 {
+		return fmt.Sprintf("%s%sw", base, ext)
+	}
+	return fmt.Sprintf("%s.%c%cw", base, ext[1], ext[len(ext)-1])
+}